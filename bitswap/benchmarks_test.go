@@ -23,6 +23,7 @@ import (
 	mockrouting "github.com/ipfs/boxo/routing/mock"
 	cid "github.com/ipfs/go-cid"
 	delay "github.com/ipfs/go-ipfs-delay"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 type fetchFunc func(b *testing.B, bs *bitswap.Bitswap, ks []cid.Cid)
@@ -310,6 +311,133 @@ func BenchmarkDatacenterMultiLeechMultiSeed(b *testing.B) {
 	printResults(benchmarkLog)
 }
 
+// realisticBench fetches from seeds that all have all the blocks, half of them
+// close to the fetcher, and half far away behind slow and lossy links.
+type realisticBench struct {
+	name       string
+	seedCount  int
+	blockCount int
+	// churn makes a seed leave early in the fetch and join again later, and
+	// another seed join late in the fetch.
+	churn   bool
+	fetchFn fetchFunc
+}
+
+var realisticBenches = []realisticBench{
+	{"10Nodes-AllToAll-BigBatch", 9, 100, false, batchFetchAll},
+	{"10Nodes-AllToAll-BigBatch-Churn", 9, 100, true, batchFetchAll},
+	{"10Nodes-AllToAll-UnixfsFetch", 9, 100, false, unixfsFileFetch},
+	{"10Nodes-AllToAll-UnixfsFetch-Churn", 9, 100, true, unixfsFileFetch},
+}
+
+var realisticReports []testinstance.Report
+
+func BenchmarkRealisticNetwork(b *testing.B) {
+	realisticReports = nil
+	benchmarkSeed, err := strconv.ParseInt(os.Getenv("BENCHMARK_SEED"), 10, 64)
+	var randomGen *rand.Rand = nil
+	if err == nil {
+		randomGen = rand.New(rand.NewSource(benchmarkSeed))
+	}
+
+	closeLink := tn.Link{
+		Latency: delay.Delay(fastSpeed, tn.InternetLatencyDelayGenerator(
+			mediumSpeed-fastSpeed, slowSpeed-fastSpeed,
+			0.0, 0.0, distribution, randomGen)),
+		Bandwidth: fastBandwidth,
+	}
+	farLink := tn.Link{
+		Latency: delay.Delay(fastSpeed, tn.InternetLatencyDelayGenerator(
+			mediumSpeed-fastSpeed, superSlowSpeed-fastSpeed,
+			0.3, 0.3, distribution, randomGen)),
+		Bandwidth: slowBandwidth,
+		Loss:      0.01,
+	}
+
+	for _, bch := range realisticBenches {
+		b.Run(bch.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				runRealisticDistribution(b, bch, closeLink, farLink, randomGen)
+			}
+		})
+	}
+
+	out, _ := json.MarshalIndent(realisticReports, "", "  ")
+	_ = os.WriteFile("tmp/realistic-benchmark.json", out, 0o666)
+	for _, r := range realisticReports {
+		fmt.Println(r)
+	}
+}
+
+func runRealisticDistribution(b *testing.B, bch realisticBench, closeLink, farLink tn.Link, rng *rand.Rand) {
+	// far is filled before the instances are connected, the topology is only
+	// called on the first message of each link.
+	far := make(map[peer.ID]bool)
+	net := tn.TopologyVirtualNetwork(mockrouting.NewServer(), func(from, to peer.ID) tn.Link {
+		if far[from] || far[to] {
+			return farLink
+		}
+		return closeLink
+	}, rng)
+
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+	measure := &testinstance.Measure{}
+	fetcherGen := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithTracer(measure)})
+	defer fetcherGen.Close()
+
+	seeds := make([]testinstance.Instance, bch.seedCount)
+	for i := range seeds {
+		seeds[i] = ig.Next()
+		if i%2 == 1 {
+			far[seeds[i].Peer] = true
+		}
+	}
+	fetcher := fetcherGen.Next()
+	blks := testutil.GenerateBlocksOfSize(bch.blockCount, stdBlockSize)
+	allToAll(b, seeds, blks)
+
+	online := append([]testinstance.Instance{fetcher}, seeds...)
+	var script []tn.ChurnEvent
+	if bch.churn {
+		late := seeds[len(seeds)-1]
+		if err := net.Leave(late.Peer); err != nil {
+			b.Fatal(err)
+		}
+		online = online[:len(online)-1]
+		script = []tn.ChurnEvent{
+			{At: 100 * time.Millisecond, Peer: seeds[0].Peer, Leave: true},
+			{At: 500 * time.Millisecond, Peer: late.Peer, Connect: []peer.ID{fetcher.Peer}},
+			{At: 2 * time.Second, Peer: seeds[0].Peer},
+		}
+	}
+	testinstance.ConnectInstances(online)
+
+	var ks []cid.Cid
+	for _, blk := range blks {
+		ks = append(ks, blk.Cid())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	churnDone := make(chan error, 1)
+	measure.Start()
+	go func() {
+		churnDone <- tn.RunChurn(ctx, net, script)
+	}()
+	bch.fetchFn(b, fetcher.Exchange, ks)
+
+	report, err := measure.Report(b.Name(), fetcher)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cancel()
+	if err := <-churnDone; err != nil && err != context.Canceled {
+		b.Fatal(err)
+	}
+	realisticReports = append(realisticReports, report)
+}
+
 func subtestDistributeAndFetch(b *testing.B, numnodes, numblks int, d delay.D, bstoreLatency time.Duration, df distFunc, ff fetchFunc) {
 	for i := 0; i < b.N; i++ {
 		net := tn.VirtualNetwork(mockrouting.NewServer(), d)
@@ -116,6 +116,7 @@ type Stat struct {
 	BlocksSent       uint64
 	DataSent         uint64
 	ProvideBufLen    int
+	PeerThroughput   map[string]float64
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
@@ -139,6 +140,7 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 		BlocksSent:       ss.BlocksSent,
 		DataSent:         ss.DataSent,
 		ProvideBufLen:    ss.ProvideBufLen,
+		PeerThroughput:   ss.PeerThroughput,
 	}, nil
 }
 
@@ -113,7 +113,7 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		}
 	}
 	peerQueueFactory := func(ctx context.Context, p peer.ID) bspm.PeerQueue {
-		return bsmq.New(ctx, p, network, onDontHaveTimeout)
+		return bsmq.New(ctx, p, network, onDontHaveTimeout, bs.tracer)
 	}
 
 	sim := bssim.New()
@@ -149,6 +149,7 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		pm:                         pm,
 		pqm:                        pqm,
 		sm:                         sm,
+		bpm:                        bpm,
 		sim:                        sim,
 		notif:                      notif,
 		counters:                   new(counters),
@@ -216,6 +217,10 @@ type Client struct {
 	// in which CIDs
 	sim *bssim.SessionInterestManager
 
+	// the BlockPresenceManager keeps track of the HAVE / DONT_HAVE received
+	// from peers
+	bpm *bsbpm.BlockPresenceManager
+
 	// how long to wait before looking for providers in a session
 	provSearchDelay time.Duration
 
@@ -48,6 +48,23 @@ func (bpm *BlockPresenceManager) updateBlockPresence(p peer.ID, c cid.Cid, prese
 	bpm.presence[c][p] = present
 }
 
+// Presences returns a copy of the HAVE (true) and DONT_HAVE (false) received
+// from each peer for each cid.
+func (bpm *BlockPresenceManager) Presences() map[cid.Cid]map[peer.ID]bool {
+	bpm.RLock()
+	defer bpm.RUnlock()
+
+	out := make(map[cid.Cid]map[peer.ID]bool, len(bpm.presence))
+	for c, ps := range bpm.presence {
+		cp := make(map[peer.ID]bool, len(ps))
+		for p, has := range ps {
+			cp[p] = has
+		}
+		out[c] = cp
+	}
+	return out
+}
+
 // PeerHasBlock indicates whether the given peer has sent a HAVE for the given
 // cid
 func (bpm *BlockPresenceManager) PeerHasBlock(p peer.ID, c cid.Cid) bool {
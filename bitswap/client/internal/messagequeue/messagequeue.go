@@ -11,6 +11,7 @@ import (
 	bsmsg "github.com/ipfs/boxo/bitswap/message"
 	pb "github.com/ipfs/boxo/bitswap/message/pb"
 	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/tracer"
 	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -105,6 +106,9 @@ type MessageQueue struct {
 
 	// Used to track things that happen asynchronously -- used only in test
 	events chan messageEvent
+
+	// Traces the messages sent to the peer, if not nil
+	tracer tracer.Tracer
 }
 
 // recallWantlist keeps a list of pending wants and a list of sent wants
@@ -213,15 +217,18 @@ type DontHaveTimeoutManager interface {
 	UpdateMessageLatency(time.Duration)
 }
 
-// New creates a new MessageQueue.
-func New(ctx context.Context, p peer.ID, network MessageNetwork, onDontHaveTimeout OnDontHaveTimeout) *MessageQueue {
+// New creates a new MessageQueue. The messages sent to the peer are passed to
+// tap, if not nil.
+func New(ctx context.Context, p peer.ID, network MessageNetwork, onDontHaveTimeout OnDontHaveTimeout, tap tracer.Tracer) *MessageQueue {
 	onTimeout := func(ks []cid.Cid) {
 		log.Infow("Bitswap: timeout waiting for blocks", "cids", ks, "peer", p)
 		onDontHaveTimeout(p, ks)
 	}
 	clock := clock.New()
 	dhTimeoutMgr := newDontHaveTimeoutMgr(newPeerConnection(p, network), onTimeout, clock)
-	return newMessageQueue(ctx, p, network, maxMessageSize, sendErrorBackoff, maxValidLatency, dhTimeoutMgr, clock, nil)
+	mq := newMessageQueue(ctx, p, network, maxMessageSize, sendErrorBackoff, maxValidLatency, dhTimeoutMgr, clock, nil)
+	mq.tracer = tap
+	return mq
 }
 
 type messageEvent int
@@ -546,6 +553,9 @@ func (mq *MessageQueue) sendMessage() {
 		mq.Shutdown()
 		return
 	}
+	if mq.tracer != nil {
+		mq.tracer.MessageSent(mq.p, message)
+	}
 
 	// Record sent time so as to calculate message latency
 	onSent()
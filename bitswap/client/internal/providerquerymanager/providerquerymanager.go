@@ -21,6 +21,7 @@ const (
 
 type inProgressRequestStatus struct {
 	ctx            context.Context
+	started        time.Time
 	cancelFn       func()
 	providersSoFar []peer.ID
 	listeners      map[chan peer.ID]struct{}
@@ -65,6 +66,19 @@ type cancelRequestMessage struct {
 	k                 cid.Cid
 }
 
+type statRequestMessage struct {
+	statsChan chan<- []QueryStat
+}
+
+// QueryStat is a snapshot of the state of an in-progress provider query.
+type QueryStat struct {
+	Cid       cid.Cid
+	Started   time.Time
+	Providers []peer.ID
+	// Listeners is the number of sessions waiting on the query.
+	Listeners int
+}
+
 // ProviderQueryManager manages requests to find more providers for blocks
 // for bitswap sessions. It's main goals are to:
 // - rate limit requests -- don't have too many find provider calls running
@@ -152,6 +166,27 @@ func (pqm *ProviderQueryManager) FindProvidersAsync(sessionCtx context.Context,
 	return pqm.receiveProviders(sessionCtx, k, receivedInProgressRequest)
 }
 
+// Stat returns a snapshot of the in-progress provider queries.
+func (pqm *ProviderQueryManager) Stat(ctx context.Context) ([]QueryStat, error) {
+	statsChan := make(chan []QueryStat, 1)
+	select {
+	case pqm.providerQueryMessages <- &statRequestMessage{statsChan: statsChan}:
+	case <-pqm.ctx.Done():
+		return nil, pqm.ctx.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case stats := <-statsChan:
+		return stats, nil
+	case <-pqm.ctx.Done():
+		return nil, pqm.ctx.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (pqm *ProviderQueryManager) receiveProviders(sessionCtx context.Context, k cid.Cid, receivedInProgressRequest inProgressRequest) <-chan peer.ID {
 	// maintains an unbuffered queue for incoming providers for given request for a given session
 	// essentially, as a provider comes in, for a given CID, we want to immediately broadcast to all
@@ -383,6 +418,7 @@ func (npqm *newProvideQueryMessage) handle(pqm *ProviderQueryManager) {
 		requestStatus = &inProgressRequestStatus{
 			listeners: make(map[chan peer.ID]struct{}),
 			ctx:       ctx,
+			started:   time.Now(),
 			cancelFn:  cancelFn,
 		}
 		pqm.inProgressRequestStatuses[npqm.k] = requestStatus
@@ -428,3 +464,20 @@ func (crm *cancelRequestMessage) handle(pqm *ProviderQueryManager) {
 		requestStatus.cancelFn()
 	}
 }
+
+func (srm *statRequestMessage) debugMessage() string {
+	return "Stat provider queries"
+}
+
+func (srm *statRequestMessage) handle(pqm *ProviderQueryManager) {
+	stats := make([]QueryStat, 0, len(pqm.inProgressRequestStatuses))
+	for k, requestStatus := range pqm.inProgressRequestStatuses {
+		stats = append(stats, QueryStat{
+			Cid:       k,
+			Started:   requestStatus.started,
+			Providers: append([]peer.ID(nil), requestStatus.providersSoFar...),
+			Listeners: len(requestStatus.listeners),
+		})
+	}
+	srm.statsChan <- stats
+}
@@ -365,3 +365,43 @@ func TestCancelFindProvidersAfterCompletion(t *testing.T) {
 		}
 	}
 }
+
+func TestStatInProgressQueries(t *testing.T) {
+	peers := testutil.GeneratePeers(10)
+	fpn := &fakeProviderNetwork{
+		peersFound: peers,
+		delay:      10 * time.Millisecond,
+	}
+	ctx := context.Background()
+	providerQueryManager := New(ctx, fpn)
+	providerQueryManager.Startup()
+	key := testutil.GenerateCids(1)[0]
+
+	sessionCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	firstRequestChan := providerQueryManager.FindProvidersAsync(sessionCtx, key)
+	secondRequestChan := providerQueryManager.FindProvidersAsync(sessionCtx, key)
+
+	// Wait for a provider, so that the query is in progress.
+	<-firstRequestChan
+
+	stats, err := providerQueryManager.Stat(sessionCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || !stats[0].Cid.Equals(key) || stats[0].Listeners != 2 || len(stats[0].Providers) == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	for range firstRequestChan {
+	}
+	for range secondRequestChan {
+	}
+	stats, err = providerQueryManager.Stat(sessionCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 0 {
+		t.Fatalf("expected no query in progress, got %+v", stats)
+	}
+}
@@ -119,6 +119,9 @@ type Session struct {
 	// channels
 	incoming      chan op
 	tickDelayReqs chan time.Duration
+	statReqs      chan chan Stat
+
+	started time.Time
 
 	// do not touch outside run loop
 	idleTick            *time.Timer
@@ -154,6 +157,8 @@ func New(
 	s := &Session{
 		sw:                  newSessionWants(broadcastLiveWantsLimit),
 		tickDelayReqs:       make(chan time.Duration),
+		statReqs:            make(chan chan Stat),
+		started:             time.Now(),
 		ctx:                 ctx,
 		shutdown:            cancel,
 		sm:                  sm,
@@ -266,6 +271,33 @@ func (s *Session) SetBaseTickDelay(baseTickDelay time.Duration) {
 	}
 }
 
+// Stat is a snapshot of the state of a session.
+type Stat struct {
+	ID      uint64
+	Started time.Time
+	// Pending are the wants that have not been sent to any peer yet.
+	Pending []cid.Cid
+	// Live are the wants that have been sent, with the time they were sent.
+	Live map[cid.Cid]time.Time
+	// Peers are the peers the session sends wants to.
+	Peers []peer.ID
+	// AverageLatency is the average time between sending a want and
+	// receiving the block. It is zero until a block is received.
+	AverageLatency time.Duration
+}
+
+// Stat returns a snapshot of the state of the session. It returns false if the
+// session is shut down.
+func (s *Session) Stat() (Stat, bool) {
+	resp := make(chan Stat, 1)
+	select {
+	case s.statReqs <- resp:
+	case <-s.ctx.Done():
+		return Stat{}, false
+	}
+	return <-resp, true
+}
+
 // onWantsSent is called when wants are sent to a peer by the session wants sender
 func (s *Session) onWantsSent(p peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid) {
 	allBlks := append(wantBlocks[:len(wantBlocks):len(wantBlocks)], wantHaves...)
@@ -333,6 +365,9 @@ func (s *Session) run(ctx context.Context) {
 		case baseTickDelay := <-s.tickDelayReqs:
 			// Set the base tick delay
 			s.baseTickDelay = baseTickDelay
+		case resp := <-s.statReqs:
+			// Take a snapshot of the session
+			resp <- s.stat()
 		case <-ctx.Done():
 			// Shutdown
 			s.handleShutdown()
@@ -489,6 +524,23 @@ func (s *Session) resetIdleTick() {
 	s.idleTick.Reset(tickDelay)
 }
 
+func (s *Session) stat() Stat {
+	st := Stat{
+		ID:      s.id,
+		Started: s.started,
+		Pending: s.sw.toFetch.Cids(),
+		Live:    make(map[cid.Cid]time.Time, len(s.sw.liveWants)),
+		Peers:   s.sprm.Peers(),
+	}
+	for c, sentAt := range s.sw.liveWants {
+		st.Live[c] = sentAt
+	}
+	if s.latencyTrkr.hasLatency() {
+		st.AverageLatency = s.latencyTrkr.averageLatency()
+	}
+	return st
+}
+
 // latencyTracker keeps track of the average latency between sending a want
 // and receiving the corresponding block
 type latencyTracker struct {
@@ -2,6 +2,7 @@ package sessionmanager
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -25,6 +26,7 @@ type Session interface {
 	exchange.Fetcher
 	ID() uint64
 	ReceiveFrom(peer.ID, []cid.Cid, []cid.Cid, []cid.Cid)
+	Stat() (bssession.Stat, bool)
 	Shutdown()
 }
 
@@ -143,6 +145,25 @@ func (sm *SessionManager) RemoveSession(sesid uint64) {
 	}
 }
 
+// SessionStats returns a snapshot of the state of each active session.
+func (sm *SessionManager) SessionStats() []bssession.Stat {
+	sm.sessLk.RLock()
+	sessions := make([]Session, 0, len(sm.sessions))
+	for _, ses := range sm.sessions {
+		sessions = append(sessions, ses)
+	}
+	sm.sessLk.RUnlock()
+
+	stats := make([]bssession.Stat, 0, len(sessions))
+	for _, ses := range sessions {
+		if st, ok := ses.Stat(); ok {
+			stats = append(stats, st)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
 // GetNextSessionID returns the next sequential identifier for a session.
 func (sm *SessionManager) GetNextSessionID() uint64 {
 	sm.sessIDLk.Lock()
@@ -47,6 +47,10 @@ func (fs *fakeSession) ReceiveFrom(p peer.ID, ks []cid.Cid, wantBlocks []cid.Cid
 	fs.wantHaves = append(fs.wantHaves, wantHaves...)
 }
 
+func (fs *fakeSession) Stat() (bssession.Stat, bool) {
+	return bssession.Stat{ID: fs.id}, true
+}
+
 func (fs *fakeSession) Shutdown() {
 	fs.sm.RemoveSession(fs.id)
 }
@@ -1,7 +1,12 @@
 package client
 
 import (
+	"context"
+
+	bspqm "github.com/ipfs/boxo/bitswap/client/internal/providerquerymanager"
+	bssession "github.com/ipfs/boxo/bitswap/client/internal/session"
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
 // Stat is a struct that provides various statistics on bitswap operations
@@ -14,6 +19,13 @@ type Stat struct {
 	MessagesReceived uint64
 }
 
+type (
+	// SessionStat is a snapshot of the state of a session.
+	SessionStat = bssession.Stat
+	// ProviderQueryStat is a snapshot of the state of a provider query.
+	ProviderQueryStat = bspqm.QueryStat
+)
+
 // Stat returns aggregated statistics about bitswap operations
 func (bs *Client) Stat() (st Stat, err error) {
 	bs.counterLk.Lock()
@@ -28,3 +40,20 @@ func (bs *Client) Stat() (st Stat, err error) {
 
 	return st, nil
 }
+
+// SessionStats returns a snapshot of the state of the active sessions, ordered
+// by ID.
+func (bs *Client) SessionStats() []SessionStat {
+	return bs.sm.SessionStats()
+}
+
+// ProviderQueryStats returns a snapshot of the in-progress provider queries.
+func (bs *Client) ProviderQueryStats(ctx context.Context) ([]ProviderQueryStat, error) {
+	return bs.pqm.Stat(ctx)
+}
+
+// BlockPresences returns, for each cid the sessions are interested in, whether
+// each peer sent a HAVE (true) or a DONT_HAVE (false).
+func (bs *Client) BlockPresences() map[cid.Cid]map[peer.ID]bool {
+	return bs.bpm.Presences()
+}
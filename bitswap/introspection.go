@@ -0,0 +1,341 @@
+package bitswap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap/client"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/server"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// IntrospectionWant is a want of a peer, as served by the introspection
+// handler.
+type IntrospectionWant struct {
+	Cid      string
+	Priority int32
+	WantType string
+}
+
+// IntrospectionSession is a snapshot of a client session, as served by the
+// introspection handler.
+type IntrospectionSession struct {
+	ID      uint64
+	Started time.Time
+	// Pending are the wants that have not been sent to any peer yet.
+	Pending []string
+	// Live are the wants that have been sent, with the time they were sent.
+	Live           map[string]time.Time
+	Peers          []string
+	AverageLatency time.Duration
+}
+
+// IntrospectionProviderQuery is a snapshot of an in-progress provider query, as
+// served by the introspection handler.
+type IntrospectionProviderQuery struct {
+	Cid       string
+	Started   time.Time
+	Providers []string
+	Listeners int
+}
+
+// Introspection is a snapshot of the state of bitswap, as served by the
+// introspection handler.
+type Introspection struct {
+	// Wantlists are the wantlists of the peers, by peer.
+	Wantlists map[string][]IntrospectionWant `json:",omitempty"`
+	// Ledgers are the ledger receipts of the peers, by peer.
+	Ledgers map[string]*server.Receipt `json:",omitempty"`
+	// Sessions are the active client sessions, ordered by ID.
+	Sessions []IntrospectionSession `json:",omitempty"`
+	// ProviderQueries are the in-progress provider queries, ordered by cid.
+	ProviderQueries []IntrospectionProviderQuery `json:",omitempty"`
+	// BlockPresences are the HAVE and DONT_HAVE received by the client, by
+	// cid and peer.
+	BlockPresences map[string]map[string]string `json:",omitempty"`
+}
+
+// introspectionFilter restricts a snapshot to a cid and a peer. The zero values
+// match everything.
+type introspectionFilter struct {
+	cid  cid.Cid
+	peer peer.ID
+}
+
+func (f introspectionFilter) matchCid(c cid.Cid) bool {
+	return !f.cid.Defined() || f.cid.Equals(c)
+}
+
+func (f introspectionFilter) matchPeer(p peer.ID) bool {
+	return f.peer == "" || f.peer == p
+}
+
+func (f introspectionFilter) matchAnyCid(cs []cid.Cid) bool {
+	if !f.cid.Defined() {
+		return true
+	}
+	for _, c := range cs {
+		if f.cid.Equals(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f introspectionFilter) matchAnyPeer(ps []peer.ID) bool {
+	if f.peer == "" {
+		return true
+	}
+	for _, p := range ps {
+		if f.peer == p {
+			return true
+		}
+	}
+	return false
+}
+
+type introspectionHandler struct {
+	bs *Bitswap
+}
+
+// NewIntrospectionHandler returns an [http.Handler] that serves JSON snapshots
+// of the internal state of bs, to debug stuck transfers:
+//
+//   - /wantlists: the wantlists of the peers
+//   - /ledgers: the ledger receipts of the peers
+//   - /sessions: the active client sessions, with their wants, peers and timings
+//   - /provider-queries: the in-progress provider queries
+//   - /block-presences: the HAVE and DONT_HAVE received by the client
+//   - /: all of the above, as an [Introspection]
+//
+// The paths are relative to where the handler is mounted, use
+// [http.StripPrefix] to mount it under a prefix. The snapshots can be filtered
+// with the cid and peer query parameters.
+//
+// The snapshots can be large, and reveal what the node is fetching and from
+// whom: do not expose the handler publicly.
+func NewIntrospectionHandler(bs *Bitswap) http.Handler {
+	return &introspectionHandler{bs: bs}
+}
+
+func (h *introspectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var f introspectionFilter
+	query := r.URL.Query()
+	if s := query.Get("cid"); s != "" {
+		c, err := cid.Decode(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid cid: %s", err), http.StatusBadRequest)
+			return
+		}
+		f.cid = c
+	}
+	if s := query.Get("peer"); s != "" {
+		p, err := peer.Decode(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid peer: %s", err), http.StatusBadRequest)
+			return
+		}
+		f.peer = p
+	}
+
+	var out interface{}
+	switch strings.Trim(r.URL.Path, "/") {
+	case "":
+		snapshot, err := h.snapshot(r, f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		out = snapshot
+	case "wantlists":
+		out = h.wantlists(f)
+	case "ledgers":
+		out = h.ledgers(f)
+	case "sessions":
+		out = h.sessions(f)
+	case "provider-queries":
+		queries, err := h.providerQueries(r, f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		out = queries
+	case "block-presences":
+		out = h.blockPresences(f)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		log.Debugf("failed to write introspection response: %s", err)
+	}
+}
+
+func (h *introspectionHandler) snapshot(r *http.Request, f introspectionFilter) (*Introspection, error) {
+	queries, err := h.providerQueries(r, f)
+	if err != nil {
+		return nil, err
+	}
+	return &Introspection{
+		Wantlists:       h.wantlists(f),
+		Ledgers:         h.ledgers(f),
+		Sessions:        h.sessions(f),
+		ProviderQueries: queries,
+		BlockPresences:  h.blockPresences(f),
+	}, nil
+}
+
+func (h *introspectionHandler) peers(f introspectionFilter) []peer.ID {
+	if f.peer != "" {
+		return []peer.ID{f.peer}
+	}
+	return h.bs.Server.Peers()
+}
+
+func (h *introspectionHandler) wantlists(f introspectionFilter) map[string][]IntrospectionWant {
+	out := make(map[string][]IntrospectionWant)
+	for _, p := range h.peers(f) {
+		var wants []IntrospectionWant
+		for _, e := range h.bs.Server.WantlistEntriesForPeer(p) {
+			if !f.matchCid(e.Cid) {
+				continue
+			}
+			wantType := "block"
+			if e.WantType == pb.Message_Wantlist_Have {
+				wantType = "have"
+			}
+			wants = append(wants, IntrospectionWant{
+				Cid:      e.Cid.String(),
+				Priority: e.Priority,
+				WantType: wantType,
+			})
+		}
+		if len(wants) > 0 {
+			out[p.String()] = wants
+		}
+	}
+	return out
+}
+
+func (h *introspectionHandler) ledgers(f introspectionFilter) map[string]*server.Receipt {
+	out := make(map[string]*server.Receipt)
+	for _, p := range h.peers(f) {
+		if receipt := h.bs.Server.LedgerForPeer(p); receipt != nil {
+			out[p.String()] = receipt
+		}
+	}
+	return out
+}
+
+func (h *introspectionHandler) sessions(f introspectionFilter) []IntrospectionSession {
+	out := make([]IntrospectionSession, 0)
+	for _, st := range h.bs.Client.SessionStats() {
+		live := make([]cid.Cid, 0, len(st.Live))
+		for c := range st.Live {
+			live = append(live, c)
+		}
+		if !(f.matchAnyCid(st.Pending) || f.matchAnyCid(live)) || !f.matchAnyPeer(st.Peers) {
+			continue
+		}
+		out = append(out, newIntrospectionSession(st))
+	}
+	return out
+}
+
+func newIntrospectionSession(st client.SessionStat) IntrospectionSession {
+	s := IntrospectionSession{
+		ID:             st.ID,
+		Started:        st.Started,
+		Pending:        cidStrings(st.Pending),
+		Live:           make(map[string]time.Time, len(st.Live)),
+		Peers:          peerStrings(st.Peers),
+		AverageLatency: st.AverageLatency,
+	}
+	for c, sentAt := range st.Live {
+		s.Live[c.String()] = sentAt
+	}
+	return s
+}
+
+func (h *introspectionHandler) providerQueries(r *http.Request, f introspectionFilter) ([]IntrospectionProviderQuery, error) {
+	stats, err := h.bs.Client.ProviderQueryStats(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]IntrospectionProviderQuery, 0, len(stats))
+	for _, st := range stats {
+		if !f.matchCid(st.Cid) || !f.matchAnyPeer(st.Providers) {
+			continue
+		}
+		out = append(out, IntrospectionProviderQuery{
+			Cid:       st.Cid.String(),
+			Started:   st.Started,
+			Providers: peerStrings(st.Providers),
+			Listeners: st.Listeners,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cid < out[j].Cid })
+	return out, nil
+}
+
+func (h *introspectionHandler) blockPresences(f introspectionFilter) map[string]map[string]string {
+	out := make(map[string]map[string]string)
+	for c, presences := range h.bs.Client.BlockPresences() {
+		if !f.matchCid(c) {
+			continue
+		}
+		peers := make(map[string]string)
+		for p, has := range presences {
+			if !f.matchPeer(p) {
+				continue
+			}
+			if has {
+				peers[p.String()] = "HAVE"
+			} else {
+				peers[p.String()] = "DONT_HAVE"
+			}
+		}
+		if len(peers) > 0 {
+			out[c.String()] = peers
+		}
+	}
+	return out
+}
+
+func cidStrings(cs []cid.Cid) []string {
+	out := make([]string, len(cs))
+	for i, c := range cs {
+		out[i] = c.String()
+	}
+	return out
+}
+
+func peerStrings(ps []peer.ID) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.String()
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,108 @@
+package bitswap_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	testinstance "github.com/ipfs/boxo/bitswap/testinstance"
+	tn "github.com/ipfs/boxo/bitswap/testnet"
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	cid "github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	delay "github.com/ipfs/go-ipfs-delay"
+)
+
+func getIntrospection(t *testing.T, h http.Handler, target string, status int, out interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != status {
+		t.Fatalf("GET %s: expected status %d, got %d: %s", target, status, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := ig.Instances(2)
+	a, b := instances[0], instances[1]
+	blks := bg.Blocks(2)
+	present, missing := blks[0], blks[1]
+	addBlock(t, context.Background(), b, present)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Fetch the present block to fill the ledgers, and keep a session
+	// waiting for the missing block.
+	if _, err := a.Exchange.GetBlock(ctx, present.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	sessCtx, sessCancel := context.WithCancel(ctx)
+	defer sessCancel()
+	if _, err := a.Exchange.NewSession(sessCtx).GetBlocks(sessCtx, []cid.Cid{missing.Cid()}); err != nil {
+		t.Fatal(err)
+	}
+
+	ha := bitswap.NewIntrospectionHandler(a.Exchange)
+	hb := bitswap.NewIntrospectionHandler(b.Exchange)
+
+	// The session wants the missing block asynchronously.
+	var sessions []bitswap.IntrospectionSession
+	for {
+		getIntrospection(t, ha, "/sessions?cid="+missing.Cid().String(), http.StatusOK, &sessions)
+		if len(sessions) == 1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected the session waiting for the missing block, got %+v", sessions)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	getIntrospection(t, ha, "/sessions?cid="+present.Cid().String(), http.StatusOK, &sessions)
+	if len(sessions) != 0 {
+		t.Fatalf("expected no session waiting for the present block, got %+v", sessions)
+	}
+
+	// The wantlist of a reaches b asynchronously.
+	var wantlists map[string][]bitswap.IntrospectionWant
+	for {
+		getIntrospection(t, hb, "/wantlists?peer="+a.Peer.String(), http.StatusOK, &wantlists)
+		if wants := wantlists[a.Peer.String()]; len(wants) == 1 && wants[0].Cid == missing.Cid().String() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected the missing block in the wantlist, got %+v", wantlists)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	var snapshot bitswap.Introspection
+	getIntrospection(t, hb, "/?peer="+a.Peer.String(), http.StatusOK, &snapshot)
+	receipt := snapshot.Ledgers[a.Peer.String()]
+	if receipt == nil || receipt.Sent != uint64(len(present.RawData())) {
+		t.Fatalf("expected the ledger of the peer, got %+v", snapshot.Ledgers)
+	}
+	if len(snapshot.Wantlists) != 1 {
+		t.Fatalf("expected the wantlist of the peer, got %+v", snapshot.Wantlists)
+	}
+
+	getIntrospection(t, ha, "/?cid=invalid", http.StatusBadRequest, nil)
+	getIntrospection(t, ha, "/?peer=invalid", http.StatusBadRequest, nil)
+	getIntrospection(t, ha, "/unknown", http.StatusNotFound, nil)
+}
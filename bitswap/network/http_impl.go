@@ -0,0 +1,532 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	peerstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultHTTPMaxRequestsPerPeer is the default maximum number of concurrent
+// requests to each HTTP peer.
+const DefaultHTTPMaxRequestsPerPeer = 16
+
+// identityCid is the empty identity CID, which every trustless gateway can
+// serve without looking it up. It is used to measure the latency of HTTP peers.
+const identityCid = "bafkqaaa"
+
+// errHTTPNotFound is returned when an HTTP peer does not have a block.
+var errHTTPNotFound = errors.New("block not found on HTTP peer")
+
+// NewFromIpfsHostWithHTTP returns a BitSwapNetwork that also reaches the
+// providers with HTTP addresses (multiaddrs ending in /http, /https or
+// /tls/http) as [trustless gateways]. Other peers are reached over libp2p as
+// with NewFromIpfsHost.
+//
+// Want-have entries sent to HTTP peers are translated into HEAD requests, and
+// want-block entries into GET requests for the raw block. Their responses are
+// passed to the receivers as HAVE, DONT_HAVE and block messages from the HTTP
+// peer, which can therefore be used by client sessions like any other peer.
+// HTTP peers are only learned from the provider records of the routing.
+//
+// [trustless gateways]: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+func NewFromIpfsHostWithHTTP(host host.Host, r routing.ContentRouting, opts ...NetOpt) BitSwapNetwork {
+	s := processSettings(opts...)
+	if s.HTTPClient == nil {
+		s.HTTPClient = http.DefaultClient
+	}
+	if s.HTTPMaxRequestsPerPeer <= 0 {
+		s.HTTPMaxRequestsPerPeer = DefaultHTTPMaxRequestsPerPeer
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpImpl{
+		impl:               newImpl(host, r, s),
+		client:             s.HTTPClient,
+		maxRequestsPerPeer: s.HTTPMaxRequestsPerPeer,
+		ctx:                ctx,
+		cancel:             cancel,
+		peers:              make(map[peer.ID]*httpPeer),
+	}
+}
+
+// httpImpl dispatches the messages to HTTP peers to their gateways, and the
+// other messages to the libp2p network.
+type httpImpl struct {
+	*impl
+
+	client             *http.Client
+	maxRequestsPerPeer int
+
+	// ctx is cancelled when the network is stopped, to abort pending requests.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	peersLk sync.RWMutex
+	peers   map[peer.ID]*httpPeer
+	// nextSweep is when the expired peers are next removed from peers.
+	nextSweep time.Time
+}
+
+// httpPeer is a peer reached through its gateways.
+type httpPeer struct {
+	urls []*url.URL
+	// expires is when the peer is forgotten, unless it is used or learned
+	// again in the meantime.
+	expires time.Time
+	// requests limits the number of concurrent requests to the peer, across
+	// all its message senders.
+	requests chan struct{}
+	// sender sends the messages passed to SendMessage. It is created on the
+	// first one.
+	sender *httpMessageSender
+}
+
+// httpPeer returns p if it is an HTTP peer, and extends its expiration, or
+// nil otherwise.
+func (bsnet *httpImpl) httpPeer(p peer.ID) *httpPeer {
+	bsnet.peersLk.Lock()
+	defer bsnet.peersLk.Unlock()
+	return bsnet.httpPeerLocked(p)
+}
+
+// httpPeerLocked is httpPeer with the lock held.
+func (bsnet *httpImpl) httpPeerLocked(p peer.ID) *httpPeer {
+	hp, ok := bsnet.peers[p]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	if now.After(hp.expires) {
+		bsnet.forgetLocked(p, hp)
+		return nil
+	}
+	hp.expires = now.Add(peerstore.TempAddrTTL)
+	return hp
+}
+
+// httpURLs returns the current gateway URLs of hp.
+func (bsnet *httpImpl) httpURLs(hp *httpPeer) []*url.URL {
+	bsnet.peersLk.RLock()
+	defer bsnet.peersLk.RUnlock()
+	return hp.urls
+}
+
+// addHTTPPeer records the gateway URLs of p, for as long as the temporary
+// addresses of the peerstore, and removes the peers that expired.
+func (bsnet *httpImpl) addHTTPPeer(p peer.ID, urls []*url.URL) {
+	bsnet.peersLk.Lock()
+	defer bsnet.peersLk.Unlock()
+
+	now := time.Now()
+	if now.After(bsnet.nextSweep) {
+		for q, hp := range bsnet.peers {
+			if now.After(hp.expires) {
+				bsnet.forgetLocked(q, hp)
+			}
+		}
+		bsnet.nextSweep = now.Add(peerstore.TempAddrTTL)
+	}
+
+	hp, ok := bsnet.peers[p]
+	if !ok {
+		hp = &httpPeer{requests: make(chan struct{}, bsnet.maxRequestsPerPeer)}
+		bsnet.peers[p] = hp
+	}
+	hp.urls = urls
+	hp.expires = now.Add(peerstore.TempAddrTTL)
+}
+
+// keepAlive extends the expiration of hp, which is in use, unless it was
+// already forgotten.
+func (bsnet *httpImpl) keepAlive(p peer.ID, hp *httpPeer) {
+	bsnet.peersLk.Lock()
+	defer bsnet.peersLk.Unlock()
+	if bsnet.peers[p] == hp {
+		hp.expires = time.Now().Add(peerstore.TempAddrTTL)
+	}
+}
+
+// forgetLocked removes an expired peer, which is reported as disconnected. It
+// must be called with the lock held.
+func (bsnet *httpImpl) forgetLocked(p peer.ID, hp *httpPeer) {
+	delete(bsnet.peers, p)
+	if hp.sender != nil {
+		hp.sender.Close()
+	}
+	bsnet.connectEvtMgr.Disconnected(p)
+}
+
+func (bsnet *httpImpl) Stop() {
+	bsnet.cancel()
+	bsnet.impl.Stop()
+}
+
+func (bsnet *httpImpl) ConnectTo(ctx context.Context, p peer.ID) error {
+	if bsnet.httpPeer(p) == nil {
+		return bsnet.impl.ConnectTo(ctx, p)
+	}
+	// There is no connection to establish: the peer is reported as connected
+	// until requests to it fail.
+	bsnet.connectEvtMgr.Connected(p)
+	return nil
+}
+
+func (bsnet *httpImpl) DisconnectFrom(ctx context.Context, p peer.ID) error {
+	if bsnet.httpPeer(p) == nil {
+		return bsnet.impl.DisconnectFrom(ctx, p)
+	}
+	bsnet.connectEvtMgr.Disconnected(p)
+	return nil
+}
+
+func (bsnet *httpImpl) Ping(ctx context.Context, p peer.ID) ping.Result {
+	hp := bsnet.httpPeer(p)
+	if hp == nil {
+		return bsnet.impl.Ping(ctx, p)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, bsnet.httpURLs(hp)[0].JoinPath("ipfs", identityCid).String(), nil)
+	if err != nil {
+		return ping.Result{Error: err}
+	}
+	start := time.Now()
+	resp, err := bsnet.client.Do(req)
+	if err != nil {
+		return ping.Result{Error: err}
+	}
+	resp.Body.Close()
+
+	// Any response is good enough to measure the round trip time.
+	rtt := time.Since(start)
+	bsnet.host.Peerstore().RecordLatency(p, rtt)
+	return ping.Result{RTT: rtt}
+}
+
+func (bsnet *httpImpl) SendMessage(ctx context.Context, p peer.ID, outgoing bsmsg.BitSwapMessage) error {
+	bsnet.peersLk.Lock()
+	hp := bsnet.httpPeerLocked(p)
+	if hp == nil {
+		bsnet.peersLk.Unlock()
+		return bsnet.impl.SendMessage(ctx, p, outgoing)
+	}
+	// The requests of the messages sent to the same peer are tracked together,
+	// so that their cancels reach them.
+	if hp.sender == nil {
+		hp.sender = bsnet.newHTTPMessageSender(p, hp, setDefaultOpts(&MessageSenderOpts{}))
+	}
+	sender := hp.sender
+	bsnet.peersLk.Unlock()
+
+	return sender.SendMsg(ctx, outgoing)
+}
+
+func (bsnet *httpImpl) NewMessageSender(ctx context.Context, p peer.ID, opts *MessageSenderOpts) (MessageSender, error) {
+	hp := bsnet.httpPeer(p)
+	if hp == nil {
+		return bsnet.impl.NewMessageSender(ctx, p, opts)
+	}
+	return bsnet.newHTTPMessageSender(p, hp, setDefaultOpts(opts)), nil
+}
+
+// FindProvidersAsync returns a channel of providers for the given key. The
+// providers with HTTP addresses are reached through them.
+func (bsnet *httpImpl) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <-chan peer.ID {
+	out := make(chan peer.ID, max)
+	go func() {
+		defer close(out)
+		providers := bsnet.routing.FindProvidersAsync(ctx, k, max)
+		for info := range providers {
+			if info.ID == bsnet.host.ID() {
+				continue // ignore self as provider
+			}
+
+			var urls []*url.URL
+			var addrs []ma.Multiaddr
+			for _, addr := range info.Addrs {
+				if u, ok := httpURL(addr); ok {
+					urls = append(urls, u)
+				} else {
+					addrs = append(addrs, addr)
+				}
+			}
+			if len(urls) > 0 {
+				bsnet.addHTTPPeer(info.ID, urls)
+			}
+			bsnet.host.Peerstore().AddAddrs(info.ID, addrs, peerstore.TempAddrTTL)
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- info.ID:
+			}
+		}
+	}()
+	return out
+}
+
+// httpURL returns the base URL of a multiaddr ending in /http, /https or
+// /tls/http, and false for the other multiaddrs.
+func httpURL(addr ma.Multiaddr) (*url.URL, bool) {
+	var host, port, scheme string
+	var tls, unsupported bool
+	ma.ForEach(addr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_IP6, ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			host = c.Value()
+		case ma.P_TCP:
+			port = c.Value()
+		case ma.P_TLS:
+			tls = true
+		case ma.P_SNI, ma.P_P2P:
+		case ma.P_HTTPS:
+			scheme = "https"
+		case ma.P_HTTP:
+			scheme = "http"
+			if tls {
+				scheme = "https"
+			}
+		default:
+			unsupported = true
+			return false
+		}
+		return true
+	})
+	if unsupported || host == "" || port == "" || scheme == "" {
+		return nil, false
+	}
+	return &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port)}, true
+}
+
+// fetch requests the block from the URLs of an HTTP peer in turn, until one
+// of them answers. It returns a nil block for HEAD requests.
+func (bsnet *httpImpl) fetch(ctx context.Context, urls []*url.URL, c cid.Cid, wantBlock bool) (blocks.Block, error) {
+	var err error
+	for _, u := range urls {
+		var blk blocks.Block
+		blk, err = bsnet.fetchFrom(ctx, u, c, wantBlock)
+		if err == nil || errors.Is(err, errHTTPNotFound) || ctx.Err() != nil {
+			return blk, err
+		}
+		log.Debugf("failed to fetch %s from %s: %s", c, u.Host, err)
+	}
+	return nil, err
+}
+
+func (bsnet *httpImpl) fetchFrom(ctx context.Context, base *url.URL, c cid.Cid, wantBlock bool) (blocks.Block, error) {
+	method := http.MethodHead
+	if wantBlock {
+		method = http.MethodGet
+	}
+	u := base.JoinPath("ipfs", c.String())
+	u.RawQuery = "format=raw"
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	resp, err := bsnet.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return nil, errHTTPNotFound
+	default:
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	if !wantBlock {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, network.MessageSizeMax+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > network.MessageSizeMax {
+		return nil, fmt.Errorf("block %s is larger than %d bytes", c, network.MessageSizeMax)
+	}
+	// Gateways are not trusted: the block must match its CID.
+	chk, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, blocks.ErrWrongHash
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// receive passes a message synthesized from HTTP responses to the receivers.
+func (bsnet *httpImpl) receive(p peer.ID, msg bsmsg.BitSwapMessage) {
+	bsnet.connectEvtMgr.OnMessage(p)
+	atomic.AddUint64(&bsnet.stats.MessagesRecvd, 1)
+	for _, v := range bsnet.receivers {
+		v.ReceiveMessage(context.Background(), p, msg)
+	}
+}
+
+// httpMessageSender translates the wants sent to an HTTP peer into requests to
+// its gateways.
+type httpMessageSender struct {
+	to    peer.ID
+	peer  *httpPeer
+	bsnet *httpImpl
+	opts  *MessageSenderOpts
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lk       sync.Mutex
+	inflight map[httpWant]*httpRequest
+}
+
+type httpWant struct {
+	c        cid.Cid
+	wantType pb.Message_Wantlist_WantType
+}
+
+type httpRequest struct {
+	cancel context.CancelFunc
+}
+
+func (bsnet *httpImpl) newHTTPMessageSender(p peer.ID, hp *httpPeer, opts *MessageSenderOpts) *httpMessageSender {
+	ctx, cancel := context.WithCancel(bsnet.ctx)
+	return &httpMessageSender{
+		to:       p,
+		peer:     hp,
+		bsnet:    bsnet,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		inflight: make(map[httpWant]*httpRequest),
+	}
+}
+
+// SendMsg starts a request for each want of the message, and cancels the
+// pending requests of its cancels. It does not wait for the responses, which
+// are passed to the receivers as they arrive. Blocks and block presences are
+// ignored, as HTTP peers do not want anything.
+func (s *httpMessageSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.bsnet.keepAlive(s.to, s.peer)
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			for _, wantType := range []pb.Message_Wantlist_WantType{pb.Message_Wantlist_Have, pb.Message_Wantlist_Block} {
+				if req, ok := s.inflight[httpWant{e.Cid, wantType}]; ok {
+					req.cancel()
+					delete(s.inflight, httpWant{e.Cid, wantType})
+				}
+			}
+			continue
+		}
+
+		want := httpWant{e.Cid, e.WantType}
+		if _, ok := s.inflight[want]; ok {
+			continue
+		}
+		rctx, cancel := context.WithTimeout(s.ctx, s.opts.SendTimeout)
+		req := &httpRequest{cancel: cancel}
+		s.inflight[want] = req
+		go s.request(rctx, want, e.SendDontHave, req)
+	}
+
+	atomic.AddUint64(&s.bsnet.stats.MessagesSent, 1)
+	return nil
+}
+
+func (s *httpMessageSender) request(ctx context.Context, want httpWant, sendDontHave bool, req *httpRequest) {
+	defer func() {
+		req.cancel()
+		s.lk.Lock()
+		if s.inflight[want] == req {
+			delete(s.inflight, want)
+		}
+		s.lk.Unlock()
+	}()
+
+	select {
+	case s.peer.requests <- struct{}{}:
+		defer func() { <-s.peer.requests }()
+	case <-ctx.Done():
+		return
+	}
+
+	blk, err := s.bsnet.fetch(ctx, s.bsnet.httpURLs(s.peer), want.c, want.wantType == pb.Message_Wantlist_Block)
+	if ctx.Err() != nil {
+		// The want was cancelled or the sender closed.
+		return
+	}
+
+	msg := bsmsg.New(false)
+	switch {
+	case err == nil && blk != nil:
+		msg.AddBlock(blk)
+	case err == nil:
+		msg.AddHave(want.c)
+	default:
+		if !errors.Is(err, errHTTPNotFound) {
+			log.Infof("failed to fetch %s from %s: %s", want.c, s.to, err)
+			s.bsnet.connectEvtMgr.MarkUnresponsive(s.to)
+		}
+		if !sendDontHave {
+			return
+		}
+		msg.AddDontHave(want.c)
+	}
+	s.bsnet.receive(s.to, msg)
+}
+
+// Close cancels the pending requests.
+func (s *httpMessageSender) Close() error {
+	s.cancel()
+	return nil
+}
+
+// Reset cancels the pending requests. The sender can still be used.
+func (s *httpMessageSender) Reset() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for want, req := range s.inflight {
+		req.cancel()
+		delete(s.inflight, want)
+	}
+	return nil
+}
+
+// SupportsHave returns true, as want-have entries are answered by HEAD
+// requests.
+func (s *httpMessageSender) SupportsHave() bool {
+	return true
+}
@@ -0,0 +1,270 @@
+package network_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	tnet "github.com/libp2p/go-libp2p-testing/net"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type httpReceiver struct {
+	messages  chan bsmsg.BitSwapMessage
+	connected chan peer.ID
+}
+
+func (r *httpReceiver) ReceiveMessage(ctx context.Context, sender peer.ID, incoming bsmsg.BitSwapMessage) {
+	r.messages <- incoming
+}
+
+func (r *httpReceiver) ReceiveError(err error) {}
+
+func (r *httpReceiver) PeerConnected(p peer.ID) {
+	r.connected <- p
+}
+
+func (r *httpReceiver) PeerDisconnected(p peer.ID) {}
+
+type providersRouting struct {
+	providers []peer.AddrInfo
+}
+
+func (r *providersRouting) Provide(context.Context, cid.Cid, bool) error {
+	return nil
+}
+
+func (r *providersRouting) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, len(r.providers))
+	for _, p := range r.providers {
+		out <- p
+	}
+	close(out)
+	return out
+}
+
+// testGatewayHandler serves the blocks as a trustless gateway.
+func testGatewayHandler(blks ...blocks.Block) http.Handler {
+	data := make(map[string][]byte)
+	for _, b := range blks {
+		data[b.Cid().String()] = b.RawData()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" || r.Header.Get("Accept") != "application/vnd.ipld.raw" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, ok := data[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+	})
+}
+
+func gatewayAddr(t *testing.T, srv *httptest.Server) ma.Multiaddr {
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := ma.NewMultiaddr("/ip4/" + u.Hostname() + "/tcp/" + u.Port() + "/http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestHTTPNetwork(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	present := blocks.NewBlock([]byte("present"))
+	missing := blocks.NewBlock([]byte("missing"))
+	corrupted := blocks.NewBlock([]byte("corrupted"))
+	// The gateway returns the wrong data for the corrupted block.
+	handler := testGatewayHandler(present, corrupted)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, corrupted.Cid().String()) {
+			_, _ = w.Write([]byte("not the block"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer gateway.Close()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gatewayID := tnet.RandIdentityOrFatal(t).ID()
+	routing := &providersRouting{providers: []peer.AddrInfo{{ID: gatewayID, Addrs: []ma.Multiaddr{gatewayAddr(t, gateway)}}}}
+	network := bsnet.NewFromIpfsHostWithHTTP(h, routing)
+	r := &httpReceiver{
+		messages:  make(chan bsmsg.BitSwapMessage, 10),
+		connected: make(chan peer.ID, 10),
+	}
+	network.Start(r)
+	t.Cleanup(network.Stop)
+
+	var providers []peer.ID
+	for p := range network.FindProvidersAsync(ctx, present.Cid(), 10) {
+		providers = append(providers, p)
+	}
+	if len(providers) != 1 || providers[0] != gatewayID {
+		t.Fatalf("expected the gateway as provider, got %v", providers)
+	}
+
+	if err := network.ConnectTo(ctx, gatewayID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-r.connected:
+		if p != gatewayID {
+			t.Fatal("expected the gateway to be connected")
+		}
+	case <-ctx.Done():
+		t.Fatal("gateway was not connected")
+	}
+
+	sender, err := network.NewMessageSender(ctx, gatewayID, &bsnet.MessageSenderOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if !sender.SupportsHave() {
+		t.Fatal("expected HTTP peers to support HAVE messages")
+	}
+
+	msg := bsmsg.New(false)
+	msg.AddEntry(present.Cid(), 1, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(missing.Cid(), 1, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(corrupted.Cid(), 1, pb.Message_Wantlist_Block, true)
+	if err := sender.SendMsg(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	haves := make(map[cid.Cid]bool)
+	dontHaves := make(map[cid.Cid]bool)
+	for len(haves)+len(dontHaves) < 3 {
+		select {
+		case m := <-r.messages:
+			for _, c := range m.Haves() {
+				haves[c] = true
+			}
+			for _, c := range m.DontHaves() {
+				dontHaves[c] = true
+			}
+		case <-ctx.Done():
+			t.Fatal("did not receive the block presences")
+		}
+	}
+	if !haves[present.Cid()] || !dontHaves[missing.Cid()] || !dontHaves[corrupted.Cid()] {
+		t.Fatalf("unexpected block presences: HAVE %v, DONT_HAVE %v", haves, dontHaves)
+	}
+
+	msg = bsmsg.New(false)
+	msg.AddEntry(present.Cid(), 1, pb.Message_Wantlist_Block, true)
+	if err := sender.SendMsg(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-r.messages:
+		blks := m.Blocks()
+		if len(blks) != 1 || !blks[0].Cid().Equals(present.Cid()) || string(blks[0].RawData()) != "present" {
+			t.Fatalf("expected the block, got %v", blks)
+		}
+	case <-ctx.Done():
+		t.Fatal("did not receive the block")
+	}
+
+	if stats := network.Stats(); stats.MessagesSent != 2 || stats.MessagesRecvd != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestHTTPNetworkMaxRequestsPerPeer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	blks := []blocks.Block{blocks.NewBlock([]byte("first")), blocks.NewBlock([]byte("second"))}
+	handler := testGatewayHandler(blks...)
+	var inflight, maxInflight int32
+	release := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		<-release
+		handler.ServeHTTP(w, r)
+	}))
+	defer gateway.Close()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gatewayID := tnet.RandIdentityOrFatal(t).ID()
+	routing := &providersRouting{providers: []peer.AddrInfo{{ID: gatewayID, Addrs: []ma.Multiaddr{gatewayAddr(t, gateway)}}}}
+	network := bsnet.NewFromIpfsHostWithHTTP(h, routing, bsnet.HTTPMaxRequestsPerPeer(1))
+	r := &httpReceiver{
+		messages:  make(chan bsmsg.BitSwapMessage, 10),
+		connected: make(chan peer.ID, 10),
+	}
+	network.Start(r)
+	t.Cleanup(network.Stop)
+
+	for range network.FindProvidersAsync(ctx, blks[0].Cid(), 10) {
+	}
+
+	// The limit applies across the messages sent to the peer.
+	for _, b := range blks {
+		msg := bsmsg.New(false)
+		msg.AddEntry(b.Cid(), 1, pb.Message_Wantlist_Have, true)
+		if err := network.SendMessage(ctx, gatewayID, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := range blks {
+		select {
+		case m := <-r.messages:
+			if len(m.Haves()) != 1 {
+				t.Fatalf("expected a HAVE, got %v", m.Haves())
+			}
+		case <-ctx.Done():
+			t.Fatalf("did not receive the block presence %d", i)
+		}
+	}
+	if n := atomic.LoadInt32(&maxInflight); n != 1 {
+		t.Fatalf("expected at most 1 concurrent request, got %d", n)
+	}
+}
@@ -39,9 +39,11 @@ var (
 
 // NewFromIpfsHost returns a BitSwapNetwork supported by underlying IPFS host.
 func NewFromIpfsHost(host host.Host, r routing.ContentRouting, opts ...NetOpt) BitSwapNetwork {
-	s := processSettings(opts...)
+	return newImpl(host, r, processSettings(opts...))
+}
 
-	bitswapNetwork := impl{
+func newImpl(host host.Host, r routing.ContentRouting, s Settings) *impl {
+	return &impl{
 		host:    host,
 		routing: r,
 
@@ -52,8 +54,6 @@ func NewFromIpfsHost(host host.Host, r routing.ContentRouting, opts ...NetOpt) B
 
 		supportedProtocols: s.SupportedProtocols,
 	}
-
-	return &bitswapNetwork
 }
 
 func processSettings(opts ...NetOpt) Settings {
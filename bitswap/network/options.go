@@ -1,12 +1,23 @@
 package network
 
-import "github.com/libp2p/go-libp2p/core/protocol"
+import (
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
 
 type NetOpt func(*Settings)
 
 type Settings struct {
 	ProtocolPrefix     protocol.ID
 	SupportedProtocols []protocol.ID
+
+	// HTTPClient is the client used to reach HTTP peers. It is only used by
+	// the network returned by NewFromIpfsHostWithHTTP.
+	HTTPClient *http.Client
+	// HTTPMaxRequestsPerPeer is the maximum number of concurrent requests to
+	// each HTTP peer.
+	HTTPMaxRequestsPerPeer int
 }
 
 func Prefix(prefix protocol.ID) NetOpt {
@@ -20,3 +31,19 @@ func SupportedProtocols(protos []protocol.ID) NetOpt {
 		settings.SupportedProtocols = protos
 	}
 }
+
+// HTTPClient sets the client used to reach HTTP peers. Default is
+// [http.DefaultClient].
+func HTTPClient(client *http.Client) NetOpt {
+	return func(settings *Settings) {
+		settings.HTTPClient = client
+	}
+}
+
+// HTTPMaxRequestsPerPeer sets the maximum number of concurrent requests to
+// each HTTP peer. Default is [DefaultHTTPMaxRequestsPerPeer].
+func HTTPMaxRequestsPerPeer(n int) NetOpt {
+	return func(settings *Settings) {
+		settings.HTTPMaxRequestsPerPeer = n
+	}
+}
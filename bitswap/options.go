@@ -6,6 +6,7 @@ import (
 	"github.com/ipfs/boxo/bitswap/client"
 	"github.com/ipfs/boxo/bitswap/server"
 	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/boxo/denylist"
 	delay "github.com/ipfs/go-ipfs-delay"
 )
 
@@ -29,6 +30,24 @@ func MaxOutstandingBytesPerPeer(count int) Option {
 	return Option{server.MaxOutstandingBytesPerPeer(count)}
 }
 
+// WithBandwidthLimit limits the rate at which the server sends blocks to all
+// the peers together.
+func WithBandwidthLimit(limit server.BandwidthLimit) Option {
+	return Option{server.WithBandwidthLimit(limit)}
+}
+
+// WithPeerBandwidthLimit limits the rate at which the server sends blocks to
+// each peer of the class.
+func WithPeerBandwidthLimit(class string, limit server.BandwidthLimit) Option {
+	return Option{server.WithPeerBandwidthLimit(class, limit)}
+}
+
+// WithPeerClassifier sets the function classifying the peers for the per-peer
+// bandwidth limits of the server.
+func WithPeerClassifier(classify server.PeerClassifier) Option {
+	return Option{server.WithPeerClassifier(classify)}
+}
+
 func MaxQueuedWantlistEntriesPerPeer(count uint) Option {
 	return Option{server.MaxQueuedWantlistEntriesPerPeer(count)}
 }
@@ -55,6 +74,12 @@ func WithPeerBlockRequestFilter(pbrf server.PeerBlockRequestFilter) Option {
 	return Option{server.WithPeerBlockRequestFilter(pbrf)}
 }
 
+// WithDenylist denies requests from peers for blocks blocked by the given
+// denylist.
+func WithDenylist(dl *denylist.Denylist) Option {
+	return Option{server.WithDenylist(dl)}
+}
+
 func WithScoreLedger(scoreLedger server.ScoreLedger) Option {
 	return Option{server.WithScoreLedger(scoreLedger)}
 }
@@ -0,0 +1,86 @@
+package bitswap_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	testinstance "github.com/ipfs/boxo/bitswap/testinstance"
+	tn "github.com/ipfs/boxo/bitswap/testnet"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	delay "github.com/ipfs/go-ipfs-delay"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	recorder := tracer.NewRecorder(&buf)
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+	recordedGen := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithTracer(recorder)})
+	defer recordedGen.Close()
+
+	provider := ig.Next()
+	recorded := recordedGen.Next()
+	if err := recorded.Adapter.ConnectTo(ctx, provider.Peer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replayed blocks are derived from the CIDs, they must be large
+	// enough not to collide.
+	blks := make([]blocks.Block, 5)
+	keys := make([]cid.Cid, len(blks))
+	for i := range blks {
+		blks[i] = blocks.NewBlock(bytes.Repeat([]byte{byte(i)}, 100))
+		addBlock(t, ctx, provider, blks[i])
+		keys[i] = blks[i].Cid()
+	}
+
+	ch, err := recorded.Exchange.NewSession(ctx).GetBlocks(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := tracer.ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent, received int
+	for _, rec := range records {
+		if rec.Peer != provider.Peer {
+			t.Fatalf("unexpected peer %s", rec.Peer)
+		}
+		switch rec.Direction {
+		case tracer.Sent:
+			sent += len(rec.Entries)
+		case tracer.Received:
+			received += len(rec.Blocks)
+		}
+	}
+	if sent == 0 || received != len(blks) {
+		t.Fatalf("expected the wants and the blocks to be recorded, got %d entries and %d blocks", sent, received)
+	}
+
+	replayNet := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	res, err := testinstance.Replay(ctx, replayNet, records, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != len(blks) || res.Stat.BlocksReceived != uint64(len(blks)) {
+		t.Fatalf("expected %d blocks to be replayed, got %+v", len(blks), res)
+	}
+}
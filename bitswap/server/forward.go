@@ -11,4 +11,14 @@ type (
 	TaskInfo               = decision.TaskInfo
 	ScoreLedger            = decision.ScoreLedger
 	ScorePeerFunc          = decision.ScorePeerFunc
+	BandwidthLimit         = decision.BandwidthLimit
+	PeerClassifier         = decision.PeerClassifier
 )
+
+// DefaultPeerClass is the class of the peers that are not classified.
+const DefaultPeerClass = decision.DefaultPeerClass
+
+// TagPeerClassifier returns a PeerClassifier that puts the peers protected or
+// tagged with tag by the connection manager in class, and the other peers in
+// DefaultPeerClass.
+var TagPeerClassifier = decision.TagPeerClassifier
@@ -0,0 +1,271 @@
+package decision
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultPeerClass is the class of the peers that are not classified.
+const DefaultPeerClass = ""
+
+// throughputWindow is the period over which the throughput to each peer is
+// measured.
+const throughputWindow = 10 * time.Second
+
+// BandwidthLimit is a token bucket limit on the bytes of blocks sent.
+type BandwidthLimit struct {
+	// Rate is the sustained rate in bytes per second. Zero means no limit.
+	Rate int
+	// Burst is the number of bytes that can be sent at once after an idle
+	// period. It defaults to Rate.
+	Burst int
+}
+
+// PeerClassifier returns the class of a peer, which selects the bandwidth limit
+// of the peer. The peers of a class without limit are not limited.
+type PeerClassifier func(p peer.ID) string
+
+// TagPeerClassifier returns a PeerClassifier that puts the peers protected or
+// tagged with tag by the connection manager in class, and the other peers in
+// DefaultPeerClass.
+func TagPeerClassifier(cm connmgr.ConnManager, tag, class string) PeerClassifier {
+	return func(p peer.ID) string {
+		if cm.IsProtected(p, tag) {
+			return class
+		}
+		if info := cm.GetTagInfo(p); info != nil {
+			if _, ok := info.Tags[tag]; ok {
+				return class
+			}
+		}
+		return DefaultPeerClass
+	}
+}
+
+// tokenBucket accumulates tokens at a constant rate, up to its burst size.
+// Tokens can be taken before they are available, in which case the bucket goes
+// into debt.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit BandwidthLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+	return &tokenBucket{
+		rate:   float64(limit.Rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes n tokens, and returns how long to wait until the bucket is out
+// of debt.
+func (b *tokenBucket) reserve(now time.Time, n int) time.Duration {
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) inDebt(now time.Time) bool {
+	b.refill(now)
+	return b.tokens < 0
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// rateMeter measures a rate over a sliding window, by weighting the count of
+// the previous window by how much it overlaps the sliding window.
+type rateMeter struct {
+	start     time.Time
+	cur, prev uint64
+}
+
+func (m *rateMeter) roll(now time.Time) {
+	elapsed := now.Sub(m.start)
+	if elapsed < throughputWindow {
+		return
+	}
+	if elapsed < 2*throughputWindow {
+		m.prev = m.cur
+	} else {
+		m.prev = 0
+	}
+	m.cur = 0
+	m.start = m.start.Add(elapsed.Truncate(throughputWindow))
+}
+
+func (m *rateMeter) add(now time.Time, n int) {
+	m.roll(now)
+	m.cur += uint64(n)
+}
+
+// rate returns the rate per second.
+func (m *rateMeter) rate(now time.Time) float64 {
+	m.roll(now)
+	overlap := 1 - float64(now.Sub(m.start))/float64(throughputWindow)
+	return (float64(m.prev)*overlap + float64(m.cur)) / throughputWindow.Seconds()
+}
+
+type peerBandwidth struct {
+	class  string
+	bucket *tokenBucket
+	meter  rateMeter
+}
+
+// bandwidthManager enforces the bandwidth limits, and measures the throughput
+// to each peer.
+type bandwidthManager struct {
+	clock clock.Clock
+
+	global     *tokenBucket
+	peerLimits map[string]BandwidthLimit
+	classify   PeerClassifier
+
+	lk    sync.Mutex
+	peers map[peer.ID]*peerBandwidth
+}
+
+func newBandwidthManager(clk clock.Clock, global BandwidthLimit, peerLimits map[string]BandwidthLimit, classify PeerClassifier) *bandwidthManager {
+	return &bandwidthManager{
+		clock:      clk,
+		global:     newTokenBucket(global, clk.Now()),
+		peerLimits: peerLimits,
+		classify:   classify,
+		peers:      make(map[peer.ID]*peerBandwidth),
+	}
+}
+
+// peer returns the state of p. It must be called with the lock held.
+func (bm *bandwidthManager) peer(p peer.ID, now time.Time) *peerBandwidth {
+	pb, ok := bm.peers[p]
+	if !ok {
+		pb = &peerBandwidth{meter: rateMeter{start: now}}
+		bm.peers[p] = pb
+	}
+	return pb
+}
+
+// waitGlobal takes n tokens from the global bucket, and waits until it is out
+// of debt.
+func (bm *bandwidthManager) waitGlobal(ctx context.Context, n int) error {
+	if bm.global == nil || n == 0 {
+		return nil
+	}
+	bm.lk.Lock()
+	delay := bm.global.reserve(bm.clock.Now(), n)
+	bm.lk.Unlock()
+	return bm.wait(ctx, delay)
+}
+
+func (bm *bandwidthManager) wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := bm.clock.Timer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reservePeer takes n tokens from the bucket of p, and returns how long to wait
+// until it is out of debt.
+func (bm *bandwidthManager) reservePeer(p peer.ID, n int) time.Duration {
+	if len(bm.peerLimits) == 0 || n == 0 {
+		return 0
+	}
+
+	class := DefaultPeerClass
+	if bm.classify != nil {
+		class = bm.classify(p)
+	}
+
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	now := bm.clock.Now()
+	pb := bm.peer(p, now)
+	if pb.bucket == nil || pb.class != class {
+		pb.class = class
+		pb.bucket = newTokenBucket(bm.peerLimits[class], now)
+	}
+	if pb.bucket == nil {
+		return 0
+	}
+	return pb.bucket.reserve(now, n)
+}
+
+// sent records that n bytes of blocks were sent to p.
+func (bm *bandwidthManager) sent(p peer.ID, n int) {
+	if n == 0 {
+		return
+	}
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	now := bm.clock.Now()
+	bm.peer(p, now).meter.add(now, n)
+}
+
+// throughput returns the throughput in bytes per second to the peers blocks
+// were recently sent to.
+func (bm *bandwidthManager) throughput() map[peer.ID]float64 {
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	now := bm.clock.Now()
+	out := make(map[peer.ID]float64, len(bm.peers))
+	for p, pb := range bm.peers {
+		rate := pb.meter.rate(now)
+		if rate > 0 {
+			out[p] = rate
+		} else if pb.bucket == nil || pb.bucket.full(now) {
+			// The peer is idle, forget it.
+			delete(bm.peers, p)
+		}
+	}
+	return out
+}
+
+// peerDisconnected forgets p, unless it is in debt, so that reconnecting does
+// not reset its limit.
+func (bm *bandwidthManager) peerDisconnected(p peer.ID) {
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	if pb, ok := bm.peers[p]; ok && (pb.bucket == nil || !pb.bucket.inDebt(bm.clock.Now())) {
+		delete(bm.peers, p)
+	}
+}
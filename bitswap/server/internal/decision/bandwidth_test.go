@@ -0,0 +1,189 @@
+package decision
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	message "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	process "github.com/jbenet/goprocess"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(BandwidthLimit{Rate: 1000, Burst: 2000}, start)
+
+	if delay := b.reserve(start, 1500); delay != 0 {
+		t.Fatalf("expected the burst to be available, got a delay of %s", delay)
+	}
+	if delay := b.reserve(start, 1500); delay != time.Second {
+		t.Fatalf("expected a delay of 1s, got %s", delay)
+	}
+	if !b.inDebt(start.Add(500 * time.Millisecond)) {
+		t.Fatal("expected the bucket to be in debt")
+	}
+	if b.full(start.Add(2 * time.Second)) {
+		t.Fatal("expected the bucket not to be full")
+	}
+	if !b.full(start.Add(3 * time.Second)) {
+		t.Fatal("expected the bucket to be full")
+	}
+
+	if newTokenBucket(BandwidthLimit{}, start) != nil {
+		t.Fatal("expected no bucket without rate")
+	}
+}
+
+func TestRateMeter(t *testing.T) {
+	start := time.Now()
+	m := rateMeter{start: start}
+
+	m.add(start, 10000)
+	if rate := m.rate(start.Add(time.Second)); rate != 1000 {
+		t.Fatalf("expected a rate of 1000, got %f", rate)
+	}
+	// Half of the previous window overlaps the sliding window.
+	if rate := m.rate(start.Add(throughputWindow + throughputWindow/2)); rate != 500 {
+		t.Fatalf("expected a rate of 500, got %f", rate)
+	}
+	if rate := m.rate(start.Add(3 * throughputWindow)); rate != 0 {
+		t.Fatalf("expected a rate of 0, got %f", rate)
+	}
+}
+
+func TestBandwidthManagerPeerClasses(t *testing.T) {
+	clk := clock.NewMock()
+	slow := libp2ptest.RandPeerIDFatal(t)
+	fast := libp2ptest.RandPeerIDFatal(t)
+	free := libp2ptest.RandPeerIDFatal(t)
+
+	bm := newBandwidthManager(clk, BandwidthLimit{}, map[string]BandwidthLimit{
+		DefaultPeerClass: {Rate: 1000},
+		"fast":           {Rate: 10000},
+	}, func(p peer.ID) string {
+		switch p {
+		case fast:
+			return "fast"
+		case free:
+			return "free"
+		}
+		return DefaultPeerClass
+	})
+
+	for _, p := range []peer.ID{slow, fast, free} {
+		if delay := bm.reservePeer(p, 2000); delay < 0 {
+			t.Fatalf("unexpected delay %s", delay)
+		}
+	}
+	if delay := bm.reservePeer(slow, 1000); delay != 2*time.Second {
+		t.Fatalf("expected a delay of 2s for the default class, got %s", delay)
+	}
+	if delay := bm.reservePeer(fast, 1000); delay != 0 {
+		t.Fatalf("expected no delay for the fast class, got %s", delay)
+	}
+	if delay := bm.reservePeer(free, 1<<20); delay != 0 {
+		t.Fatalf("expected no delay for a class without limit, got %s", delay)
+	}
+
+	// Peers in debt are remembered after they disconnect.
+	bm.peerDisconnected(slow)
+	if delay := bm.reservePeer(slow, 1000); delay != 3*time.Second {
+		t.Fatalf("expected a delay of 3s after reconnecting, got %s", delay)
+	}
+}
+
+func TestBandwidthManagerGlobalLimit(t *testing.T) {
+	clk := clock.NewMock()
+	bm := newBandwidthManager(clk, BandwidthLimit{Rate: 1000}, nil, nil)
+
+	if err := bm.waitGlobal(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bm.waitGlobal(context.Background(), 1000)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected to wait for the global limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	clk.Add(time.Second)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the global limit to be released")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bm.waitGlobal(ctx, 1000); err == nil {
+		t.Fatal("expected an error when the context is cancelled")
+	}
+}
+
+func TestEnginePeerBandwidthLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clk := clock.NewMock()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	blks := []blocks.Block{
+		blocks.NewBlock(make([]byte, 2000)),
+		blocks.NewBlock(append(make([]byte, 1999), 1)),
+	}
+	if err := bs.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+
+	// One block per message, and one active message per peer.
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0,
+		WithTargetMessageSize(1),
+		WithMaxOutstandingBytesPerPeer(1),
+		WithPeerBandwidthLimit(DefaultPeerClass, BandwidthLimit{Rate: 1000}),
+	)
+	e.bandwidth = newBandwidthManager(clk, e.bandwidthLimit, e.peerBandwidthLimit, e.peerClassifier)
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	partner := libp2ptest.RandPeerIDFatal(t)
+	msg := message.New(false)
+	for _, blk := range blks {
+		msg.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, true)
+	}
+	e.MessageReceived(ctx, partner, msg)
+
+	_, env := getNextEnvelope(e, nil, time.Second)
+	if env == nil || len(env.Message.Blocks()) != 1 {
+		t.Fatal("expected an envelope with a block")
+	}
+	e.MessageSent(env.Peer, env.Message)
+	env.Sent()
+
+	if throughput := e.Throughput()[partner]; throughput != 200 {
+		t.Fatalf("expected a throughput of 200 bytes/s, got %f", throughput)
+	}
+
+	// The peer is over its limit for a second.
+	next, env := getNextEnvelope(e, nil, 100*time.Millisecond)
+	if env != nil {
+		t.Fatal("expected the peer to be held back by its bandwidth limit")
+	}
+	clk.Add(time.Second)
+	_, env = getNextEnvelope(e, next, time.Second)
+	if env == nil || len(env.Message.Blocks()) != 1 {
+		t.Fatal("expected an envelope with the second block")
+	}
+}
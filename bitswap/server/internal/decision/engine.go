@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/google/uuid"
 
 	wl "github.com/ipfs/boxo/bitswap/client/wantlist"
@@ -16,6 +17,7 @@ import (
 	pb "github.com/ipfs/boxo/bitswap/message/pb"
 	bmetrics "github.com/ipfs/boxo/bitswap/metrics"
 	bstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/denylist"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -183,12 +185,18 @@ type Engine struct {
 	taskComparator TaskComparator
 
 	peerBlockRequestFilter PeerBlockRequestFilter
+	denylist               *denylist.Denylist
 
 	bstoreWorkerCount          int
 	maxOutstandingBytesPerPeer int
 
 	maxQueuedWantlistEntriesPerPeer uint
 	maxCidSize                      uint
+
+	bandwidthLimit     BandwidthLimit
+	peerBandwidthLimit map[string]BandwidthLimit
+	peerClassifier     PeerClassifier
+	bandwidth          *bandwidthManager
 }
 
 // TaskInfo represents the details of a request from a peer.
@@ -228,6 +236,14 @@ func WithPeerBlockRequestFilter(pbrf PeerBlockRequestFilter) Option {
 	}
 }
 
+// WithDenylist denies requests for blocks blocked by the given denylist, in
+// addition to the requests denied by the [PeerBlockRequestFilter].
+func WithDenylist(dl *denylist.Denylist) Option {
+	return func(e *Engine) {
+		e.denylist = dl
+	}
+}
+
 func WithTargetMessageSize(size int) Option {
 	return func(e *Engine) {
 		e.targetMessageSize = size
@@ -294,6 +310,39 @@ func WithSetSendDontHave(send bool) Option {
 	}
 }
 
+// WithBandwidthLimit limits the rate at which blocks are sent to all the peers
+// together. Task workers wait for the limit before preparing more messages.
+func WithBandwidthLimit(limit BandwidthLimit) Option {
+	return func(e *Engine) {
+		e.bandwidthLimit = limit
+	}
+}
+
+// WithPeerBandwidthLimit limits the rate at which blocks are sent to each peer
+// of the class, as returned by the [PeerClassifier]. Use [DefaultPeerClass] to
+// limit the peers that are not classified.
+//
+// Once a peer is over its limit, its tasks stay active until it is back within
+// it, so that the request queue serves other peers meanwhile: the peer can
+// exceed its limit by up to the maximum outstanding bytes per peer. When that
+// maximum is disabled, task workers wait for the limit instead.
+func WithPeerBandwidthLimit(class string, limit BandwidthLimit) Option {
+	return func(e *Engine) {
+		if e.peerBandwidthLimit == nil {
+			e.peerBandwidthLimit = make(map[string]BandwidthLimit)
+		}
+		e.peerBandwidthLimit[class] = limit
+	}
+}
+
+// WithPeerClassifier sets the function classifying the peers for the per-peer
+// bandwidth limits. By default, every peer is in [DefaultPeerClass].
+func WithPeerClassifier(classify PeerClassifier) Option {
+	return func(e *Engine) {
+		e.peerClassifier = classify
+	}
+}
+
 // wrapTaskComparator wraps a TaskComparator so it can be used as a QueueTaskComparator
 func wrapTaskComparator(tc TaskComparator) peertask.QueueTaskComparator {
 	return func(a, b *peertask.QueueTask) bool {
@@ -373,6 +422,7 @@ func newEngine(
 		opt(e)
 	}
 
+	e.bandwidth = newBandwidthManager(clock.New(), e.bandwidthLimit, e.peerBandwidthLimit, e.peerClassifier)
 	e.bsm = newBlockstoreManager(bs, e.bstoreWorkerCount, bmetrics.PendingBlocksGauge(ctx), bmetrics.ActiveBlocksGauge(ctx))
 
 	// default peer task queue options
@@ -479,6 +529,12 @@ func (e *Engine) LedgerForPeer(p peer.ID) *Receipt {
 	return e.scoreLedger.GetReceipt(p)
 }
 
+// Throughput returns the rate in bytes per second at which blocks were
+// recently sent to each peer.
+func (e *Engine) Throughput() map[peer.ID]float64 {
+	return e.bandwidth.throughput()
+}
+
 // Each taskWorker pulls items off the request queue up to the maximum size
 // and adds them to an envelope that is passed off to the bitswap workers,
 // which send the message to the network.
@@ -594,17 +650,45 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 			continue
 		}
 
+		// Apply the bandwidth limits
+		blocksSize := 0
+		for _, blk := range msg.Blocks() {
+			blocksSize += len(blk.RawData())
+		}
+		if err := e.bandwidth.waitGlobal(ctx, blocksSize); err != nil {
+			return nil, err
+		}
+		peerDelay := e.bandwidth.reservePeer(p, blocksSize)
+		if peerDelay > 0 && e.maxOutstandingBytesPerPeer == 0 {
+			// Keeping the tasks active would not hold back the peer.
+			if err := e.bandwidth.wait(ctx, peerDelay); err != nil {
+				return nil, err
+			}
+			peerDelay = 0
+		}
+		peerReady := e.bandwidth.clock.Now().Add(peerDelay)
+
 		log.Debugw("Bitswap engine -> msg", "local", e.self, "to", p, "blockCount", len(msg.Blocks()), "presenceCount", len(msg.BlockPresences()), "size", msg.Size())
 		return &Envelope{
 			Peer:    p,
 			Message: msg,
 			Sent: func() {
-				// Once the message has been sent, signal the request queue so
-				// it can be cleared from the queue
-				e.peerRequestQueue.TasksDone(p, nextTasks...)
+				tasksDone := func() {
+					// Once the message has been sent, signal the request queue so
+					// it can be cleared from the queue
+					e.peerRequestQueue.TasksDone(p, nextTasks...)
 
-				// Signal the worker to check for more work
-				e.signalNewWork()
+					// Signal the worker to check for more work
+					e.signalNewWork()
+				}
+
+				// Keep the tasks of a peer over its bandwidth limit active
+				// until it is back within it.
+				if delay := peerReady.Sub(e.bandwidth.clock.Now()); delay > 0 {
+					e.bandwidth.clock.AfterFunc(delay, tasksDone)
+					return
+				}
+				tasksDone()
 			},
 		}, nil
 	}
@@ -823,7 +907,7 @@ func (e *Engine) splitWantsCancels(es []bsmsg.Entry) ([]bsmsg.Entry, []bsmsg.Ent
 
 // Split the want-have / want-block entries from the block that will be denied access
 func (e *Engine) splitWantsDenials(p peer.ID, allWants []bsmsg.Entry) ([]bsmsg.Entry, []bsmsg.Entry) {
-	if e.peerBlockRequestFilter == nil {
+	if e.peerBlockRequestFilter == nil && e.denylist == nil {
 		return allWants, nil
 	}
 
@@ -831,7 +915,11 @@ func (e *Engine) splitWantsDenials(p peer.ID, allWants []bsmsg.Entry) ([]bsmsg.E
 	denied := make([]bsmsg.Entry, 0, len(allWants))
 
 	for _, et := range allWants {
-		if e.peerBlockRequestFilter(p, et.Cid) {
+		allowed := !e.denylist.IsCidBlocked(et.Cid)
+		if allowed && e.peerBlockRequestFilter != nil {
+			allowed = e.peerBlockRequestFilter(p, et.Cid)
+		}
+		if allowed {
 			wants = append(wants, et)
 		} else {
 			denied = append(denied, et)
@@ -923,6 +1011,7 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) {
 	// Remove sent blocks from the want list for the peer
 	for _, block := range m.Blocks() {
 		e.scoreLedger.AddToSentBytes(p, len(block.RawData()))
+		e.bandwidth.sent(p, len(block.RawData()))
 		e.peerLedger.CancelWantWithType(p, block.Cid(), pb.Message_Wantlist_Block)
 	}
 
@@ -953,6 +1042,7 @@ func (e *Engine) PeerDisconnected(p peer.ID) {
 
 	e.peerLedger.PeerDisconnected(p)
 	e.scoreLedger.PeerDisconnected(p)
+	e.bandwidth.peerDisconnected(p)
 }
 
 // If the want is a want-have, and it's below a certain size, send the full
@@ -13,10 +13,12 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/bitswap/client/wantlist"
 	"github.com/ipfs/boxo/bitswap/internal/testutil"
 	message "github.com/ipfs/boxo/bitswap/message"
 	pb "github.com/ipfs/boxo/bitswap/message/pb"
 	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/denylist"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
@@ -1732,3 +1734,37 @@ func TestKillConnectionForInlineCid(t *testing.T) {
 		t.Fatal("connection was not killed when receiving inline in cancel")
 	}
 }
+
+func TestDenylist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	allowed := blocks.NewBlock([]byte("allowed"))
+	blocked := blocks.NewBlock([]byte("blocked"))
+	filtered := blocks.NewBlock([]byte("filtered"))
+
+	dl, err := denylist.New(strings.NewReader("/ipfs/" + blocked.Cid().String() + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithDenylist(dl),
+		WithPeerBlockRequestFilter(func(p peer.ID, c cid.Cid) bool {
+			return !filtered.Cid().Equals(c)
+		}),
+	)
+
+	var entries []message.Entry
+	for _, blk := range []blocks.Block{allowed, blocked, filtered} {
+		entries = append(entries, message.Entry{Entry: wantlist.Entry{Cid: blk.Cid()}})
+	}
+
+	wants, denied := e.splitWantsDenials(libp2ptest.RandPeerIDFatal(t), entries)
+	if len(wants) != 1 || !wants[0].Cid.Equals(allowed.Cid()) {
+		t.Fatalf("expected only %s to be wanted, got %v", allowed.Cid(), wants)
+	}
+	if len(denied) != 2 {
+		t.Fatalf("expected 2 denied entries, got %d", len(denied))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ipfs/boxo/bitswap/client/wantlist"
 	"github.com/ipfs/boxo/bitswap/internal/defaults"
 	"github.com/ipfs/boxo/bitswap/message"
 	pb "github.com/ipfs/boxo/bitswap/message/pb"
@@ -16,6 +17,7 @@ import (
 	"github.com/ipfs/boxo/bitswap/server/internal/decision"
 	"github.com/ipfs/boxo/bitswap/tracer"
 	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/denylist"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -145,6 +147,14 @@ func WithPeerBlockRequestFilter(pbrf decision.PeerBlockRequestFilter) Option {
 	}
 }
 
+// WithDenylist denies requests for blocks blocked by the given denylist.
+func WithDenylist(dl *denylist.Denylist) Option {
+	o := decision.WithDenylist(dl)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 // WithTaskComparator configures custom task prioritization logic.
 func WithTaskComparator(comparator decision.TaskComparator) Option {
 	o := decision.WithTaskComparator(comparator)
@@ -212,6 +222,36 @@ func MaxOutstandingBytesPerPeer(count int) Option {
 	}
 }
 
+// WithBandwidthLimit limits the rate at which blocks are sent to all the peers
+// together.
+func WithBandwidthLimit(limit BandwidthLimit) Option {
+	o := decision.WithBandwidthLimit(limit)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
+// WithPeerBandwidthLimit limits the rate at which blocks are sent to each peer
+// of the class, as returned by the [PeerClassifier]. Use [DefaultPeerClass] to
+// limit the peers that are not classified. A peer can exceed its limit by up
+// to [MaxOutstandingBytesPerPeer].
+func WithPeerBandwidthLimit(class string, limit BandwidthLimit) Option {
+	o := decision.WithPeerBandwidthLimit(class, limit)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
+// WithPeerClassifier sets the function classifying the peers for the per-peer
+// bandwidth limits, such as [TagPeerClassifier]. By default, every peer is in
+// [DefaultPeerClass].
+func WithPeerClassifier(classify PeerClassifier) Option {
+	o := decision.WithPeerClassifier(classify)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 // MaxQueuedWantlistEntriesPerPeer limits how much individual entries each peer is allowed to send.
 // If a peer send us more than this we will truncate newest entries.
 // It defaults to defaults.MaxQueuedWantlistEntiresPerPeer.
@@ -253,6 +293,17 @@ func (bs *Server) WantlistForPeer(p peer.ID) []cid.Cid {
 	return out
 }
 
+// WantlistEntriesForPeer returns the currently understood list of blocks
+// requested by a given peer, with their priority and want type.
+func (bs *Server) WantlistEntriesForPeer(p peer.ID) []wantlist.Entry {
+	return bs.engine.WantlistForPeer(p)
+}
+
+// Peers returns the peers with whom the local node has active sessions.
+func (bs *Server) Peers() []peer.ID {
+	return bs.engine.Peers()
+}
+
 func (bs *Server) startWorkers(ctx context.Context, px process.Process) {
 	bs.engine.StartWorkers(ctx, px)
 
@@ -386,6 +437,9 @@ type Stat struct {
 	ProvideBufLen int
 	BlocksSent    uint64
 	DataSent      uint64
+	// PeerThroughput is the rate in bytes per second at which blocks were
+	// recently sent to each peer.
+	PeerThroughput map[string]float64
 }
 
 // Stat returns aggregated statistics about bitswap operations
@@ -403,6 +457,12 @@ func (bs *Server) Stat() (Stat, error) {
 	sort.Strings(peersStr)
 	s.Peers = peersStr
 
+	throughput := bs.engine.Throughput()
+	s.PeerThroughput = make(map[string]float64, len(throughput))
+	for p, rate := range throughput {
+		s.PeerThroughput[p.Pretty()] = rate
+	}
+
 	return s, nil
 }
 
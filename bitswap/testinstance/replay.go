@@ -0,0 +1,215 @@
+package testsession
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	tn "github.com/ipfs/boxo/bitswap/testnet"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ReplayResult is the outcome of a replay.
+type ReplayResult struct {
+	// Blocks is the number of blocks fetched.
+	Blocks int
+	// Duration is the time it took to fetch the blocks.
+	Duration time.Duration
+	// RecordedDuration is the time it took to fetch the blocks in the
+	// recording.
+	RecordedDuration time.Duration
+	// Stat are the statistics of the replaying instance.
+	Stat *bitswap.Stat
+}
+
+// Replay reproduces on net the session recorded by a [tracer.Recorder].
+//
+// The recorded node is replaced by a new instance created with bsOptions, and
+// each recorded peer by an instance holding the blocks the peer sent or had in
+// the recording. Only the CIDs of the blocks are recorded, so each block is
+// replaced by a block of the same size derived from its CID. The new instance
+// fetches in a single session the blocks the recorded node received, wanting
+// each block at the same time, relative to the first record, as in the
+// recording.
+func Replay(ctx context.Context, net tn.Network, records []tracer.Record, bsOptions []bitswap.Option) (*ReplayResult, error) {
+	if len(records) == 0 {
+		return nil, errors.New("no records to replay")
+	}
+	records = append([]tracer.Record(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	start := records[0].Time
+
+	sizes := make(map[cid.Cid]int)
+	wantedAt := make(map[cid.Cid]time.Duration)
+	receivedAt := make(map[cid.Cid]time.Duration)
+	has := make(map[peer.ID]map[cid.Cid]struct{})
+	for _, rec := range records {
+		offset := rec.Time.Sub(start)
+		if _, ok := has[rec.Peer]; !ok {
+			has[rec.Peer] = make(map[cid.Cid]struct{})
+		}
+		for _, b := range rec.Blocks {
+			sizes[b.Cid] = b.Size
+		}
+
+		if rec.Direction == tracer.Sent {
+			for _, e := range rec.Entries {
+				if _, ok := wantedAt[e.Cid]; !ok && !e.Cancel {
+					wantedAt[e.Cid] = offset
+				}
+			}
+			continue
+		}
+		for _, b := range rec.Blocks {
+			has[rec.Peer][b.Cid] = struct{}{}
+			if _, ok := receivedAt[b.Cid]; !ok {
+				receivedAt[b.Cid] = offset
+			}
+		}
+		for _, c := range rec.Haves {
+			has[rec.Peer][c] = struct{}{}
+		}
+	}
+
+	replayBlocks := make(map[cid.Cid]blocks.Block, len(sizes))
+	for c, size := range sizes {
+		replayBlocks[c] = replayBlock(c, size)
+	}
+
+	// Fetch the blocks that were wanted and received.
+	var fetch []cid.Cid
+	for c := range wantedAt {
+		if _, ok := receivedAt[c]; ok {
+			fetch = append(fetch, c)
+		}
+	}
+	if len(fetch) == 0 {
+		return nil, errors.New("no block was wanted and received in the records")
+	}
+	sort.Slice(fetch, func(i, j int) bool { return wantedAt[fetch[i]] < wantedAt[fetch[j]] })
+	first := wantedAt[fetch[0]]
+
+	// Blocks of the same size derived from different CIDs could be the same,
+	// fetch them once.
+	var recordedDuration time.Duration
+	fetched := make(map[cid.Cid]struct{}, len(fetch))
+	wants := fetch[:0]
+	for _, c := range fetch {
+		if d := receivedAt[c] - first; d > recordedDuration {
+			recordedDuration = d
+		}
+		if _, ok := fetched[replayBlocks[c].Cid()]; ok {
+			continue
+		}
+		fetched[replayBlocks[c].Cid()] = struct{}{}
+		wants = append(wants, c)
+	}
+	fetch = wants
+
+	peers := make([]peer.ID, 0, len(has))
+	for p := range has {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+
+	peerGen := NewTestInstanceGenerator(net, nil, nil)
+	defer peerGen.Close()
+	localGen := NewTestInstanceGenerator(net, nil, bsOptions)
+	defer localGen.Close()
+
+	local := localGen.Next()
+	defer local.Exchange.Close()
+	for _, p := range peers {
+		inst := peerGen.Next()
+		defer inst.Exchange.Close()
+		for c := range has[p] {
+			// The size of the blocks that were only announced is unknown.
+			b, ok := replayBlocks[c]
+			if !ok {
+				continue
+			}
+			if err := inst.Blockstore().Put(ctx, b); err != nil {
+				return nil, err
+			}
+		}
+		if err := local.Adapter.ConnectTo(ctx, inst.Peer); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	session := local.Exchange.NewSession(ctx)
+	got := make(chan struct{})
+	begin := time.Now()
+	for i := 0; i < len(fetch); {
+		// Want together the blocks that were wanted together.
+		offset := wantedAt[fetch[i]]
+		j := i + 1
+		for j < len(fetch) && wantedAt[fetch[j]] == offset {
+			j++
+		}
+		keys := make([]cid.Cid, 0, j-i)
+		for _, c := range fetch[i:j] {
+			keys = append(keys, replayBlocks[c].Cid())
+		}
+		i = j
+
+		timer := time.NewTimer(time.Until(begin.Add(offset - first)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		ch, err := session.GetBlocks(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			for range ch {
+				select {
+				case got <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for n := 0; n < len(fetch); n++ {
+		select {
+		case <-got:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	duration := time.Since(begin)
+
+	stat, err := local.Exchange.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayResult{
+		Blocks:           len(fetch),
+		Duration:         duration,
+		RecordedDuration: recordedDuration,
+		Stat:             stat,
+	}, nil
+}
+
+// replayBlock returns a block of the given size, with data derived from c.
+func replayBlock(c cid.Cid, size int) blocks.Block {
+	data := make([]byte, size)
+	seed := sha256.Sum256(c.Bytes())
+	for i := 0; i < size; i += len(seed) {
+		copy(data[i:], seed[:])
+	}
+	return blocks.NewBlock(data)
+}
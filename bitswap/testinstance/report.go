@@ -0,0 +1,95 @@
+package testsession
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Report is the outcome of a fetch by an instance.
+type Report struct {
+	Name string
+	// TimeToFirstBlock is the time until the first block was received.
+	TimeToFirstBlock time.Duration
+	// Duration is the time until the fetch was done.
+	Duration  time.Duration
+	Blocks    uint64
+	DupBlocks uint64
+	// BytesWasted is the size of the duplicate blocks.
+	BytesWasted      uint64
+	MessagesSent     uint64
+	MessagesReceived uint64
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%s: first block %s, done %s, dups %d / %d (%d bytes wasted), sent %d, recv %d",
+		r.Name, r.TimeToFirstBlock, r.Duration, r.DupBlocks, r.Blocks, r.BytesWasted,
+		r.MessagesSent, r.MessagesReceived)
+}
+
+// Measure is a Tracer measuring the time until an instance receives its first
+// block, to build a Report. Pass it to the instance with [bitswap.WithTracer].
+type Measure struct {
+	lk    sync.Mutex
+	start time.Time
+	first time.Time
+}
+
+var _ tracer.Tracer = (*Measure)(nil)
+
+// Start starts measuring a fetch.
+func (m *Measure) Start() {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.start = time.Now()
+	m.first = time.Time{}
+}
+
+func (m *Measure) MessageReceived(_ peer.ID, msg bsmsg.BitSwapMessage) {
+	if len(msg.Blocks()) == 0 {
+		return
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if m.first.IsZero() {
+		m.first = time.Now()
+	}
+}
+
+func (m *Measure) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+// Report returns the report of the fetch by inst since Start, which is done.
+// The counters of inst are not reset by Start, they must be zero when the
+// fetch starts.
+func (m *Measure) Report(name string, inst Instance) (Report, error) {
+	m.lk.Lock()
+	start, first := m.start, m.first
+	m.lk.Unlock()
+
+	st, err := inst.Exchange.Stat()
+	if err != nil {
+		return Report{}, err
+	}
+	nst := inst.Adapter.Stats()
+
+	r := Report{
+		Name:             name,
+		Duration:         time.Since(start),
+		Blocks:           st.BlocksReceived,
+		DupBlocks:        st.DupBlksReceived,
+		BytesWasted:      st.DupDataReceived,
+		MessagesSent:     nst.MessagesSent,
+		MessagesReceived: nst.MessagesRecvd,
+	}
+	if !first.IsZero() {
+		r.TimeToFirstBlock = first.Sub(start)
+	}
+	return r, nil
+}
@@ -0,0 +1,243 @@
+package bitswap
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	delay "github.com/ipfs/go-ipfs-delay"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Link describes the link from a peer to another.
+type Link struct {
+	// Latency is the one-way latency of the messages. It is sampled for each
+	// message, so a distribution models jitter, but the messages are still
+	// delivered in order. Nil means no latency.
+	Latency delay.D
+	// Bandwidth is the capacity of the link in bytes per second. The messages
+	// queue behind each other when the link is busy. Zero means no limit.
+	Bandwidth float64
+	// Loss is the probability for a message to be lost, between 0 and 1. Lost
+	// messages are not delivered, and sending them fails as if the stream was
+	// reset. Like the libp2p ones, message senders send them again, up to
+	// the MaxRetries of their options.
+	Loss float64
+}
+
+// errMessageLost is returned when sending a message lost on its link.
+var errMessageLost = errors.New("message lost on the link")
+
+// Topology returns the link from a peer to another. It is called once for
+// each direction of each pair of peers, on the first message between them.
+type Topology func(from, to peer.ID) Link
+
+// UniformTopology returns a Topology where all the links are link.
+func UniformTopology(link Link) Topology {
+	return func(peer.ID, peer.ID) Link {
+		return link
+	}
+}
+
+// ChurnNetwork is a Network where peers can leave and join again.
+type ChurnNetwork interface {
+	Network
+
+	// Leave disconnects p from its peers. Messages to and from p are lost
+	// until it joins again.
+	Leave(p peer.ID) error
+	// Join brings back p, and connects it to the peers it was connected to
+	// when it left and to peers.
+	Join(p peer.ID, peers ...peer.ID) error
+}
+
+// TopologyVirtualNetwork generates a testnet instance where the messages go
+// through the links of topology, and peers can leave and join. rng is the
+// source of the message losses, nil means a shared source.
+func TopologyVirtualNetwork(rs mockrouting.Server, topology Topology, rng *rand.Rand) ChurnNetwork {
+	if rng == nil {
+		rng = sharedRNG
+	}
+	n := VirtualNetwork(rs, delay.Fixed(0)).(*network)
+	n.topology = topology
+	n.links = make(map[peer.ID]map[peer.ID]*linkState)
+	n.rng = rng
+	return n
+}
+
+// linkState is the state of a link in a topology.
+type linkState struct {
+	Link
+	// busyUntil is when the last message is done being transmitted.
+	busyUntil time.Time
+	// lastArrival is when the last message arrives.
+	lastArrival time.Time
+}
+
+// schedule returns when a message of size bytes sent at now arrives, or false
+// if it is lost.
+func (ls *linkState) schedule(now time.Time, size int, rng *rand.Rand) (time.Time, bool) {
+	if ls.Loss > 0 && rng.Float64() < ls.Loss {
+		return time.Time{}, false
+	}
+
+	sent := now
+	if ls.Bandwidth > 0 {
+		if ls.busyUntil.After(sent) {
+			sent = ls.busyUntil
+		}
+		sent = sent.Add(time.Duration(float64(size) / ls.Bandwidth * float64(time.Second)))
+		ls.busyUntil = sent
+	}
+
+	arrival := sent
+	if ls.Latency != nil {
+		arrival = arrival.Add(ls.Latency.NextWaitTime())
+	}
+	if arrival.Before(ls.lastArrival) {
+		arrival = ls.lastArrival
+	}
+	ls.lastArrival = arrival
+	return arrival, true
+}
+
+// link returns the state of the link from a peer to another. It must be called
+// with the lock held.
+func (n *network) link(from, to peer.ID) *linkState {
+	links, ok := n.links[from]
+	if !ok {
+		links = make(map[peer.ID]*linkState)
+		n.links[from] = links
+	}
+	ls, ok := links[to]
+	if !ok {
+		ls = &linkState{Link: n.topology(from, to)}
+		links[to] = ls
+	}
+	return ls
+}
+
+// isOffline returns whether p left the network. It must be called with the lock
+// held.
+func (n *network) isOffline(p peer.ID) bool {
+	_, ok := n.offline[p]
+	return ok
+}
+
+// isGone returns whether a message from a peer to another can't be delivered
+// because one of them left the network.
+func (n *network) isGone(from, to peer.ID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.isOffline(from) || n.isOffline(to)
+}
+
+func (n *network) Leave(p peer.ID) error {
+	n.mu.Lock()
+	client, ok := n.clients[p]
+	if !ok {
+		n.mu.Unlock()
+		return errors.New("no such peer in network")
+	}
+	if n.isOffline(p) {
+		n.mu.Unlock()
+		return nil
+	}
+
+	var peers []peer.ID
+	for q := range n.clients {
+		tag := tagForPeers(p, q)
+		if _, ok := n.conns[tag]; ok {
+			delete(n.conns, tag)
+			peers = append(peers, q)
+		}
+	}
+	if n.offline == nil {
+		n.offline = make(map[peer.ID][]peer.ID)
+	}
+	n.offline[p] = peers
+
+	// The links start afresh when the peer joins again.
+	delete(n.links, p)
+	for _, links := range n.links {
+		delete(links, p)
+	}
+	others := make([]*networkClient, len(peers))
+	for i, q := range peers {
+		others[i] = n.clients[q].receiver
+	}
+	n.mu.Unlock()
+
+	for i, other := range others {
+		other.PeerDisconnected(p)
+		client.receiver.PeerDisconnected(peers[i])
+	}
+	return nil
+}
+
+func (n *network) Join(p peer.ID, peers ...peer.ID) error {
+	n.mu.Lock()
+	client, ok := n.clients[p]
+	if !ok {
+		n.mu.Unlock()
+		return errors.New("no such peer in network")
+	}
+	previous := n.offline[p]
+	delete(n.offline, p)
+	n.mu.Unlock()
+
+	for _, q := range previous {
+		// The previous peers may have left in the meantime.
+		_ = client.receiver.ConnectTo(context.Background(), q)
+	}
+	for _, q := range peers {
+		if err := client.receiver.ConnectTo(context.Background(), q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChurnEvent is a peer leaving or joining a ChurnNetwork.
+type ChurnEvent struct {
+	// At is the time of the event, relative to the start of the script.
+	At   time.Duration
+	Peer peer.ID
+	// Leave is true when the peer leaves, and false when it joins.
+	Leave bool
+	// Connect are the peers the peer connects to when it joins, in addition to
+	// the peers it was connected to when it left.
+	Connect []peer.ID
+}
+
+// RunChurn applies the events of script to n at their time, until all the
+// events are applied or ctx is done.
+func RunChurn(ctx context.Context, n ChurnNetwork, script []ChurnEvent) error {
+	script = append([]ChurnEvent(nil), script...)
+	sort.SliceStable(script, func(i, j int) bool { return script[i].At < script[j].At })
+
+	start := time.Now()
+	for _, ev := range script {
+		timer := time.NewTimer(time.Until(start.Add(ev.At)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		var err error
+		if ev.Leave {
+			err = n.Leave(ev.Peer)
+		} else {
+			err = n.Join(ev.Peer, ev.Connect...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package bitswap
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	blocks "github.com/ipfs/go-block-format"
+	delay "github.com/ipfs/go-ipfs-delay"
+	tnet "github.com/libp2p/go-libp2p-testing/net"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestLinkSchedule(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	ls := &linkState{Link: Link{Latency: delay.Fixed(10 * time.Millisecond), Bandwidth: 1000}}
+
+	arrival, ok := ls.schedule(now, 500, rng)
+	if !ok || !arrival.Equal(now.Add(510*time.Millisecond)) {
+		t.Fatalf("expected the message to arrive after 510ms, got %s", arrival.Sub(now))
+	}
+	// The second message waits for the first one to be transmitted.
+	arrival, ok = ls.schedule(now, 500, rng)
+	if !ok || !arrival.Equal(now.Add(1010*time.Millisecond)) {
+		t.Fatalf("expected the message to arrive after 1010ms, got %s", arrival.Sub(now))
+	}
+
+	// Messages are delivered in order, even if the latency decreases.
+	ls = &linkState{Link: Link{Latency: delay.Fixed(time.Second)}}
+	first, _ := ls.schedule(now, 1, rng)
+	ls.Latency = delay.Fixed(0)
+	if second, _ := ls.schedule(now, 1, rng); second.Before(first) {
+		t.Fatal("expected the messages to be delivered in order")
+	}
+
+	ls = &linkState{Link: Link{Loss: 1}}
+	if _, ok := ls.schedule(now, 1, rng); ok {
+		t.Fatal("expected the message to be lost")
+	}
+}
+
+func TestLeaveAndJoin(t *testing.T) {
+	net := TopologyVirtualNetwork(mockrouting.NewServer(), UniformTopology(Link{}), nil)
+	senderPeer := tnet.RandIdentityOrFatal(t)
+	receiverPeer := tnet.RandIdentityOrFatal(t)
+	sender := net.Adapter(senderPeer)
+	receiver := net.Adapter(receiverPeer)
+
+	received := make(chan struct{}, 1)
+	receiver.Start(lambda(func(context.Context, peer.ID, bsmsg.BitSwapMessage) {
+		received <- struct{}{}
+	}))
+	t.Cleanup(receiver.Stop)
+
+	ctx := context.Background()
+	if err := sender.ConnectTo(ctx, receiverPeer.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(true)
+	msg.AddBlock(blocks.NewBlock([]byte("data")))
+
+	if err := net.Leave(receiverPeer.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.SendMessage(ctx, receiverPeer.ID(), msg); err == nil {
+		t.Fatal("expected an error sending to a peer that left")
+	}
+	if err := sender.ConnectTo(ctx, receiverPeer.ID()); err == nil {
+		t.Fatal("expected an error connecting to a peer that left")
+	}
+
+	if err := net.Join(receiverPeer.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.SendMessage(ctx, receiverPeer.ID(), msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("expected the message to be received after joining")
+	}
+}
+
+func TestLostMessages(t *testing.T) {
+	net := TopologyVirtualNetwork(mockrouting.NewServer(), UniformTopology(Link{Loss: 1}), nil)
+	senderPeer := tnet.RandIdentityOrFatal(t)
+	receiverPeer := tnet.RandIdentityOrFatal(t)
+	sender := net.Adapter(senderPeer)
+	receiver := net.Adapter(receiverPeer)
+	receiver.Start(lambda(func(context.Context, peer.ID, bsmsg.BitSwapMessage) {
+		t.Error("expected the message to be lost")
+	}))
+	t.Cleanup(receiver.Stop)
+
+	ctx := context.Background()
+	if err := sender.ConnectTo(ctx, receiverPeer.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(true)
+	msg.AddBlock(blocks.NewBlock([]byte("data")))
+	if err := sender.SendMessage(ctx, receiverPeer.ID(), msg); err == nil {
+		t.Fatal("expected an error sending a lost message")
+	}
+
+	ms, err := sender.NewMessageSender(ctx, receiverPeer.ID(), &bsnet.MessageSenderOpts{MaxRetries: 3, SendErrorBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := ms.SendMsg(ctx, msg); err == nil {
+		t.Fatal("expected an error sending a lost message")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected the message to be sent again, gave up after %s", elapsed)
+	}
+}
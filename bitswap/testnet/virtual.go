@@ -3,6 +3,7 @@ package bitswap
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -68,6 +69,14 @@ type network struct {
 	isRateLimited      bool
 	rateLimitGenerator RateLimitGenerator
 	conns              map[string]struct{}
+
+	// set by TopologyVirtualNetwork
+	topology Topology
+	links    map[peer.ID]map[peer.ID]*linkState
+	rng      *rand.Rand
+
+	// offline are the peers that left, with the peers they were connected to
+	offline map[peer.ID][]peer.ID
 }
 
 type message struct {
@@ -133,6 +142,13 @@ func (n *network) SendMessage(
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if n.isOffline(from) || n.isOffline(to) {
+		return errors.New("peer left the network")
+	}
+	if n.topology != nil {
+		return n.sendOverLink(from, to, mes)
+	}
+
 	latencies, ok := n.latencies[from]
 	if !ok {
 		latencies = make(map[peer.ID]time.Duration)
@@ -183,6 +199,35 @@ func (n *network) SendMessage(
 	return nil
 }
 
+// sendOverLink sends a message over the link of the topology from a peer to
+// another. It must be called with the lock held.
+func (n *network) sendOverLink(from, to peer.ID, mes bsmsg.BitSwapMessage) error {
+	receiver, ok := n.clients[to]
+	if !ok {
+		return errors.New("cannot locate peer on network")
+	}
+
+	now := time.Now()
+	arrival, ok := n.link(from, to).schedule(now, mes.ToProtoV1().Size(), n.rng)
+	if !ok {
+		return errMessageLost
+	}
+
+	latencies, ok := n.latencies[from]
+	if !ok {
+		latencies = make(map[peer.ID]time.Duration)
+		n.latencies[from] = latencies
+	}
+	latencies[to] = arrival.Sub(now)
+
+	receiver.enqueue(&message{
+		from:       from,
+		msg:        mes,
+		shouldSend: arrival,
+	})
+	return nil
+}
+
 var _ bsnet.Receiver = (*networkClient)(nil)
 
 type networkClient struct {
@@ -283,10 +328,23 @@ type messagePasser struct {
 	target peer.ID
 	local  peer.ID
 	ctx    context.Context
+	opts   *bsnet.MessageSenderOpts
 }
 
 func (mp *messagePasser) SendMsg(ctx context.Context, m bsmsg.BitSwapMessage) error {
-	return mp.net.SendMessage(ctx, mp.target, m)
+	// Lost messages are sent again, as libp2p senders do on stream resets.
+	for i := 1; ; i++ {
+		err := mp.net.SendMessage(ctx, mp.target, m)
+		if !errors.Is(err, errMessageLost) || i >= mp.opts.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mp.opts.SendErrorBackoff):
+		}
+	}
 }
 
 func (mp *messagePasser) Close() error {
@@ -314,11 +372,15 @@ func (mp *messagePasser) SupportsHave() bool {
 }
 
 func (nc *networkClient) NewMessageSender(ctx context.Context, p peer.ID, opts *bsnet.MessageSenderOpts) (bsnet.MessageSender, error) {
+	if opts == nil {
+		opts = &bsnet.MessageSenderOpts{}
+	}
 	return &messagePasser{
 		net:    nc,
 		target: p,
 		local:  nc.local,
 		ctx:    ctx,
+		opts:   opts,
 	}, nil
 }
 
@@ -341,6 +403,10 @@ func (nc *networkClient) ConnectTo(_ context.Context, p peer.ID) error {
 		nc.network.mu.Unlock()
 		return errors.New("no such peer in network")
 	}
+	if nc.network.isOffline(nc.local) || nc.network.isOffline(p) {
+		nc.network.mu.Unlock()
+		return errors.New("peer left the network")
+	}
 
 	tag := tagForPeers(nc.local, p)
 	if _, ok := nc.network.conns[tag]; ok {
@@ -413,6 +479,10 @@ func (rq *receiverQueue) process() {
 			rq.queue = rq.queue[1:]
 			rq.lk.Unlock()
 			time.Sleep(time.Until(m.shouldSend))
+			if rq.receiver.network.isGone(m.from, rq.receiver.local) {
+				// The message was in flight when a peer left
+				continue
+			}
 			atomic.AddUint64(&rq.receiver.stats.MessagesRecvd, 1)
 			rq.receiver.ReceiveMessage(context.TODO(), m.from, m.msg)
 		} else {
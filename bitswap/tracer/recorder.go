@@ -0,0 +1,188 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Direction is the direction of a recorded message.
+type Direction string
+
+const (
+	// Received is the direction of the messages received from a peer.
+	Received Direction = "in"
+	// Sent is the direction of the messages sent to a peer.
+	Sent Direction = "out"
+)
+
+// RecordEntry is a wantlist entry of a recorded message.
+type RecordEntry struct {
+	Cid      cid.Cid `json:"c"`
+	Priority int32   `json:"p,omitempty"`
+	// Have is true for want-have entries, and false for want-block entries.
+	Have         bool `json:"h,omitempty"`
+	Cancel       bool `json:"x,omitempty"`
+	SendDontHave bool `json:"d,omitempty"`
+}
+
+// RecordBlock is a block of a recorded message. Only the CID and the size of
+// the block are recorded.
+type RecordBlock struct {
+	Cid  cid.Cid `json:"c"`
+	Size int     `json:"s"`
+}
+
+// Record is a message sent or received by Bitswap.
+type Record struct {
+	Time      time.Time     `json:"t"`
+	Peer      peer.ID       `json:"p"`
+	Direction Direction     `json:"d"`
+	Full      bool          `json:"f,omitempty"`
+	Entries   []RecordEntry `json:"e,omitempty"`
+	Blocks    []RecordBlock `json:"b,omitempty"`
+	Haves     []cid.Cid     `json:"h,omitempty"`
+	DontHaves []cid.Cid     `json:"n,omitempty"`
+}
+
+// NewRecord returns the record of msg, sent to or received from p at t.
+func NewRecord(t time.Time, p peer.ID, d Direction, msg bsmsg.BitSwapMessage) Record {
+	rec := Record{
+		Time:      t,
+		Peer:      p,
+		Direction: d,
+		Full:      msg.Full(),
+		Haves:     msg.Haves(),
+		DontHaves: msg.DontHaves(),
+	}
+	for _, e := range msg.Wantlist() {
+		rec.Entries = append(rec.Entries, RecordEntry{
+			Cid:          e.Cid,
+			Priority:     e.Priority,
+			Have:         e.WantType == pb.Message_Wantlist_Have,
+			Cancel:       e.Cancel,
+			SendDontHave: e.SendDontHave,
+		})
+	}
+	for _, b := range msg.Blocks() {
+		rec.Blocks = append(rec.Blocks, RecordBlock{
+			Cid:  b.Cid(),
+			Size: len(b.RawData()),
+		})
+	}
+	return rec
+}
+
+// Recorder is a Tracer that writes a record of each message as a line of JSON,
+// to be read back with ReadRecords.
+type Recorder struct {
+	lk     sync.Mutex
+	w      *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+	err    error
+}
+
+var _ Tracer = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder writing to w. Writes are buffered, call Flush
+// or Close to write the buffered records.
+func NewRecorder(w io.Writer) *Recorder {
+	bw := bufio.NewWriter(w)
+	r := &Recorder{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+	}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	return r
+}
+
+// NewFileRecorder returns a Recorder writing to the file at path, which is
+// truncated if it exists. Close closes the file.
+func NewFileRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f), nil
+}
+
+func (r *Recorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(NewRecord(time.Now(), p, Received, msg))
+}
+
+func (r *Recorder) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(NewRecord(time.Now(), p, Sent, msg))
+}
+
+func (r *Recorder) record(rec Record) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	// Stop recording after the first error, the log would be incomplete.
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(rec)
+}
+
+// Flush writes the buffered records. It returns the first error that occurred
+// while recording.
+func (r *Recorder) Flush() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+// Close flushes the buffered records, and closes the underlying writer if it
+// is an io.Closer. No records are written after Close.
+func (r *Recorder) Close() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	err := r.err
+	if err == nil {
+		err = r.w.Flush()
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("recorder closed")
+	}
+	return err
+}
+
+// ReadRecords reads the records written by a Recorder.
+func ReadRecords(rd io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(rd)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
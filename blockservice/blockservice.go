@@ -5,6 +5,7 @@ package blockservice
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"sync"
 
@@ -12,6 +13,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/denylist"
 	"github.com/ipfs/boxo/exchange"
 	"github.com/ipfs/boxo/verifcid"
 	blocks "github.com/ipfs/go-block-format"
@@ -73,6 +75,7 @@ type BoundedBlockService interface {
 
 type blockService struct {
 	allowlist  verifcid.Allowlist
+	denylist   *denylist.Denylist
 	blockstore blockstore.Blockstore
 	exchange   exchange.Interface
 	// If checkFirst is true then first check that a block doesn't
@@ -97,6 +100,14 @@ func WithAllowlist(allowlist verifcid.Allowlist) Option {
 	}
 }
 
+// WithDenylist sets a [denylist.Denylist] which is used to refuse getting,
+// fetching and adding blocked blocks.
+func WithDenylist(dl *denylist.Denylist) Option {
+	return func(bs *blockService) {
+		bs.denylist = dl
+	}
+}
+
 // New creates a BlockService with given datastore instance.
 func New(bs blockstore.Blockstore, exchange exchange.Interface, opts ...Option) BlockService {
 	if exchange == nil {
@@ -139,6 +150,25 @@ func (s *blockService) Allowlist() verifcid.Allowlist {
 	return s.allowlist
 }
 
+// Denylist returns the denylist used by this blockservice, if any.
+func (s *blockService) Denylist() *denylist.Denylist {
+	return s.denylist
+}
+
+// denylistedBlockService is implemented by blockservices created with the
+// [WithDenylist] option.
+type denylistedBlockService interface {
+	Denylist() *denylist.Denylist
+}
+
+// checkDenylist returns an error wrapping [denylist.ErrBlocked] if c is blocked.
+func checkDenylist(dl *denylist.Denylist, c cid.Cid) error {
+	if dl.IsCidBlocked(c) {
+		return fmt.Errorf("%s: %w", c, denylist.ErrBlocked)
+	}
+	return nil
+}
+
 // NewSession creates a new session that allows for
 // controlled exchange of wantlists to decrease the bandwidth overhead.
 // If the current exchange is a SessionExchange, a new exchange
@@ -149,10 +179,15 @@ func NewSession(ctx context.Context, bs BlockService) *Session {
 	if bbs, ok := bs.(BoundedBlockService); ok {
 		allowlist = bbs.Allowlist()
 	}
+	var dl *denylist.Denylist
+	if dbs, ok := bs.(denylistedBlockService); ok {
+		dl = dbs.Denylist()
+	}
 	exch := bs.Exchange()
 	if sessEx, ok := exch.(exchange.SessionExchange); ok {
 		return &Session{
 			allowlist: allowlist,
+			denylist:  dl,
 			sessCtx:   ctx,
 			ses:       nil,
 			sessEx:    sessEx,
@@ -162,6 +197,7 @@ func NewSession(ctx context.Context, bs BlockService) *Session {
 	}
 	return &Session{
 		allowlist: allowlist,
+		denylist:  dl,
 		ses:       exch,
 		sessCtx:   ctx,
 		bs:        bs.Blockstore(),
@@ -179,6 +215,9 @@ func (s *blockService) AddBlock(ctx context.Context, o blocks.Block) error {
 	if err != nil {
 		return err
 	}
+	if err := checkDenylist(s.denylist, c); err != nil {
+		return err
+	}
 	if s.checkFirst {
 		if has, err := s.blockstore.Has(ctx, c); has || err != nil {
 			return err
@@ -210,6 +249,9 @@ func (s *blockService) AddBlocks(ctx context.Context, bs []blocks.Block) error {
 		if err != nil {
 			return err
 		}
+		if err := checkDenylist(s.denylist, b.Cid()); err != nil {
+			return err
+		}
 	}
 	var toput []blocks.Block
 	if s.checkFirst {
@@ -256,18 +298,21 @@ func (s *blockService) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, e
 		f = s.getExchange
 	}
 
-	return getBlock(ctx, c, s.blockstore, s.allowlist, f)
+	return getBlock(ctx, c, s.blockstore, s.allowlist, s.denylist, f)
 }
 
 func (s *blockService) getExchange() notifiableFetcher {
 	return s.exchange
 }
 
-func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, allowlist verifcid.Allowlist, fget func() notifiableFetcher) (blocks.Block, error) {
+func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, allowlist verifcid.Allowlist, dl *denylist.Denylist, fget func() notifiableFetcher) (blocks.Block, error) {
 	err := verifcid.ValidateCid(allowlist, c) // hash security
 	if err != nil {
 		return nil, err
 	}
+	if err := checkDenylist(dl, c); err != nil {
+		return nil, err
+	}
 
 	block, err := bs.Get(ctx, c)
 	if err == nil {
@@ -313,10 +358,10 @@ func (s *blockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan block
 		f = s.getExchange
 	}
 
-	return getBlocks(ctx, ks, s.blockstore, s.allowlist, f)
+	return getBlocks(ctx, ks, s.blockstore, s.allowlist, s.denylist, f)
 }
 
-func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, allowlist verifcid.Allowlist, fget func() notifiableFetcher) <-chan blocks.Block {
+func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, allowlist verifcid.Allowlist, dl *denylist.Denylist, fget func() notifiableFetcher) <-chan blocks.Block {
 	out := make(chan blocks.Block)
 
 	go func() {
@@ -328,6 +373,10 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, allo
 				allValid = false
 				break
 			}
+			if err := checkDenylist(dl, c); err != nil {
+				allValid = false
+				break
+			}
 		}
 
 		if !allValid {
@@ -335,10 +384,12 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, allo
 			ks2 := make([]cid.Cid, 0, len(ks))
 			for _, c := range ks {
 				// hash security
-				if err := verifcid.ValidateCid(allowlist, c); err == nil {
-					ks2 = append(ks2, c)
-				} else {
+				if err := verifcid.ValidateCid(allowlist, c); err != nil {
 					logger.Errorf("unsafe CID (%s) passed to blockService.GetBlocks: %s", c, err)
+				} else if err := checkDenylist(dl, c); err != nil {
+					logger.Debugf("blocked CID (%s) passed to blockService.GetBlocks", c)
+				} else {
+					ks2 = append(ks2, c)
 				}
 			}
 			ks = ks2
@@ -432,6 +483,7 @@ type notifier interface {
 // Session is a helper type to provide higher level access to bitswap sessions
 type Session struct {
 	allowlist verifcid.Allowlist
+	denylist  *denylist.Denylist
 	bs        blockstore.Blockstore
 	ses       exchange.Fetcher
 	sessEx    exchange.SessionExchange
@@ -480,7 +532,7 @@ func (s *Session) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error)
 	ctx, span := internal.StartSpan(ctx, "Session.GetBlock", trace.WithAttributes(attribute.Stringer("CID", c)))
 	defer span.End()
 
-	return getBlock(ctx, c, s.bs, s.allowlist, s.getFetcherFactory())
+	return getBlock(ctx, c, s.bs, s.allowlist, s.denylist, s.getFetcherFactory())
 }
 
 // GetBlocks gets blocks in the context of a request session
@@ -488,7 +540,7 @@ func (s *Session) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Blo
 	ctx, span := internal.StartSpan(ctx, "Session.GetBlocks")
 	defer span.End()
 
-	return getBlocks(ctx, ks, s.bs, s.allowlist, s.getFetcherFactory())
+	return getBlocks(ctx, ks, s.bs, s.allowlist, s.denylist, s.getFetcherFactory())
 }
 
 var _ BlockGetter = (*Session)(nil)
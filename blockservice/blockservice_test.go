@@ -2,9 +2,11 @@ package blockservice
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/denylist"
 	exchange "github.com/ipfs/boxo/exchange"
 	offline "github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/verifcid"
@@ -288,3 +290,42 @@ func TestAllowlist(t *testing.T) {
 	check(blockservice.GetBlock)
 	check(NewSession(ctx, blockservice).GetBlock)
 }
+
+func TestDenylist(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bgen := butil.NewBlockGenerator()
+	blocked := bgen.Next()
+	allowed := bgen.Next()
+
+	dl, err := denylist.New(strings.NewReader("/ipfs/" + blocked.Cid().String()))
+	a.NoError(err)
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	a.NoError(bs.PutMany(ctx, []blocks.Block{blocked, allowed}))
+
+	check := func(getBlock func(context.Context, cid.Cid) (blocks.Block, error)) {
+		_, err := getBlock(ctx, blocked.Cid())
+		a.ErrorIs(err, denylist.ErrBlocked)
+
+		_, err = getBlock(ctx, allowed.Cid())
+		a.NoError(err)
+	}
+
+	blockservice := New(bs, nil, WithDenylist(dl))
+	check(blockservice.GetBlock)
+	check(NewSession(ctx, blockservice).GetBlock)
+
+	var got []cid.Cid
+	for blk := range blockservice.GetBlocks(ctx, []cid.Cid{blocked.Cid(), allowed.Cid()}) {
+		got = append(got, blk.Cid())
+	}
+	a.Equal([]cid.Cid{allowed.Cid()}, got)
+
+	a.ErrorIs(blockservice.AddBlock(ctx, blocked), denylist.ErrBlocked)
+}
@@ -0,0 +1,388 @@
+// Package denylist implements content blocking based on a list of CIDs, paths,
+// IPNS names and DNSLink names.
+//
+// A denylist is a text file with one rule per line. Empty lines and lines
+// starting with '#' are ignored. If the file contains a line with only "---",
+// everything before it is treated as a header and ignored. Rules can be:
+//
+//   - /ipfs/{cid}: blocks the CID, regardless of its version and codec.
+//   - /ipfs/{cid}/sub/path: blocks the given path and everything under it.
+//   - /ipns/{name}: blocks an IPNS name, which can be a libp2p key or a DNSLink
+//     name. A sub path can be given, like for /ipfs/ rules.
+//   - //{hash}: blocks content based on its double hash. The hash is the
+//     SHA2-256 digest of "{cidv1}/{path}" for /ipfs/ content, where {cidv1}
+//     is the base32 CIDv1 and {path} is empty for the root, or "{name}/{path}"
+//     for /ipns/ content. It can be given as a hex-encoded digest, which is the
+//     legacy badbits format, or as a base58btc-encoded SHA2-256 multihash.
+package denylist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("denylist")
+
+// ErrBlocked is returned when content is blocked by a [Denylist].
+var ErrBlocked = errors.New("content is blocked")
+
+// DefaultReloadInterval is the default interval at which a [Denylist] created
+// with [NewFromFile] checks whether the file changed.
+const DefaultReloadInterval = 10 * time.Second
+
+// Denylist holds a set of rules used to decide whether content is blocked. All
+// methods are safe for concurrent use, and can be called on a nil *Denylist,
+// which blocks nothing.
+type Denylist struct {
+	mu    sync.RWMutex
+	rules *rules
+
+	filename       string
+	reloadInterval time.Duration
+	modTime        time.Time
+	size           int64
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	closed    chan struct{}
+}
+
+// rules is an immutable set of parsed rules. Paths are stored as a list of
+// blocked sub paths per CID multihash or IPNS name, where an empty sub path
+// blocks everything.
+type rules struct {
+	cids   map[string][]string
+	names  map[string][]string
+	hashes map[[sha256.Size]byte]struct{}
+}
+
+// Option configures a [Denylist] created with [NewFromFile].
+type Option func(*Denylist) error
+
+// WithReloadInterval sets the interval at which the file is checked for
+// changes. A zero interval disables reloading.
+func WithReloadInterval(interval time.Duration) Option {
+	return func(d *Denylist) error {
+		if interval < 0 {
+			return fmt.Errorf("reload interval must not be negative, got %s", interval)
+		}
+		d.reloadInterval = interval
+		return nil
+	}
+}
+
+// New creates a [Denylist] from the rules read from r.
+func New(r io.Reader) (*Denylist, error) {
+	rs, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Denylist{rules: rs}, nil
+}
+
+// NewFromFile creates a [Denylist] from the rules in the given file. The file
+// is reloaded when its modification time or size change. If the file cannot be
+// parsed during a reload, the previous rules are kept. [Denylist.Close] must be
+// called to stop watching the file.
+func NewFromFile(filename string, opts ...Option) (*Denylist, error) {
+	d := &Denylist{
+		filename:       filename,
+		reloadInterval: DefaultReloadInterval,
+		closing:        make(chan struct{}),
+		closed:         make(chan struct{}),
+	}
+	for _, o := range opts {
+		if err := o(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := d.reload(); err != nil {
+		return nil, err
+	}
+
+	if d.reloadInterval > 0 {
+		go d.watch()
+	} else {
+		close(d.closed)
+	}
+	return d, nil
+}
+
+// Close stops watching the file the denylist was created from.
+func (d *Denylist) Close() error {
+	if d == nil || d.closing == nil {
+		return nil
+	}
+	d.closeOnce.Do(func() {
+		close(d.closing)
+	})
+	<-d.closed
+	return nil
+}
+
+func (d *Denylist) watch() {
+	defer close(d.closed)
+
+	ticker := time.NewTicker(d.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reloaded, err := d.reload()
+			if err != nil {
+				log.Errorf("failed to reload denylist %s, keeping previous rules: %s", d.filename, err)
+			} else if reloaded {
+				log.Infof("reloaded denylist %s", d.filename)
+			}
+		case <-d.closing:
+			return
+		}
+	}
+}
+
+// reload reads the file again if it changed since it was last read.
+func (d *Denylist) reload() (bool, error) {
+	fi, err := os.Stat(d.filename)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.RLock()
+	unchanged := d.rules != nil && fi.ModTime().Equal(d.modTime) && fi.Size() == d.size
+	d.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(d.filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	rs, err := parse(f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", d.filename, err)
+	}
+
+	d.mu.Lock()
+	d.rules = rs
+	d.modTime = fi.ModTime()
+	d.size = fi.Size()
+	d.mu.Unlock()
+	return true, nil
+}
+
+func (d *Denylist) getRules() *rules {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.rules
+}
+
+// IsCidBlocked returns whether the given CID is blocked as a whole. CIDs with
+// the same multihash are treated as equal.
+func (d *Denylist) IsCidBlocked(c cid.Cid) bool {
+	rs := d.getRules()
+	if rs == nil || !c.Defined() {
+		return false
+	}
+	return rs.isCidBlocked(c, nil)
+}
+
+// IsPathBlocked returns whether the given /ipfs/ or /ipns/ path is blocked,
+// either because its root is blocked, or because one of its parent paths is.
+func (d *Denylist) IsPathBlocked(p path.Path) bool {
+	rs := d.getRules()
+	if rs == nil {
+		return false
+	}
+
+	segments := p.Segments()
+	if len(segments) < 2 {
+		return false
+	}
+	root, rest := segments[1], segments[2:]
+
+	switch segments[0] {
+	case path.IPFSNamespace:
+		c, err := cid.Decode(root)
+		if err != nil {
+			return false
+		}
+		return rs.isCidBlocked(c, rest)
+	case path.IPNSNamespace:
+		name := normalizeName(root)
+		return isSubPathBlocked(rs.names[name], rest) || rs.isDoubleHashBlocked(name, rest)
+	default:
+		return false
+	}
+}
+
+func (rs *rules) isCidBlocked(c cid.Cid, rest []string) bool {
+	if isSubPathBlocked(rs.cids[string(c.Hash())], rest) {
+		return true
+	}
+	return rs.isDoubleHashBlocked(cid.NewCidV1(c.Type(), c.Hash()).String(), rest)
+}
+
+// isDoubleHashBlocked checks the double hash of root, and of root joined with
+// every prefix of rest.
+func (rs *rules) isDoubleHashBlocked(root string, rest []string) bool {
+	if len(rs.hashes) == 0 {
+		return false
+	}
+	for i := 0; i <= len(rest); i++ {
+		if _, ok := rs.hashes[sha256.Sum256([]byte(root+"/"+strings.Join(rest[:i], "/")))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubPathBlocked returns whether rest is equal to, or under, one of the
+// blocked sub paths.
+func isSubPathBlocked(blocked []string, rest []string) bool {
+	for _, b := range blocked {
+		if b == "" {
+			return true
+		}
+		bs := strings.Split(b, "/")
+		if len(bs) > len(rest) {
+			continue
+		}
+		match := true
+		for i := range bs {
+			if bs[i] != rest[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName returns the canonical form of an IPNS name, so that different
+// encodings of the same key match.
+func normalizeName(name string) string {
+	if n, err := ipns.NameFromString(name); err == nil {
+		return n.String()
+	}
+	return strings.ToLower(name)
+}
+
+func parse(r io.Reader) (*rules, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Skip the optional header.
+	first := 0
+	for i, line := range lines {
+		if line == "---" {
+			first = i + 1
+			break
+		}
+	}
+
+	rs := &rules{
+		cids:   map[string][]string{},
+		names:  map[string][]string{},
+		hashes: map[[sha256.Size]byte]struct{}{},
+	}
+	for i := first; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := rs.add(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return rs, nil
+}
+
+func (rs *rules) add(rule string) error {
+	if strings.HasPrefix(rule, "//") {
+		digest, err := parseDoubleHash(rule[2:])
+		if err != nil {
+			return err
+		}
+		rs.hashes[digest] = struct{}{}
+		return nil
+	}
+
+	p, err := path.NewPath(rule)
+	if err != nil {
+		return fmt.Errorf("invalid rule %q: %w", rule, err)
+	}
+
+	segments := p.Segments()
+	subPath := strings.Join(segments[2:], "/")
+	switch p.Namespace() {
+	case path.IPFSNamespace:
+		c, err := cid.Decode(segments[1])
+		if err != nil {
+			return fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		key := string(c.Hash())
+		rs.cids[key] = append(rs.cids[key], subPath)
+	case path.IPNSNamespace:
+		name := normalizeName(segments[1])
+		rs.names[name] = append(rs.names[name], subPath)
+	default:
+		return fmt.Errorf("invalid rule %q: unsupported namespace", rule)
+	}
+	return nil
+}
+
+func parseDoubleHash(s string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+
+	if len(s) == hex.EncodedLen(sha256.Size) {
+		if b, err := hex.DecodeString(s); err == nil {
+			copy(digest[:], b)
+			return digest, nil
+		}
+	}
+
+	m, err := mh.FromB58String(s)
+	if err != nil {
+		return digest, fmt.Errorf("invalid double hash %q: %w", s, err)
+	}
+	dm, err := mh.Decode(m)
+	if err != nil {
+		return digest, fmt.Errorf("invalid double hash %q: %w", s, err)
+	}
+	if dm.Code != mh.SHA2_256 || len(dm.Digest) != sha256.Size {
+		return digest, fmt.Errorf("invalid double hash %q: only sha2-256 is supported", s)
+	}
+	copy(digest[:], dm.Digest)
+	return digest, nil
+}
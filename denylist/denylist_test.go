@@ -0,0 +1,130 @@
+package denylist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustCid(t *testing.T, data string) cid.Cid {
+	c, err := cid.NewPrefixV1(cid.DagProtobuf, mh.SHA2_256).Sum([]byte(data))
+	require.NoError(t, err)
+	return c
+}
+
+func mustPath(t *testing.T, str string) path.Path {
+	p, err := path.NewPath(str)
+	require.NoError(t, err)
+	return p
+}
+
+func TestDenylist(t *testing.T) {
+	blockedCid := mustCid(t, "blocked")
+	subPathCid := mustCid(t, "sub path")
+	hashedCid := mustCid(t, "hashed")
+	allowedCid := mustCid(t, "allowed")
+
+	pid := libp2ptest.RandPeerIDFatal(t)
+
+	hexHash := sha256.Sum256([]byte(hashedCid.String() + "/"))
+	mhHash, err := mh.Sum([]byte("example.org/secret"), mh.SHA2_256, -1)
+	require.NoError(t, err)
+
+	list := strings.Join([]string{
+		"version: 1",
+		"---",
+		"# comment",
+		"",
+		"/ipfs/" + blockedCid.String(),
+		"/ipfs/" + subPathCid.String() + "/a/b",
+		"/ipns/" + pid.String(),
+		"/ipns/Example.com",
+		"//" + hex.EncodeToString(hexHash[:]),
+		"//" + mhHash.B58String(),
+	}, "\n")
+
+	dl, err := New(strings.NewReader(list))
+	require.NoError(t, err)
+
+	t.Run("CIDs", func(t *testing.T) {
+		assert.True(t, dl.IsCidBlocked(blockedCid))
+		assert.True(t, dl.IsCidBlocked(cid.NewCidV0(blockedCid.Hash())), "CIDv0 with same multihash")
+		assert.True(t, dl.IsCidBlocked(hashedCid), "double hash")
+		assert.False(t, dl.IsCidBlocked(subPathCid))
+		assert.False(t, dl.IsCidBlocked(allowedCid))
+	})
+
+	t.Run("Paths", func(t *testing.T) {
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+blockedCid.String()+"/file")))
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+subPathCid.String()+"/a/b")))
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+subPathCid.String()+"/a/b/c")))
+		assert.False(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+subPathCid.String()+"/a")))
+		assert.False(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+subPathCid.String()+"/a/bc")))
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+hashedCid.String()+"/file")))
+		assert.False(t, dl.IsPathBlocked(mustPath(t, "/ipfs/"+allowedCid.String()+"/file")))
+	})
+
+	t.Run("IPNS and DNSLink names", func(t *testing.T) {
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipns/"+pid.String())))
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipns/"+peer.ToCid(pid).String()+"/file")), "CIDv1 peer ID")
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipns/example.com/file")))
+		assert.True(t, dl.IsPathBlocked(mustPath(t, "/ipns/example.org/secret/file")), "double hash")
+		assert.False(t, dl.IsPathBlocked(mustPath(t, "/ipns/example.org/public")))
+	})
+
+	t.Run("Nil denylist blocks nothing", func(t *testing.T) {
+		var nilList *Denylist
+		assert.False(t, nilList.IsCidBlocked(blockedCid))
+		assert.False(t, nilList.IsPathBlocked(mustPath(t, "/ipfs/"+blockedCid.String())))
+		assert.NoError(t, nilList.Close())
+	})
+}
+
+func TestDenylistInvalidRules(t *testing.T) {
+	for _, rule := range []string{
+		"/ipfs/not-a-cid",
+		"//not-a-hash",
+		"//" + hex.EncodeToString([]byte("too short")),
+		"bafkqaaa",
+	} {
+		_, err := New(strings.NewReader(rule))
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestDenylistReload(t *testing.T) {
+	first := mustCid(t, "first")
+	second := mustCid(t, "second")
+
+	filename := filepath.Join(t.TempDir(), "test.deny")
+	require.NoError(t, os.WriteFile(filename, []byte("/ipfs/"+first.String()+"\n"), 0o644))
+
+	dl, err := NewFromFile(filename, WithReloadInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer dl.Close()
+
+	assert.True(t, dl.IsCidBlocked(first))
+	assert.False(t, dl.IsCidBlocked(second))
+
+	require.NoError(t, os.WriteFile(filename, []byte("/ipfs/"+second.String()+"\n# reloaded\n"), 0o644))
+	assert.Eventually(t, func() bool {
+		return dl.IsCidBlocked(second) && !dl.IsCidBlocked(first)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Invalid lists are ignored, and the previous rules are kept.
+	require.NoError(t, os.WriteFile(filename, []byte("/ipfs/invalid\n"), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, dl.IsCidBlocked(second))
+}
@@ -7,12 +7,12 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/examples/gateway/common"
 	"github.com/ipfs/boxo/gateway"
 	"github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func main() {
@@ -37,14 +37,20 @@ func main() {
 	blockStore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
 
 	// Sets up the exchange, which will proxy the block requests to the given gateway.
-	e := newProxyExchange(*gatewayUrlPtr, nil)
-	blockService := blockservice.New(blockStore, e)
+	exch, err := gateway.NewRemoteExchange(
+		[]gateway.RemoteGateway{{URL: *gatewayUrlPtr}},
+		gateway.WithRemoteHTTPClient(newTracingHTTPClient()),
+		gateway.WithRemoteCacheBlockstore(blockStore),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Sets up the routing system, which will proxy the IPNS routing requests to the given gateway.
 	routing := newProxyRouting(*gatewayUrlPtr, nil)
 
-	// Creates the gateway with the block service and the routing.
-	backend, err := gateway.NewBlocksBackend(blockService, gateway.WithValueStore(routing))
+	// Creates the gateway with the exchange and the routing.
+	backend, err := gateway.NewRemoteBlocksBackend(exch, gateway.WithValueStore(routing))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -59,3 +65,11 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// newTracingHTTPClient returns an [http.Client] that propagates the tracing
+// context of incoming requests to the remote gateway.
+func newTracingHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+}
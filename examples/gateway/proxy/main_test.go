@@ -9,7 +9,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/examples/gateway/common"
 	"github.com/ipfs/boxo/gateway"
@@ -25,11 +24,17 @@ const (
 
 func newProxyGateway(t *testing.T, rs *httptest.Server) *httptest.Server {
 	blockStore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
-	exch := newProxyExchange(rs.URL, nil)
-	blockService := blockservice.New(blockStore, exch)
+	exch, err := gateway.NewRemoteExchange(
+		[]gateway.RemoteGateway{{URL: rs.URL}},
+		gateway.WithRemoteHTTPClient(newTracingHTTPClient()),
+		gateway.WithRemoteCacheBlockstore(blockStore),
+	)
+	if err != nil {
+		t.Error(err)
+	}
 	routing := newProxyRouting(rs.URL, nil)
 
-	backend, err := gateway.NewBlocksBackend(blockService, gateway.WithValueStore(routing))
+	backend, err := gateway.NewRemoteBlocksBackend(exch, gateway.WithValueStore(routing))
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,139 @@
+package files
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ZipWriter writes UnixFS nodes into a ZIP archive. Files are streamed into
+// the archive, and ZIP64 extensions are used when the sizes or offsets require
+// it.
+type ZipWriter struct {
+	ZipW       *zip.Writer
+	baseDirSet bool
+	baseDir    string
+}
+
+// NewZipWriter wraps given io.Writer into a new zip writer
+func NewZipWriter(w io.Writer) (*ZipWriter, error) {
+	return &ZipWriter{
+		ZipW: zip.NewWriter(w),
+	}, nil
+}
+
+func (w *ZipWriter) writeDir(f Directory, fpath string) error {
+	// Unlike TAR, ZIP has no entry for the root of the archive.
+	if fpath != "" {
+		if _, err := w.ZipW.CreateHeader(&zip.FileHeader{
+			Name:     fpath + "/",
+			Method:   zip.Store,
+			Modified: time.Now().Truncate(time.Second),
+		}); err != nil {
+			return err
+		}
+	}
+
+	it := f.Entries()
+	for it.Next() {
+		if err := w.WriteFile(it.Node(), path.Join(fpath, it.Name())); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+func (w *ZipWriter) writeFile(f File, fpath string) error {
+	size, err := f.Size()
+	if err != nil {
+		return err
+	}
+
+	fh := &zip.FileHeader{
+		Name:     fpath,
+		Method:   zip.Deflate,
+		Modified: time.Now().Truncate(time.Second),
+	}
+	fh.SetMode(0o644)
+
+	// Sizes and checksums are written in a data descriptor after the file
+	// contents, using ZIP64 extensions if needed.
+	zw, err := w.ZipW.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(zw, f)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("file %q: expected %d bytes, copied %d", fpath, size, n)
+	}
+	return nil
+}
+
+func (w *ZipWriter) writeSymlink(target, fpath string) error {
+	fh := &zip.FileHeader{
+		Name:     fpath,
+		Method:   zip.Store,
+		Modified: time.Now().Truncate(time.Second),
+	}
+	fh.SetMode(os.ModeSymlink | 0o777)
+
+	zw, err := w.ZipW.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(zw, target)
+	return err
+}
+
+func validateZipFilePath(baseDir, fpath string) bool {
+	if fpath == baseDir {
+		return true
+	}
+
+	// Ensure the filepath has no ".", "..", etc within the known root directory.
+	fpath = path.Clean(fpath)
+	if path.IsAbs(fpath) || fpath == ".." || strings.HasPrefix(fpath, "../") {
+		return false
+	}
+
+	// Unlike TAR, the check is done on whole path segments, so that siblings of
+	// the root directory that share its prefix are rejected too.
+	return baseDir == "" || strings.HasPrefix(fpath, baseDir+"/")
+}
+
+// WriteFile adds a node to the archive.
+func (w *ZipWriter) WriteFile(nd Node, fpath string) error {
+	if !w.baseDirSet {
+		w.baseDirSet = true // Use a variable for this as baseDir may be an empty string.
+		w.baseDir = fpath
+	}
+
+	if !validateZipFilePath(w.baseDir, fpath) {
+		return ErrUnixFSPathOutsideRoot
+	}
+
+	switch nd := nd.(type) {
+	case *Symlink:
+		return w.writeSymlink(nd.Target, fpath)
+	case File:
+		return w.writeFile(nd, fpath)
+	case Directory:
+		return w.writeDir(nd, fpath)
+	default:
+		return fmt.Errorf("file type %T is not supported", nd)
+	}
+}
+
+// Close finishes writing the zip file, by writing the central directory.
+func (w *ZipWriter) Close() error {
+	return w.ZipW.Close()
+}
@@ -0,0 +1,126 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestZipWriter(t *testing.T) {
+	tf := NewMapDirectory(map[string]Node{
+		"file.txt": NewBytesFile([]byte(text)),
+		"boop": NewMapDirectory(map[string]Node{
+			"a.txt": NewBytesFile([]byte("bleep")),
+			"b.txt": NewBytesFile([]byte("bloop")),
+		}),
+		"link": NewLinkFile("file.txt", nil),
+	})
+
+	var buf bytes.Buffer
+	zw, err := NewZipWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.WriteFile(tf, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"root/":           "",
+		"root/boop/":      "",
+		"root/boop/a.txt": "bleep",
+		"root/boop/b.txt": "bloop",
+		"root/file.txt":   text,
+		"root/link":       "file.txt",
+	}
+	if len(zr.File) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(zr.File))
+	}
+
+	for _, f := range zr.File {
+		content, ok := expected[f.Name]
+		if !ok {
+			t.Errorf("unexpected entry %q", f.Name)
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("entry %q: got %q, expected %q", f.Name, data, content)
+		}
+
+		if f.Name == "root/link" && f.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("entry %q should be a symlink, got mode %s", f.Name, f.Mode())
+		}
+	}
+}
+
+func TestZipWriterRelativePathInsideRoot(t *testing.T) {
+	tf := NewMapDirectory(map[string]Node{
+		"file.txt": NewBytesFile([]byte(text)),
+		"boop": NewMapDirectory(map[string]Node{
+			"../a.txt": NewBytesFile([]byte("bleep")),
+		}),
+	})
+
+	zw, err := NewZipWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+
+	if err := zw.WriteFile(tf, "root"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestZipWriterFailsFileOutsideRoot(t *testing.T) {
+	tf := NewMapDirectory(map[string]Node{
+		"../../x.txt": NewBytesFile([]byte(text)),
+	})
+
+	zw, err := NewZipWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+
+	if err := zw.WriteFile(tf, "root"); !errors.Is(err, ErrUnixFSPathOutsideRoot) {
+		t.Errorf("unexpected error, wanted: %v; got: %v", ErrUnixFSPathOutsideRoot, err)
+	}
+}
+
+func TestZipWriterFailsSiblingOfRoot(t *testing.T) {
+	tf := NewMapDirectory(map[string]Node{
+		"../rootx/x.txt": NewBytesFile([]byte(text)),
+	})
+
+	zw, err := NewZipWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+
+	if err := zw.WriteFile(tf, "root"); !errors.Is(err, ErrUnixFSPathOutsideRoot) {
+		t.Errorf("unexpected error, wanted: %v; got: %v", ErrUnixFSPathOutsideRoot, err)
+	}
+}
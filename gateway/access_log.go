@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// AccessLogFormat is the format of the entries written by [NewAccessLogHandler].
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON writes each entry as a JSON object on its own line, with all
+	// the fields of the entry.
+	AccessLogJSON AccessLogFormat = iota
+
+	// AccessLogCommon writes each entry in the [Common Log Format], which only
+	// includes the client address, the time, the request line, the status and
+	// the number of bytes sent.
+	//
+	// [Common Log Format]: https://httpd.apache.org/docs/current/logs.html#common
+	AccessLogCommon
+)
+
+// clfTimeFormat is the time format of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// responseFormatNames are the short names of the response formats used in
+// access logs, which match the values of the ?format= query parameter.
+var responseFormatNames = map[string]string{
+	"":                       "web",
+	rawResponseFormat:        "raw",
+	carResponseFormat:        "car",
+	tarResponseFormat:        "tar",
+	zipResponseFormat:        "zip",
+	jsonResponseFormat:       "json",
+	cborResponseFormat:       "cbor",
+	dagJsonResponseFormat:    "dag-json",
+	dagCborResponseFormat:    "dag-cbor",
+	ipnsRecordResponseFormat: "ipns-record",
+}
+
+// accessLogEntry is an entry of the access log.
+type accessLogEntry struct {
+	Time           time.Time `json:"time"`
+	RemoteAddr     string    `json:"remote_addr"`
+	Method         string    `json:"method"`
+	URI            string    `json:"uri"`
+	Proto          string    `json:"proto"`
+	Host           string    `json:"host"`
+	ContentPath    string    `json:"content_path,omitempty"`
+	ResolvedPath   string    `json:"resolved_path,omitempty"`
+	RootCid        string    `json:"root_cid,omitempty"`
+	ResponseFormat string    `json:"response_format,omitempty"`
+	Status         int       `json:"status"`
+	BytesSent      int64     `json:"bytes_sent"`
+	CacheControl   string    `json:"cache_control,omitempty"`
+	Etag           string    `json:"etag,omitempty"`
+	LastModified   string    `json:"last_modified,omitempty"`
+	DurationMs     float64   `json:"duration_ms"`
+	Referer        string    `json:"referer,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+}
+
+// NewAccessLogHandler is a middleware that wraps an [http.Handler] in order to
+// write an access log entry to out for each request, in the given format.
+//
+// Entries include the IPFS-specific details of requests, such as the content
+// path, the resolved immutable path and its root CID, and the response format,
+// when the wrapped handler is, or wraps, a handler created with [NewHandler].
+func NewAccessLogHandler(out io.Writer, format AccessLogFormat, next http.Handler) http.Handler {
+	return &accessLogHandler{
+		out:    out,
+		format: format,
+		next:   next,
+	}
+}
+
+type accessLogHandler struct {
+	out    io.Writer
+	format AccessLogFormat
+	next   http.Handler
+
+	mu sync.Mutex
+}
+
+func (h *accessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	begin := time.Now()
+
+	// The gateway handler fills in the summary of the request, which carries
+	// the IPFS-specific fields of the entry.
+	s := newRequestSummary(r)
+	r = r.WithContext(context.WithValue(r.Context(), requestSummaryKey, s))
+
+	ew := &errRecordingResponseWriter{ResponseWriter: w}
+	h.next.ServeHTTP(ew, r)
+
+	entry := accessLogEntry{
+		Time:         begin,
+		RemoteAddr:   ClientIPKey(r),
+		Method:       r.Method,
+		URI:          r.RequestURI,
+		Proto:        r.Proto,
+		Host:         r.Host,
+		Status:       ew.code,
+		BytesSent:    ew.bytes,
+		CacheControl: w.Header().Get("Cache-Control"),
+		Etag:         w.Header().Get("Etag"),
+		LastModified: w.Header().Get("Last-Modified"),
+		DurationMs:   float64(time.Since(begin)) / float64(time.Millisecond),
+		Referer:      r.Referer(),
+		UserAgent:    r.UserAgent(),
+	}
+	if entry.Status == 0 {
+		// Nothing was written, which net/http sends as 200 OK.
+		entry.Status = http.StatusOK
+	}
+
+	s.mu.Lock()
+	entry.ContentPath = s.summary.ContentPath
+	entry.ResolvedPath = s.summary.ResolvedPath
+	if s.rootCid != cid.Undef {
+		entry.RootCid = s.rootCid.String()
+	}
+	if entry.ContentPath != "" {
+		entry.ResponseFormat = responseFormatNames[s.summary.RequestType]
+		if entry.ResponseFormat == "" {
+			entry.ResponseFormat = s.summary.RequestType
+		}
+	}
+	s.mu.Unlock()
+
+	h.write(entry)
+}
+
+func (h *accessLogHandler) write(entry accessLogEntry) {
+	var line []byte
+	switch h.format {
+	case AccessLogCommon:
+		bytesSent := "-"
+		if entry.BytesSent > 0 {
+			bytesSent = strconv.FormatInt(entry.BytesSent, 10)
+		}
+		line = []byte(fmt.Sprintf("%s - - [%s] %q %d %s\n",
+			entry.RemoteAddr,
+			entry.Time.Format(clfTimeFormat),
+			entry.Method+" "+entry.URI+" "+entry.Proto,
+			entry.Status,
+			bytesSent,
+		))
+	default:
+		var err error
+		line, err = json.Marshal(entry)
+		if err != nil {
+			log.Errorw("failed to encode access log entry", "error", err)
+			return
+		}
+		line = append(line, '\n')
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.out.Write(line); err != nil {
+		log.Errorw("failed to write access log entry", "error", err)
+	}
+}
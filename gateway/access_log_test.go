@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/example.net"] = path.FromCid(root)
+
+	newAccessLog := func(t *testing.T, format AccessLogFormat) (http.Handler, *bytes.Buffer) {
+		var buf bytes.Buffer
+		handler := NewHandler(Config{DeserializedResponses: true}, backend)
+		return NewAccessLogHandler(&buf, format, handler), &buf
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		h, buf := newAccessLog(t, AccessLogJSON)
+
+		req := httptest.NewRequest(http.MethodGet, "/ipns/example.net/subdir/fnord?format=raw", nil)
+		req.RemoteAddr = "1.2.3.4:1000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+		assert.Equal(t, "1.2.3.4", entry["remote_addr"])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Equal(t, "/ipns/example.net/subdir/fnord", entry["content_path"])
+		assert.Equal(t, path.FromCid(root).String()+"/subdir/fnord", entry["resolved_path"])
+		assert.Equal(t, root.String(), entry["root_cid"])
+		assert.Equal(t, "raw", entry["response_format"])
+		assert.EqualValues(t, http.StatusOK, entry["status"])
+		assert.EqualValues(t, rec.Body.Len(), entry["bytes_sent"])
+		// Responses for mutable paths without a TTL have no Cache-Control.
+		assert.Empty(t, rec.Header().Get("Cache-Control"))
+		assert.NotContains(t, entry, "cache_control")
+		assert.Equal(t, rec.Header().Get("Etag"), entry["etag"])
+		assert.Contains(t, entry, "duration_ms")
+	})
+
+	t.Run("JSON with Cache-Control", func(t *testing.T) {
+		h, buf := newAccessLog(t, AccessLogJSON)
+
+		req := httptest.NewRequest(http.MethodGet, "/ipfs/"+root.String()+"/subdir/fnord", nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotEmpty(t, rec.Header().Get("Cache-Control"))
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, rec.Header().Get("Cache-Control"), entry["cache_control"])
+	})
+
+	t.Run("JSON without content path", func(t *testing.T) {
+		h, buf := newAccessLog(t, AccessLogJSON)
+
+		req := httptest.NewRequest(http.MethodOptions, "/ipfs/"+root.String(), nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.EqualValues(t, http.StatusOK, entry["status"])
+		assert.NotContains(t, entry, "content_path")
+		assert.NotContains(t, entry, "response_format")
+	})
+
+	t.Run("Common Log Format", func(t *testing.T) {
+		h, buf := newAccessLog(t, AccessLogCommon)
+
+		req := httptest.NewRequest(http.MethodGet, "/ipfs/"+root.String()+"/subdir/fnord", nil)
+		req.RemoteAddr = "1.2.3.4:1000"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Regexp(t, `^1\.2\.3\.4 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET `+regexp.QuoteMeta("/ipfs/"+root.String()+"/subdir/fnord")+` HTTP/1\.1" 200 5\n$`, buf.String())
+	})
+
+	t.Run("Shares the request summary with the gateway handler", func(t *testing.T) {
+		var summary RequestSummary
+		var buf bytes.Buffer
+		handler := NewHandler(Config{
+			DeserializedResponses: true,
+			OnRequestSummary:      func(s RequestSummary) { summary = s },
+		}, backend)
+		h := NewAccessLogHandler(&buf, AccessLogJSON, handler)
+
+		req := httptest.NewRequest(http.MethodGet, "/ipfs/"+root.String()+"/subdir/fnord", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, summary.ResolvedPath, entry["resolved_path"])
+		assert.Equal(t, "web", entry["response_format"])
+	})
+}
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultAdmissionRetryAfter is the default Retry-After hint sent to clients
+	// whose requests were rejected because too many requests were in flight.
+	DefaultAdmissionRetryAfter = time.Second
+
+	// admissionBucketSweepInterval is how often idle token buckets are removed.
+	admissionBucketSweepInterval = time.Minute
+)
+
+// expensiveResponseFormats are the response formats that can trigger an
+// unbounded amount of backend work for a single request.
+var expensiveResponseFormats = map[string]struct{}{
+	carResponseFormat: {},
+	tarResponseFormat: {},
+	zipResponseFormat: {},
+}
+
+// AdmissionConfig configures the admission control performed by
+// [NewAdmissionHandler]. Every limit is disabled when set to zero.
+type AdmissionConfig struct {
+	// RequestsPerSecond is the rate at which each client is allowed to make
+	// requests, on average.
+	RequestsPerSecond float64
+
+	// Burst is the maximum number of requests a client can make at once. It
+	// defaults to RequestsPerSecond, rounded up.
+	Burst int
+
+	// KeyFunc returns the key used to identify the client making a request. It
+	// defaults to [ClientIPKey]. A custom function is needed when the gateway is
+	// behind a reverse proxy, for example to read the X-Forwarded-For header.
+	KeyFunc func(r *http.Request) string
+
+	// MaxInFlight is the maximum number of requests handled at the same time.
+	MaxInFlight int
+
+	// MaxExpensiveInFlight is the maximum number of requests for expensive
+	// response formats, such as CAR, TAR and ZIP, handled at the same time. These
+	// requests also count towards MaxInFlight.
+	MaxExpensiveInFlight int
+
+	// RetryAfter is the Retry-After hint sent when a request is rejected because
+	// of MaxInFlight or MaxExpensiveInFlight. It defaults to
+	// [DefaultAdmissionRetryAfter].
+	RetryAfter time.Duration
+}
+
+// ClientIPKey returns the IP address of the client from [http.Request.RemoteAddr].
+func ClientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// NewAdmissionHandler is a middleware that wraps an [http.Handler] in order to
+// limit the rate of requests per client, and the number of requests handled at
+// the same time. Rejected requests receive a 429 Too Many Requests response
+// with a Retry-After header.
+func NewAdmissionHandler(c Config, ac AdmissionConfig, next http.Handler) http.Handler {
+	if ac.KeyFunc == nil {
+		ac.KeyFunc = ClientIPKey
+	}
+	if ac.RetryAfter <= 0 {
+		ac.RetryAfter = DefaultAdmissionRetryAfter
+	}
+
+	a := &admissionHandler{
+		config: &c,
+		ac:     ac,
+		next:   next,
+		rejectedMetric: newCounterMetric(
+			"gw_admission_rejected_total",
+			"The number of requests rejected by the admission control, per reason.",
+			"reason",
+		),
+	}
+	if ac.RequestsPerSecond > 0 {
+		burst := ac.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(ac.RequestsPerSecond))
+		}
+		a.limiter = newRateLimiter(ac.RequestsPerSecond, burst)
+	}
+	if ac.MaxInFlight > 0 {
+		a.inFlight = make(chan struct{}, ac.MaxInFlight)
+	}
+	if ac.MaxExpensiveInFlight > 0 {
+		a.expensiveInFlight = make(chan struct{}, ac.MaxExpensiveInFlight)
+	}
+	return a
+}
+
+type admissionHandler struct {
+	config *Config
+	ac     AdmissionConfig
+	next   http.Handler
+
+	limiter           *rateLimiter
+	inFlight          chan struct{}
+	expensiveInFlight chan struct{}
+
+	rejectedMetric *prometheus.CounterVec
+}
+
+func (a *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.limiter != nil {
+		if ok, wait := a.limiter.allow(a.ac.KeyFunc(r)); !ok {
+			a.reject(w, r, "rate_limit", fmt.Errorf("request rate limit exceeded: %w", ErrTooManyRequests), wait)
+			return
+		}
+	}
+
+	if a.inFlight != nil {
+		select {
+		case a.inFlight <- struct{}{}:
+			defer func() { <-a.inFlight }()
+		default:
+			a.reject(w, r, "in_flight", fmt.Errorf("too many requests in flight: %w", ErrTooManyRequests), a.ac.RetryAfter)
+			return
+		}
+	}
+
+	if a.expensiveInFlight != nil && isExpensiveRequest(r) {
+		select {
+		case a.expensiveInFlight <- struct{}{}:
+			defer func() { <-a.expensiveInFlight }()
+		default:
+			a.reject(w, r, "expensive_in_flight", fmt.Errorf("too many expensive requests in flight: %w", ErrTooManyRequests), a.ac.RetryAfter)
+			return
+		}
+	}
+
+	a.next.ServeHTTP(w, r)
+}
+
+func (a *admissionHandler) reject(w http.ResponseWriter, r *http.Request, reason string, err error, retryAfter time.Duration) {
+	a.rejectedMetric.WithLabelValues(reason).Inc()
+
+	// Retry-After only has a precision of one second, round up so that clients
+	// never retry too early.
+	retryAfter = time.Duration(math.Ceil(retryAfter.Seconds())) * time.Second
+	webError(w, r, a.config, NewErrorRetryAfter(err, retryAfter), http.StatusTooManyRequests)
+}
+
+// isExpensiveRequest returns whether the request asks for one of the
+// [expensiveResponseFormats].
+func isExpensiveRequest(r *http.Request) bool {
+	responseFormat, _, err := customResponseFormat(r)
+	if err != nil {
+		return false
+	}
+	_, ok := expensiveResponseFormats[responseFormat]
+	return ok
+}
+
+// rateLimiter is a set of token buckets, one per client key.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		now:       time.Now,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of the given key. If the bucket is empty,
+// it returns false and how long to wait until a token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= admissionBucketSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets that would be full by now, as they are
+// indistinguishable from new buckets.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmissionHandler(t *testing.T) {
+	t.Parallel()
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(h http.Handler, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Rate limits each client", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewAdmissionHandler(Config{}, AdmissionConfig{RequestsPerSecond: 0.5, Burst: 2}, okHandler)
+
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa", "1.2.3.4:1000").Code)
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa", "1.2.3.4:1001").Code)
+
+		rec := serve(h, "/ipfs/bafkqaaa", "1.2.3.4:1002")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+		// Other clients have their own bucket.
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa", "5.6.7.8:1000").Code)
+	})
+
+	t.Run("Uses custom key function", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewAdmissionHandler(Config{}, AdmissionConfig{
+			RequestsPerSecond: 1,
+			KeyFunc: func(r *http.Request) string {
+				return r.URL.Query().Get("key")
+			},
+		}, okHandler)
+
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa?key=a", "1.2.3.4:1000").Code)
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa?key=b", "1.2.3.4:1000").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(h, "/ipfs/bafkqaaa?key=a", "5.6.7.8:1000").Code)
+	})
+
+	t.Run("Limits requests in flight", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusOK)
+		})
+
+		h := NewAdmissionHandler(Config{}, AdmissionConfig{MaxInFlight: 1, RetryAfter: 3 * time.Second}, blocking)
+
+		done := make(chan int)
+		go func() {
+			done <- serve(h, "/ipfs/bafkqaaa", "1.2.3.4:1000").Code
+		}()
+		<-started
+
+		rec := serve(h, "/ipfs/bafkqaaa", "5.6.7.8:1000")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("Retry-After"))
+
+		close(release)
+		assert.Equal(t, http.StatusOK, <-done)
+	})
+
+	t.Run("Limits expensive requests in flight", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("format") == "car" {
+				started <- struct{}{}
+				<-release
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		h := NewAdmissionHandler(Config{}, AdmissionConfig{MaxExpensiveInFlight: 1}, blocking)
+
+		done := make(chan int)
+		go func() {
+			done <- serve(h, "/ipfs/bafkqaaa?format=car", "1.2.3.4:1000").Code
+		}()
+		<-started
+
+		assert.Equal(t, http.StatusTooManyRequests, serve(h, "/ipfs/bafkqaaa?format=car", "5.6.7.8:1000").Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/ipfs/bafkqaaa", nil)
+		req.Header.Set("Accept", tarResponseFormat)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		assert.Equal(t, http.StatusOK, serve(h, "/ipfs/bafkqaaa?format=raw", "5.6.7.8:1000").Code)
+
+		close(release)
+		assert.Equal(t, http.StatusOK, <-done)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := newRateLimiter(2, 1)
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.allow("a")
+	require.True(t, ok)
+
+	ok, wait := l.allow("a")
+	require.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.allow("a")
+	assert.True(t, ok)
+
+	// Idle buckets are removed once they are full again.
+	now = now.Add(admissionBucketSweepInterval)
+	ok, _ = l.allow("b")
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+}
@@ -113,6 +113,7 @@ type DirectoryTemplateData struct {
 	Breadcrumbs []Breadcrumb
 	BackLink    string
 	Hash        string
+	NextPage    string
 }
 
 type DirectoryItem struct {
@@ -0,0 +1,82 @@
+package backendtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/namesys"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type blocksBackendProvider struct{}
+
+func (blocksBackendProvider) MakeBackend(t *testing.T, bs blockstore.Blockstore, ns namesys.NameSystem) gateway.IPFSBackend {
+	backend, err := gateway.NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)), gateway.WithNameSystem(ns))
+	require.NoError(t, err)
+	return backend
+}
+
+func TestBlocksBackend(t *testing.T) {
+	TestBackend(blocksBackendProvider{})(t)
+}
+
+// providerFunc is a [Provider] function.
+type providerFunc func(t *testing.T, bs blockstore.Blockstore, ns namesys.NameSystem) gateway.IPFSBackend
+
+func (f providerFunc) MakeBackend(t *testing.T, bs blockstore.Blockstore, ns namesys.NameSystem) gateway.IPFSBackend {
+	return f(t, bs, ns)
+}
+
+// readRecordingBlockstore records the blocks that are read.
+type readRecordingBlockstore struct {
+	blockstore.Blockstore
+
+	lk   sync.Mutex
+	read map[cid.Cid]bool
+}
+
+func (bs *readRecordingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	bs.lk.Lock()
+	bs.read[c] = true
+	bs.lk.Unlock()
+	return bs.Blockstore.Get(ctx, c)
+}
+
+func (bs *readRecordingBlockstore) wasRead(c cid.Cid) bool {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	return bs.read[c]
+}
+
+func TestBlocksBackendPrefetchesRanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var recording *readRecordingBlockstore
+	tp := &TestSuite{Provider: providerFunc(func(t *testing.T, bs blockstore.Blockstore, ns namesys.NameSystem) gateway.IPFSBackend {
+		recording = &readRecordingBlockstore{Blockstore: bs, read: map[cid.Cid]bool{}}
+		return blocksBackendProvider{}.MakeBackend(t, recording, ns)
+	})}
+	backend := tp.makeBackend(t)
+
+	// The blocks of the second range are fetched without reading the file up
+	// to it, and the blocks between the ranges are not fetched.
+	first, last := int64(9), int64(639)
+	_, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "multiblock.txt"), gateway.ByteRange{From: 0, To: &first}, gateway.ByteRange{From: 600, To: &last})
+	require.NoError(t, err)
+	defer resp.Close()
+
+	assert.Eventually(t, func() bool {
+		return recording.wasRead(multiblockLeafCids[2])
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, recording.wasRead(multiblockLeafCids[1]))
+}
@@ -0,0 +1,218 @@
+package backendtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestGetCAR(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	int64Ptr := func(v int64) *int64 { return &v }
+
+	for _, test := range []struct {
+		name     string
+		segments []string
+		scope    gateway.DagScope
+		rng      *gateway.DagByteRange
+
+		// terminal is the block of the terminal element of the path.
+		terminal cid.Cid
+
+		// blocks are the blocks that must be in the CAR, in DFS order.
+		blocks []cid.Cid
+
+		// excluded are blocks that must not be in the CAR.
+		excluded []cid.Cid
+
+		// duplicated is a block that is duplicated in the DAG.
+		duplicated cid.Cid
+	}{
+		{
+			name:     "Directory with dag-scope=all",
+			scope:    gateway.DagScopeAll,
+			terminal: rootCid,
+			blocks:   []cid.Cid{rootCid, dupsCid, dupsLeafCid, emptyDirCid, helloCid, multiblockCid, multiblockLeafCids[0], multiblockLeafCids[1], multiblockLeafCids[2], subdirCid, dagCborDocCid, dagJsonDocCid, symlinkCid},
+			// The duplicated block of dups.txt is in the DAG of the root.
+			duplicated: dupsLeafCid,
+		},
+		{
+			name:     "Directory with dag-scope=entity",
+			scope:    gateway.DagScopeEntity,
+			terminal: rootCid,
+			blocks:   []cid.Cid{rootCid},
+			excluded: []cid.Cid{dupsCid, helloCid, subdirCid},
+		},
+		{
+			name:     "Directory with dag-scope=block",
+			scope:    gateway.DagScopeBlock,
+			terminal: rootCid,
+			blocks:   []cid.Cid{rootCid},
+			excluded: []cid.Cid{dupsCid, helloCid, subdirCid},
+		},
+		{
+			name:     "File with dag-scope=all",
+			segments: []string{"multiblock.txt"},
+			scope:    gateway.DagScopeAll,
+			terminal: multiblockCid,
+			blocks:   []cid.Cid{rootCid, multiblockCid, multiblockLeafCids[0], multiblockLeafCids[1], multiblockLeafCids[2]},
+			excluded: []cid.Cid{helloCid, subdirCid},
+		},
+		{
+			name:     "File with dag-scope=entity",
+			segments: []string{"multiblock.txt"},
+			scope:    gateway.DagScopeEntity,
+			terminal: multiblockCid,
+			blocks:   []cid.Cid{rootCid, multiblockCid, multiblockLeafCids[0], multiblockLeafCids[1], multiblockLeafCids[2]},
+			excluded: []cid.Cid{helloCid, subdirCid},
+		},
+		{
+			name:     "File with dag-scope=entity and entity-bytes within the first block",
+			segments: []string{"multiblock.txt"},
+			scope:    gateway.DagScopeEntity,
+			terminal: multiblockCid,
+			rng:      &gateway.DagByteRange{From: 0, To: int64Ptr(10)},
+			blocks:   []cid.Cid{rootCid, multiblockCid, multiblockLeafCids[0]},
+			excluded: []cid.Cid{multiblockLeafCids[2]},
+		},
+		{
+			name:     "File with dag-scope=entity and entity-bytes from the end",
+			segments: []string{"multiblock.txt"},
+			scope:    gateway.DagScopeEntity,
+			terminal: multiblockCid,
+			rng:      &gateway.DagByteRange{From: -10},
+			blocks:   []cid.Cid{rootCid, multiblockCid, multiblockLeafCids[2]},
+			excluded: []cid.Cid{multiblockLeafCids[0]},
+		},
+		{
+			name:     "File with dag-scope=block",
+			segments: []string{"multiblock.txt"},
+			scope:    gateway.DagScopeBlock,
+			terminal: multiblockCid,
+			blocks:   []cid.Cid{rootCid, multiblockCid},
+			excluded: multiblockLeafCids,
+		},
+		{
+			name:       "File with duplicated blocks with dag-scope=entity",
+			segments:   []string{"dups.txt"},
+			scope:      gateway.DagScopeEntity,
+			terminal:   dupsCid,
+			blocks:     []cid.Cid{rootCid, dupsCid, dupsLeafCid},
+			duplicated: dupsLeafCid,
+		},
+		{
+			name:     "DAG-CBOR block with dag-scope=all",
+			segments: []string{"subdir", "dag-cbor-document"},
+			scope:    gateway.DagScopeAll,
+			terminal: dagCborDocCid,
+			blocks:   []cid.Cid{rootCid, subdirCid, dagCborDocCid},
+			excluded: []cid.Cid{dagJsonDocCid},
+		},
+	} {
+		for _, order := range []gateway.DagOrder{gateway.DagOrderUnspecified, gateway.DagOrderUnknown, gateway.DagOrderDFS} {
+			for _, dups := range []gateway.DuplicateBlocksPolicy{gateway.DuplicateBlocksUnspecified, gateway.DuplicateBlocksIncluded, gateway.DuplicateBlocksExcluded} {
+				t.Run(fmt.Sprintf("%s order=%s dups=%s", test.name, order, dups), func(t *testing.T) {
+					params := gateway.CarParams{
+						Range:      test.rng,
+						Scope:      test.scope,
+						Order:      order,
+						Duplicates: dups,
+					}
+					md, rc, err := backend.GetCAR(ctx, immutablePath(t, rootCid, test.segments...), params)
+					require.NoError(t, err)
+					defer rc.Close()
+
+					assert.Equal(t, test.terminal, md.LastSegment.RootCid())
+
+					got := readCAR(t, rc)
+
+					// Blocks are unique, unless duplicates are explicitly requested.
+					seen := map[cid.Cid]int{}
+					var unique []cid.Cid
+					for _, c := range got {
+						if seen[c] == 0 {
+							unique = append(unique, c)
+						}
+						seen[c]++
+					}
+					if dups.Bool() {
+						if test.duplicated.Defined() {
+							assert.GreaterOrEqual(t, seen[test.duplicated], 2, "duplicated block was not sent twice")
+						}
+					} else {
+						for c, n := range seen {
+							assert.Equal(t, 1, n, "block %s was sent %d times", c, n)
+						}
+					}
+
+					// Blocks must be in DFS order, unless any order is accepted.
+					if order == gateway.DagOrderUnknown {
+						for _, c := range test.blocks {
+							assert.Contains(t, unique, c)
+						}
+					} else {
+						assertSubsequence(t, test.blocks, unique)
+					}
+
+					for _, c := range test.excluded {
+						assert.NotContains(t, unique, c)
+					}
+				})
+			}
+		}
+	}
+
+	t.Run("Missing link", func(t *testing.T) {
+		// The CAR proves that the link is missing, so it is not an error: it
+		// holds the blocks of the path up to the directory without the link.
+		_, rc, err := backend.GetCAR(ctx, immutablePath(t, rootCid, "missing"), gateway.CarParams{Scope: gateway.DagScopeAll})
+		require.NoError(t, err)
+		defer rc.Close()
+		assert.Equal(t, []cid.Cid{rootCid}, readCAR(t, rc))
+	})
+}
+
+// readCAR reads a CAR stream, checking that each block matches its CID, and
+// returns the CIDs of its blocks in order.
+func readCAR(t *testing.T, r io.Reader) []cid.Cid {
+	t.Helper()
+
+	br, err := car.NewBlockReader(r)
+	require.NoError(t, err)
+	assert.Len(t, br.Roots, 1)
+
+	var cids []cid.Cid
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			return cids
+		}
+		require.NoError(t, err)
+		assertBlockData(t, blk.Cid(), blk.RawData())
+		cids = append(cids, blk.Cid())
+	}
+}
+
+// assertSubsequence asserts that expected appear in order in got.
+func assertSubsequence(t *testing.T, expected, got []cid.Cid) {
+	t.Helper()
+
+	i := 0
+	for _, c := range got {
+		if i < len(expected) && c == expected[i] {
+			i++
+		}
+	}
+	assert.Equal(t, len(expected), i, "blocks are not in DFS order: expected %v, got %v", expected, got)
+}
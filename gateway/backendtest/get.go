@@ -0,0 +1,298 @@
+package backendtest
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	readBytes := func(t *testing.T, resp *gateway.GetResponse, n int64) (string, int64) {
+		r, size := resp.Bytes()
+		require.NotNil(t, r, "response is not a file")
+		if n >= 0 {
+			r = io.NopCloser(io.LimitReader(r, n))
+		}
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(data), size
+	}
+
+	t.Run("UnixFS file", func(t *testing.T) {
+		md, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "multiblock.txt"))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		assert.Equal(t, multiblockCid, md.LastSegment.RootCid())
+		assert.Equal(t, []cid.Cid{rootCid}, md.PathSegmentRoots)
+
+		data, size := readBytes(t, resp, -1)
+		assert.EqualValues(t, len(multiblockContent), size)
+		assert.Equal(t, multiblockContent, data)
+	})
+
+	for _, test := range []struct {
+		name string
+		from uint64
+		to   int64
+	}{
+		{"within the first block", 10, 19},
+		{"across blocks", 250, 265},
+		{"within the last block", 600, 639},
+	} {
+		t.Run("UnixFS file range "+test.name, func(t *testing.T) {
+			to := test.to
+			_, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "multiblock.txt"), gateway.ByteRange{From: test.from, To: &to})
+			require.NoError(t, err)
+			defer resp.Close()
+
+			// The reader starts at the beginning of the range, and the size is the
+			// size of the whole file.
+			data, size := readBytes(t, resp, test.to-int64(test.from)+1)
+			assert.EqualValues(t, len(multiblockContent), size)
+			assert.Equal(t, multiblockContent[test.from:test.to+1], data)
+		})
+	}
+
+	t.Run("Raw block range", func(t *testing.T) {
+		_, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "hello.txt"), gateway.ByteRange{From: 6})
+		require.NoError(t, err)
+		defer resp.Close()
+
+		data, size := readBytes(t, resp, -1)
+		assert.EqualValues(t, 12, size)
+		assert.Equal(t, "world\n", data)
+	})
+
+	t.Run("DAG-CBOR block ignores ranges", func(t *testing.T) {
+		md, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "subdir", "dag-cbor-document"), gateway.ByteRange{From: 5})
+		require.NoError(t, err)
+		defer resp.Close()
+
+		assert.Equal(t, dagCborDocCid, md.LastSegment.RootCid())
+		assert.Equal(t, []cid.Cid{rootCid, subdirCid}, md.PathSegmentRoots)
+
+		data, size := readBytes(t, resp, -1)
+		assert.EqualValues(t, len(data), size)
+		assertBlockData(t, dagCborDocCid, []byte(data))
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		md, resp, err := backend.Get(ctx, immutablePath(t, rootCid))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		assert.Equal(t, rootCid, md.LastSegment.RootCid())
+		assert.Empty(t, md.PathSegmentRoots)
+
+		dagSize, entries, ok := resp.DirectoryListing()
+		require.True(t, ok, "response is not a directory")
+		assert.NotZero(t, dagSize)
+
+		links := map[string]cid.Cid{}
+		for entry := range entries {
+			require.NoError(t, entry.Err)
+			links[entry.Link.Name] = entry.Link.Cid
+		}
+		assert.Equal(t, map[string]cid.Cid{
+			"dups.txt":       dupsCid,
+			"empty-dir":      emptyDirCid,
+			"hello.txt":      helloCid,
+			"multiblock.txt": multiblockCid,
+			"subdir":         subdirCid,
+			"symlink":        symlinkCid,
+		}, links)
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		_, resp, err := backend.Get(ctx, immutablePath(t, rootCid, "symlink"))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		s := resp.Symlink()
+		require.NotNil(t, s, "response is not a symlink")
+		assert.Equal(t, "hello.txt", s.Target)
+	})
+
+	t.Run("Missing link", func(t *testing.T) {
+		_, _, err := backend.Get(ctx, immutablePath(t, rootCid, "missing"))
+		assertNotFound(t, err)
+	})
+}
+
+func (tp *TestSuite) TestGetAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	t.Run("File", func(t *testing.T) {
+		md, nd, err := backend.GetAll(ctx, immutablePath(t, rootCid, "multiblock.txt"))
+		require.NoError(t, err)
+		defer nd.Close()
+
+		assert.Equal(t, multiblockCid, md.LastSegment.RootCid())
+
+		f, ok := nd.(files.File)
+		require.True(t, ok, "node is not a file")
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, multiblockContent, string(data))
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		md, nd, err := backend.GetAll(ctx, immutablePath(t, rootCid))
+		require.NoError(t, err)
+		defer nd.Close()
+
+		assert.Equal(t, rootCid, md.LastSegment.RootCid())
+
+		dir, ok := nd.(files.Directory)
+		require.True(t, ok, "node is not a directory")
+
+		// The entries of subdir are not UnixFS, so it is not walked.
+		var names []string
+		contents := map[string]string{}
+		it := dir.Entries()
+		for it.Next() {
+			names = append(names, it.Name())
+			switch n := it.Node().(type) {
+			case *files.Symlink:
+				contents[it.Name()] = "-> " + n.Target
+			case files.File:
+				data, err := io.ReadAll(n)
+				require.NoError(t, err)
+				contents[it.Name()] = string(data)
+			case files.Directory:
+				contents[it.Name()] = "/"
+			}
+		}
+		require.NoError(t, it.Err())
+
+		assert.True(t, sort.StringsAreSorted(names), "entries are not sorted: %v", names)
+		assert.Equal(t, map[string]string{
+			"dups.txt":       strings.Repeat("D", 512),
+			"empty-dir":      "/",
+			"hello.txt":      "hello world\n",
+			"multiblock.txt": multiblockContent,
+			"subdir":         "/",
+			"symlink":        "-> hello.txt",
+		}, contents)
+	})
+
+	t.Run("Missing link", func(t *testing.T) {
+		_, _, err := backend.GetAll(ctx, immutablePath(t, rootCid, "missing"))
+		assertNotFound(t, err)
+	})
+}
+
+func (tp *TestSuite) TestGetBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	for _, test := range []struct {
+		name     string
+		segments []string
+		cid      cid.Cid
+		roots    []cid.Cid
+	}{
+		{"Directory", nil, rootCid, nil},
+		{"File", []string{"multiblock.txt"}, multiblockCid, []cid.Cid{rootCid}},
+		{"Raw block", []string{"hello.txt"}, helloCid, []cid.Cid{rootCid}},
+		{"DAG-JSON block", []string{"subdir", "dag-json-document"}, dagJsonDocCid, []cid.Cid{rootCid, subdirCid}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			md, f, err := backend.GetBlock(ctx, immutablePath(t, rootCid, test.segments...))
+			require.NoError(t, err)
+			defer f.Close()
+
+			assert.Equal(t, test.cid, md.LastSegment.RootCid())
+			assert.ElementsMatch(t, test.roots, md.PathSegmentRoots)
+
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assertBlockData(t, test.cid, data)
+		})
+	}
+
+	t.Run("Missing link", func(t *testing.T) {
+		_, _, err := backend.GetBlock(ctx, immutablePath(t, rootCid, "missing"))
+		assertNotFound(t, err)
+	})
+}
+
+func (tp *TestSuite) TestHead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	head := func(t *testing.T, segments ...string) (gateway.ContentPathMetadata, *gateway.HeadResponse) {
+		md, resp, err := backend.Head(ctx, immutablePath(t, rootCid, segments...))
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Close() })
+		return md, resp
+	}
+
+	t.Run("UnixFS file", func(t *testing.T) {
+		md, resp := head(t, "multiblock.txt")
+		assert.Equal(t, multiblockCid, md.LastSegment.RootCid())
+		assert.True(t, resp.IsFile())
+		assert.EqualValues(t, len(multiblockContent), resp.Size())
+
+		// Either the content type is set, or enough of the file is returned to
+		// sniff it.
+		if md.ContentType == "" {
+			require.NotNil(t, resp.StartingBytes())
+			data, err := io.ReadAll(resp.StartingBytes())
+			require.NoError(t, err)
+			assert.NotEmpty(t, data)
+			assert.Equal(t, multiblockContent[:len(data)], string(data))
+		}
+	})
+
+	t.Run("Raw block", func(t *testing.T) {
+		_, resp := head(t, "hello.txt")
+		assert.True(t, resp.IsFile())
+		assert.EqualValues(t, 12, resp.Size())
+	})
+
+	t.Run("DAG-CBOR block", func(t *testing.T) {
+		_, resp := head(t, "subdir", "dag-cbor-document")
+		assert.True(t, resp.IsFile())
+		assert.EqualValues(t, 13, resp.Size())
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		md, resp := head(t, "empty-dir")
+		assert.Equal(t, emptyDirCid, md.LastSegment.RootCid())
+		assert.True(t, resp.IsDirectory())
+		assert.False(t, resp.IsFile())
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		_, resp := head(t, "symlink")
+		assert.True(t, resp.IsSymlink())
+		assert.EqualValues(t, len("hello.txt"), resp.Size())
+	})
+
+	t.Run("Missing link", func(t *testing.T) {
+		_, _, err := backend.Head(ctx, immutablePath(t, rootCid, "missing"))
+		assertNotFound(t, err)
+	})
+}
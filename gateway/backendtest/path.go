@@ -0,0 +1,125 @@
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestResolvePath(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	for _, test := range []struct {
+		name      string
+		segments  []string
+		roots     []cid.Cid
+		last      cid.Cid
+		remainder []string
+	}{
+		{"Root", nil, nil, rootCid, nil},
+		{"File", []string{"multiblock.txt"}, []cid.Cid{rootCid}, multiblockCid, nil},
+		{"Nested", []string{"subdir", "dag-cbor-document"}, []cid.Cid{rootCid, subdirCid}, dagCborDocCid, nil},
+		{"Within a DAG-CBOR block", []string{"subdir", "dag-cbor-document", "hello"}, []cid.Cid{rootCid, subdirCid, dagCborDocCid}, dagCborDocCid, []string{"hello"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			md, err := backend.ResolvePath(ctx, immutablePath(t, rootCid, test.segments...))
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, test.roots, md.PathSegmentRoots)
+			assert.Equal(t, test.last, md.LastSegment.RootCid())
+			assert.ElementsMatch(t, test.remainder, md.LastSegmentRemainder)
+		})
+	}
+
+	for _, test := range []struct {
+		name     string
+		segments []string
+	}{
+		{"Missing link", []string{"missing"}},
+		{"Missing nested link", []string{"subdir", "missing"}},
+		{"Link within a raw block", []string{"hello.txt", "missing"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := backend.ResolvePath(ctx, immutablePath(t, rootCid, test.segments...))
+			assertNotFound(t, err)
+		})
+	}
+}
+
+func (tp *TestSuite) TestResolveMutable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := tp.makeBackend(t)
+
+	resolve := func(t *testing.T, p string) (path.ImmutablePath, error) {
+		mutablePath, err := path.NewPath(p)
+		require.NoError(t, err)
+		return backend.ResolveMutable(ctx, mutablePath)
+	}
+
+	t.Run("Name", func(t *testing.T) {
+		p, err := resolve(t, dnslinkName)
+		require.NoError(t, err)
+		assert.Equal(t, immutablePath(t, rootCid).String(), p.String())
+	})
+
+	t.Run("Name with a remainder", func(t *testing.T) {
+		p, err := resolve(t, dnslinkName+"/subdir/dag-cbor-document")
+		require.NoError(t, err)
+		assert.Equal(t, immutablePath(t, rootCid, "subdir", "dag-cbor-document").String(), p.String())
+	})
+
+	t.Run("Immutable path", func(t *testing.T) {
+		p, err := resolve(t, immutablePath(t, rootCid, "subdir").String())
+		require.NoError(t, err)
+		assert.Equal(t, immutablePath(t, rootCid, "subdir").String(), p.String())
+	})
+
+	t.Run("Unknown name", func(t *testing.T) {
+		_, err := resolve(t, "/ipns/unknown.example.net")
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown name with a remainder", func(t *testing.T) {
+		_, err := resolve(t, "/ipns/unknown.example.net/subdir")
+		assert.Error(t, err)
+	})
+}
+
+// assertNotFound asserts that err is one of the errors that the gateway handler
+// treats as content not found, which result in 404 Not Found responses.
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	require.Error(t, err)
+
+	if errors.Is(err, &resolver.ErrNoLink{}) {
+		return
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch e.(type) {
+		case datamodel.ErrWrongKind, datamodel.ErrNotExists:
+			return
+		}
+	}
+	assert.Fail(t, "error is not a not found error", "%v (%T)", err, err)
+}
+
+// assertBlockData asserts that data is the data of the block c.
+func assertBlockData(t *testing.T, c cid.Cid, data []byte) {
+	t.Helper()
+
+	got, err := c.Prefix().Sum(data)
+	require.NoError(t, err)
+	assert.Equal(t, c, got, "data does not match the block")
+}
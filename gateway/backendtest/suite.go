@@ -0,0 +1,151 @@
+// Package backendtest provides a conformance test suite for implementations of
+// [gateway.IPFSBackend]. It checks the behaviour that the gateway handler relies
+// on, using [gateway.BlocksBackend] as the reference.
+//
+// To run the suite against a backend, implement [Provider] and call
+// [TestBackend] from a test:
+//
+//	func TestMyBackend(t *testing.T) {
+//		backendtest.TestBackend(myProvider{})(t)
+//	}
+package backendtest
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	nsopts "github.com/ipfs/boxo/coreiface/options/namesys"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// Provider creates the backends under test.
+type Provider interface {
+	// MakeBackend returns the backend under test. It must serve the blocks of bs,
+	// and resolve /ipns/ names with ns.
+	MakeBackend(t *testing.T, bs blockstore.Blockstore, ns namesys.NameSystem) gateway.IPFSBackend
+}
+
+// TestSuite is the conformance test suite of a [Provider].
+type TestSuite struct {
+	Provider
+}
+
+// TestBackend returns a test that runs the whole suite against the backends
+// created by p.
+func TestBackend(p Provider) func(t *testing.T) {
+	tp := &TestSuite{Provider: p}
+
+	return func(t *testing.T) {
+		t.Run("Get", tp.TestGet)
+		t.Run("GetAll", tp.TestGetAll)
+		t.Run("GetBlock", tp.TestGetBlock)
+		t.Run("Head", tp.TestHead)
+		t.Run("ResolvePath", tp.TestResolvePath)
+		t.Run("ResolveMutable", tp.TestResolveMutable)
+		t.Run("GetCAR", tp.TestGetCAR)
+	}
+}
+
+// unixfsFixture is a UnixFS directory with the following entries:
+//
+//	dups.txt            file of two identical 256 bytes leaves
+//	empty-dir/          empty directory
+//	hello.txt           raw leaf with "hello world\n"
+//	multiblock.txt      file of three leaves: 256 'A', 256 'B' and 128 'C'
+//	subdir/
+//	  dag-cbor-document {"hello": "world"} as DAG-CBOR
+//	  dag-json-document {"hello": "world"} as DAG-JSON
+//	symlink             symlink to hello.txt
+//
+//go:embed unixfs.car
+var unixfsFixture []byte
+
+// CIDs of the blocks of unixfsFixture.
+var (
+	rootCid            = cid.MustParse("bafybeiaep46wv2lk2bupxeevaagqivpfvlx5idbizwwo44v2c46s23vanq")
+	dupsCid            = cid.MustParse("bafybeihu2giogcocww7pja5xxfymfguotbomfoluam56pjcgisfxtr23va")
+	dupsLeafCid        = cid.MustParse("bafkreifpjbk7pkuuuuoxtpgyfnuaomgncmyrnhgef2tz3gs2ax6x4hra4a")
+	emptyDirCid        = cid.MustParse("bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354")
+	helloCid           = cid.MustParse("bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4")
+	multiblockCid      = cid.MustParse("bafybeifmrwdb4t6jvidcmyrbnrl775ysmxtqlafqxlnxlk5ky6stdwjm5y")
+	multiblockLeafCids = []cid.Cid{
+		cid.MustParse("bafkreihaoxzpkhfnepifg4mgz7gvb6ir5kku7hbogksdp5cte7y3pcm3xm"),
+		cid.MustParse("bafkreifqcaxd4xz45u5uxupj2gqi5424l7hcc2ycjpxkhgoeuunzl3hi44"),
+		cid.MustParse("bafkreidorojsl53z3otaytaurxxf33kdwgpnedjf2zxdhcv6yu5zsf2p5a"),
+	}
+	subdirCid     = cid.MustParse("bafybeicsa4clyguyftnowfa2kult4tfxxvc262gakazc7wriyaxsz4rami")
+	dagCborDocCid = cid.MustParse("bafyreidykglsfhoixmivffc5uwhcgshx4j465xwqntbmu43nb2dzqwfvae")
+	dagJsonDocCid = cid.MustParse("baguqeerasords4njcts6vs7qvdjfcvgnume4hqohf65zsfguprqphs3icwea")
+	symlinkCid    = cid.MustParse("bafybeiar537gtgwbfdxiqzybddgre3qnehtxmegnc573cpzs3lza53a4la")
+)
+
+// multiblockContent is the content of multiblock.txt.
+var multiblockContent = strings.Repeat("A", 256) + strings.Repeat("B", 256) + strings.Repeat("C", 128)
+
+// dnslinkName is the name that the [namesys.NameSystem] passed to providers
+// resolves to the root of the fixture.
+const dnslinkName = "/ipns/example.net"
+
+// makeBackend loads the fixtures and returns the backend under test.
+func (tp *TestSuite) makeBackend(t *testing.T) gateway.IPFSBackend {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	br, err := car.NewBlockReader(bytes.NewReader(unixfsFixture))
+	require.NoError(t, err)
+	for {
+		blk, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, bs.Put(context.Background(), blk))
+	}
+
+	ns := mockNamesys{dnslinkName: path.FromCid(rootCid)}
+	return tp.MakeBackend(t, bs, ns)
+}
+
+// immutablePath returns the immutable path of the given segments under c.
+func immutablePath(t *testing.T, c cid.Cid, segments ...string) path.ImmutablePath {
+	p, err := path.Join(path.FromCid(c), segments...)
+	require.NoError(t, err)
+	imPath, err := path.NewImmutablePath(p)
+	require.NoError(t, err)
+	return imPath
+}
+
+type mockNamesys map[string]path.Path
+
+func (m mockNamesys) Resolve(ctx context.Context, name string, opts ...nsopts.ResolveOpt) (path.Path, error) {
+	value, ok := m[name]
+	if !ok {
+		return nil, namesys.ErrResolveFailed
+	}
+	return value, nil
+}
+
+func (m mockNamesys) ResolveAsync(ctx context.Context, name string, opts ...nsopts.ResolveOpt) <-chan namesys.Result {
+	out := make(chan namesys.Result, 1)
+	v, err := m.Resolve(ctx, name, opts...)
+	out <- namesys.Result{Path: v, Err: err}
+	close(out)
+	return out
+}
+
+func (m mockNamesys) Publish(ctx context.Context, name crypto.PrivKey, value path.Path, opts ...nsopts.PublishOption) error {
+	return errors.New("not implemented for mockNamesys")
+}
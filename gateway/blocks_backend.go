@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/ipfs/boxo/blockservice"
@@ -16,8 +17,11 @@ import (
 	bsfetcher "github.com/ipfs/boxo/fetcher/impl/blockservice"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
 	ufile "github.com/ipfs/boxo/ipld/unixfs/file"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
 	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/namesys/resolve"
 	"github.com/ipfs/boxo/path"
@@ -62,7 +66,10 @@ type BlocksBackend struct {
 	routing routing.ValueStore
 }
 
-var _ IPFSBackend = (*BlocksBackend)(nil)
+var (
+	_ IPFSBackend           = (*BlocksBackend)(nil)
+	_ IPNSPublishingBackend = (*BlocksBackend)(nil)
+)
 
 type blocksBackendOptions struct {
 	ns namesys.NameSystem
@@ -151,9 +158,9 @@ func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, range
 		return md, nil, err
 	}
 
-	// Only a single range is supported in responses to HTTP Range Requests.
-	// When more than one is passed in the Range header, this library will
-	// return a response for the first one and ignores remaining ones.
+	// The first range positions the returned files. They are seekable, and the
+	// blocks of the remaining ranges, if any, are prefetched for the handler to
+	// seek to them.
 	var ra *ByteRange
 	if len(ranges) > 0 {
 		ra = &ranges[0]
@@ -200,7 +207,15 @@ func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, range
 		if sz < 0 {
 			return ContentPathMetadata{}, nil, fmt.Errorf("directory cumulative DAG size cannot be negative")
 		}
-		return md, NewGetResponseFromDirectoryListing(uint64(sz), dir.EnumLinksAsync(ctx), nil), nil
+		// Paginated listings need the entries in order, the others are
+		// enumerated as fast as possible.
+		var entries <-chan unixfs.LinkResult
+		if page, ok := ctx.Value(DirectoryListingPageKey).(DirectoryListingPage); ok {
+			entries = bb.enumLinksInOrder(ctx, nd, dir, page.After)
+		} else {
+			entries = dir.EnumLinksAsync(ctx)
+		}
+		return md, NewGetResponseFromDirectoryListing(uint64(sz), entries, nil), nil
 	}
 	if file, ok := f.(files.File); ok {
 		fileSize, err := f.Size()
@@ -211,6 +226,9 @@ func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, range
 		if err := seekToRangeStart(file, ra); err != nil {
 			return ContentPathMetadata{}, nil, err
 		}
+		if len(ranges) > 1 {
+			go bb.prefetchRanges(ctx, nd, fileSize, ranges[1:])
+		}
 
 		if s, ok := f.(*files.Symlink); ok {
 			return md, NewGetResponseFromSymlink(s, fileSize), nil
@@ -222,14 +240,137 @@ func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, range
 	return ContentPathMetadata{}, nil, fmt.Errorf("data was not a valid file or directory: %w", ErrInternalServerError) // TODO: should there be a gateway invalid content type to abstract over the various IPLD error types?
 }
 
+// prefetchRanges fetches the blocks of the UnixFS file nd that hold the given
+// ranges, one level of the DAG at a time, until ctx is done.
+func (bb *BlocksBackend) prefetchRanges(ctx context.Context, nd format.Node, size int64, ranges []ByteRange) {
+	if size <= 0 {
+		return
+	}
+
+	// The absolute spans of the ranges, ends included.
+	var spans [][2]uint64
+	for _, ra := range ranges {
+		end := uint64(size) - 1
+		if ra.To != nil && *ra.To >= 0 && uint64(*ra.To) < end {
+			end = uint64(*ra.To)
+		}
+		if ra.From <= end {
+			spans = append(spans, [2]uint64{ra.From, end})
+		}
+	}
+	overlaps := func(from, to uint64) bool {
+		for _, span := range spans {
+			if from <= span[1] && span[0] < to {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The offsets of the nodes of the current level in the file. A node may
+	// appear several times in a file.
+	level := map[cid.Cid][]uint64{nd.Cid(): {0}}
+	nodes := []format.Node{nd}
+	for len(nodes) > 0 {
+		next := map[cid.Cid][]uint64{}
+		for _, n := range nodes {
+			pbnd, ok := n.(*merkledag.ProtoNode)
+			if !ok {
+				// Raw leaves have no children.
+				continue
+			}
+			fsn, err := unixfs.FSNodeFromBytes(pbnd.Data())
+			if err != nil {
+				return
+			}
+			for _, offset := range level[n.Cid()] {
+				offset += uint64(len(fsn.Data()))
+				for i, l := range pbnd.Links() {
+					if i >= fsn.NumChildren() {
+						break
+					}
+					childSize := fsn.BlockSize(i)
+					if overlaps(offset, offset+childSize) {
+						next[l.Cid] = append(next[l.Cid], offset)
+					}
+					offset += childSize
+				}
+			}
+		}
+
+		cids := make([]cid.Cid, 0, len(next))
+		for c := range next {
+			cids = append(cids, c)
+		}
+		nodes = nodes[:0]
+		for res := range bb.dagService.GetMany(ctx, cids) {
+			if res.Err != nil {
+				log.Debugw("failed to prefetch ranges", "cid", nd.Cid(), "error", res.Err)
+				return
+			}
+			nodes = append(nodes, res.Node)
+		}
+		level = next
+	}
+}
+
+// enumLinksInOrder returns the links of dir, whose node is nd, in the order of
+// the directory, which is stable for a given CID so that listings can be
+// paginated. Unlike [uio.Directory.EnumLinksAsync], HAMT shards are walked one
+// at a time, starting from the one holding the link named after, if any.
+func (bb *BlocksBackend) enumLinksInOrder(ctx context.Context, nd format.Node, dir uio.Directory, after string) <-chan unixfs.LinkResult {
+	forEach := dir.ForEachLink
+	if after != "" {
+		if shard, err := hamt.NewHamtFromDag(bb.dagService, nd); err == nil {
+			forEach = func(ctx context.Context, f func(*format.Link) error) error {
+				err := shard.ForEachLinkFrom(ctx, after, f)
+				if errors.Is(err, os.ErrNotExist) {
+					// The handler rejects the pages after a missing entry.
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	out := make(chan unixfs.LinkResult)
+	go func() {
+		defer close(out)
+		err := forEach(ctx, func(l *format.Link) error {
+			select {
+			case out <- unixfs.LinkResult{Link: l}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			select {
+			case out <- unixfs.LinkResult{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
 func (bb *BlocksBackend) GetAll(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, files.Node, error) {
 	md, nd, err := bb.getNode(ctx, path)
 	if err != nil {
 		return md, nil, err
 	}
 
+	// The other limits are enforced by the handler, on the files themselves.
+	dagService := bb.dagService
+	if limits := responseLimitsFromContext(ctx); limits.MaxBlocks > 0 {
+		dagService = &budgetDAGService{
+			DAGService: dagService,
+			budget:     newBlockBudget(ResponseLimits{MaxBlocks: limits.MaxBlocks}, false),
+		}
+	}
+
 	// This code path covers full graph, single file/directory, and range requests
-	n, err := ufile.NewUnixfsFile(ctx, bb.dagService, nd)
+	n, err := ufile.NewUnixfsFile(ctx, dagService, nd)
 	if err != nil {
 		return md, nil, err
 	}
@@ -328,6 +469,11 @@ func (bb *BlocksBackend) GetCAR(ctx context.Context, p path.ImmutablePath, param
 		return ContentPathMetadata{}, nil, fmt.Errorf("path does not have /ipfs/ prefix")
 	}
 
+	limits := responseLimitsFromContext(ctx)
+	if err := bb.checkCarSize(ctx, pathMetadata, params, limits); err != nil {
+		return ContentPathMetadata{}, nil, err
+	}
+
 	r, w := io.Pipe()
 	go func() {
 		cw, err := storage.NewWritable(
@@ -345,8 +491,9 @@ func (bb *BlocksBackend) GetCAR(ctx context.Context, p path.ImmutablePath, param
 		blockGetter := merkledag.NewDAGService(bb.blockService).Session(ctx)
 
 		blockGetter = &nodeGetterToCarExporer{
-			ng: blockGetter,
-			cw: cw,
+			ng:     blockGetter,
+			cw:     cw,
+			budget: newBlockBudget(limits, params.Duplicates.Bool()),
 		}
 
 		// Setup the UnixFS resolver.
@@ -368,6 +515,29 @@ func (bb *BlocksBackend) GetCAR(ctx context.Context, p path.ImmutablePath, param
 	return pathMetadata, r, nil
 }
 
+// checkCarSize fails early if the CAR response for a dag-scope=all request that
+// includes duplicates would exceed the byte limit, as its size is then known from
+// the cumulative size of the requested node.
+func (bb *BlocksBackend) checkCarSize(ctx context.Context, pathMetadata ContentPathMetadata, params CarParams, limits ResponseLimits) error {
+	if limits.MaxBytes == 0 || params.Scope != DagScopeAll || !params.Duplicates.Bool() || len(pathMetadata.LastSegmentRemainder) > 0 {
+		return nil
+	}
+
+	// If the size is unknown, the limit is only enforced while streaming.
+	nd, err := bb.dagService.Get(ctx, pathMetadata.LastSegment.RootCid())
+	if err != nil {
+		return nil
+	}
+	size, err := nd.Size()
+	if err != nil {
+		return nil
+	}
+	if size > uint64(limits.MaxBytes) {
+		return fmt.Errorf("%w: DAG of %d bytes exceeds %d bytes", ErrResponseLimitExceeded, size, limits.MaxBytes)
+	}
+	return nil
+}
+
 // walkGatewaySimpleSelector walks the subgraph described by the path and terminal element parameters
 func walkGatewaySimpleSelector(ctx context.Context, p path.ImmutablePath, params CarParams, lsys *ipld.LinkSystem, pathResolver resolver.Resolver) error {
 	// First resolve the path since we always need to.
@@ -397,10 +567,15 @@ func walkGatewaySimpleSelector(ctx context.Context, p path.ImmutablePath, params
 			return err
 		}
 
+		walkLsys := *lsys
+		if maxDepth := responseLimitsFromContext(ctx).MaxDepth; maxDepth > 0 {
+			walkLsys.StorageReadOpener = depthLimitedOpener(walkLsys.StorageReadOpener, maxDepth)
+		}
+
 		progress := traversal.Progress{
 			Cfg: &traversal.Config{
 				Ctx:                            ctx,
-				LinkSystem:                     *lsys,
+				LinkSystem:                     walkLsys,
 				LinkTargetNodePrototypeChooser: bsfetcher.DefaultPrototypeChooser,
 				LinkVisitOnlyOnce:              !params.Duplicates.Bool(),
 			},
@@ -650,6 +825,23 @@ func (bb *BlocksBackend) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte,
 	return bb.routing.GetValue(ctx, "/ipns/"+string(id))
 }
 
+func (bb *BlocksBackend) PutIPNSRecord(ctx context.Context, c cid.Cid, record []byte) error {
+	if bb.routing == nil {
+		return NewErrorStatusCode(errors.New("IPNS Record publishing is not supported by this gateway"), http.StatusNotImplemented)
+	}
+
+	name, err := ipns.NameFromCid(c)
+	if err != nil {
+		return NewErrorStatusCode(err, http.StatusBadRequest)
+	}
+
+	err = bb.routing.PutValue(ctx, string(name.RoutingKey()), record)
+	if errors.Is(err, routing.ErrNotSupported) {
+		return NewErrorStatusCode(fmt.Errorf("IPNS Record publishing is not supported by this gateway: %w", err), http.StatusNotImplemented)
+	}
+	return err
+}
+
 func (bb *BlocksBackend) GetDNSLinkRecord(ctx context.Context, hostname string) (path.Path, error) {
 	if bb.namesys != nil {
 		p, err := bb.namesys.Resolve(ctx, "/ipns/"+hostname, nsopts.Depth(1))
@@ -722,8 +914,9 @@ func (bb *BlocksBackend) resolvePath(ctx context.Context, p path.Path) (path.Imm
 }
 
 type nodeGetterToCarExporer struct {
-	ng format.NodeGetter
-	cw storage.WritableCar
+	ng     format.NodeGetter
+	cw     storage.WritableCar
+	budget *blockBudget
 }
 
 func (n *nodeGetterToCarExporer) Get(ctx context.Context, c cid.Cid) (format.Node, error) {
@@ -764,11 +957,55 @@ func (n *nodeGetterToCarExporer) GetMany(ctx context.Context, cids []cid.Cid) <-
 }
 
 func (n *nodeGetterToCarExporer) trySendBlock(ctx context.Context, block blocks.Block) error {
+	if err := n.budget.spend(block.Cid(), len(block.RawData())); err != nil {
+		return err
+	}
 	return n.cw.Put(ctx, block.Cid().KeyString(), block.RawData())
 }
 
 var _ format.NodeGetter = (*nodeGetterToCarExporer)(nil)
 
+// budgetDAGService is a [format.DAGService] that accounts for the nodes it gets
+// in a [blockBudget], and fails once it is exceeded.
+type budgetDAGService struct {
+	format.DAGService
+	budget *blockBudget
+}
+
+func (s *budgetDAGService) Get(ctx context.Context, c cid.Cid) (format.Node, error) {
+	nd, err := s.DAGService.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.budget.spend(nd.Cid(), len(nd.RawData())); err != nil {
+		return nil, err
+	}
+
+	return nd, nil
+}
+
+func (s *budgetDAGService) GetMany(ctx context.Context, cids []cid.Cid) <-chan *format.NodeOption {
+	ndCh := s.DAGService.GetMany(ctx, cids)
+	outCh := make(chan *format.NodeOption)
+	go func() {
+		defer close(outCh)
+		for nd := range ndCh {
+			if nd.Err == nil {
+				if err := s.budget.spend(nd.Node.Cid(), len(nd.Node.RawData())); err != nil {
+					nd = &format.NodeOption{Err: err}
+				}
+			}
+			select {
+			case outCh <- nd:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return outCh
+}
+
 type nodeGetterFetcherSingleUseFactory struct {
 	linkSystem   ipld.LinkSystem
 	protoChooser traversal.LinkTargetNodePrototypeChooser
@@ -0,0 +1,330 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/ipld/unixfs/mod"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipld/go-car/v2"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var _ WritableIPFSBackend = (*BlocksBackend)(nil)
+
+// writableCidBuilder is used for content added through the writable gateway
+// that does not inherit the CID prefix of an existing DAG.
+var writableCidBuilder = cid.V1Builder{Codec: cid.DagProtobuf, MhType: mh.SHA2_256}
+
+func (bb *BlocksBackend) AddFile(ctx context.Context, node files.Node) (path.ImmutablePath, error) {
+	nd, err := bb.importNode(ctx, node, writableCidBuilder)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	return path.FromCid(nd.Cid()), nil
+}
+
+func (bb *BlocksBackend) AddCAR(ctx context.Context, r io.Reader) (path.ImmutablePath, error) {
+	br, err := car.NewBlockReader(r, car.WithTrustedCAR(false))
+	if err != nil {
+		return path.ImmutablePath{}, NewErrorStatusCode(fmt.Errorf("invalid CAR: %w", err), http.StatusBadRequest)
+	}
+
+	if len(br.Roots) != 1 {
+		return path.ImmutablePath{}, NewErrorStatusCode(fmt.Errorf("CAR must have exactly one root, got %d", len(br.Roots)), http.StatusBadRequest)
+	}
+	root := br.Roots[0]
+
+	var hasRoot bool
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return path.ImmutablePath{}, NewErrorStatusCode(fmt.Errorf("invalid CAR: %w", err), http.StatusBadRequest)
+		}
+
+		if err := bb.blockService.AddBlock(ctx, blk); err != nil {
+			return path.ImmutablePath{}, err
+		}
+		if blk.Cid().Equals(root) {
+			hasRoot = true
+		}
+	}
+
+	if !hasRoot {
+		return path.ImmutablePath{}, NewErrorStatusCode(fmt.Errorf("CAR root %s is missing from the CAR", root), http.StatusBadRequest)
+	}
+
+	return path.FromCid(root), nil
+}
+
+func (bb *BlocksBackend) Put(ctx context.Context, p path.ImmutablePath, node files.Node) (path.ImmutablePath, error) {
+	segments := p.Segments()[2:]
+	if len(segments) == 0 {
+		return path.ImmutablePath{}, NewErrorStatusCode(errors.New("cannot replace the root of a DAG, use POST to add new content"), http.StatusBadRequest)
+	}
+
+	rootNd, err := bb.dagService.Get(ctx, p.RootCid())
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	newRoot, err := bb.putAt(ctx, rootNd, segments, node)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return path.FromCid(newRoot.Cid()), nil
+}
+
+func (bb *BlocksBackend) Delete(ctx context.Context, p path.ImmutablePath) (path.ImmutablePath, error) {
+	segments := p.Segments()[2:]
+	if len(segments) == 0 {
+		return path.ImmutablePath{}, NewErrorStatusCode(errors.New("cannot delete the root of a DAG"), http.StatusBadRequest)
+	}
+
+	rootNd, err := bb.dagService.Get(ctx, p.RootCid())
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	newRoot, err := bb.deleteAt(ctx, rootNd, segments)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return path.FromCid(newRoot.Cid()), nil
+}
+
+// putAt writes node under the directory dirNd, at the location described by
+// segments, and returns the new version of dirNd.
+func (bb *BlocksBackend) putAt(ctx context.Context, dirNd format.Node, segments []string, node files.Node) (format.Node, error) {
+	dir, err := bb.directoryFromNode(dirNd)
+	if err != nil {
+		return nil, err
+	}
+
+	name := segments[0]
+	child, err := dir.Find(ctx, name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	childExists := err == nil
+
+	var newChild format.Node
+	if len(segments) == 1 {
+		_, isSymlink := node.(*files.Symlink)
+		file, isFile := node.(files.File)
+		if childExists && isFile && !isSymlink && isUnixFSFile(child) {
+			newChild, err = bb.modifyFile(ctx, child, file)
+		} else {
+			newChild, err = bb.importNode(ctx, node, dir.GetCidBuilder())
+		}
+	} else {
+		if !childExists {
+			emptyDir := uio.NewDirectory(bb.dagService)
+			emptyDir.SetCidBuilder(dir.GetCidBuilder())
+			child, err = emptyDir.GetNode()
+			if err != nil {
+				return nil, err
+			}
+		}
+		newChild, err = bb.putAt(ctx, child, segments[1:], node)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dir.AddChild(ctx, name, newChild); err != nil {
+		return nil, err
+	}
+
+	return bb.addDirectoryNode(ctx, dir)
+}
+
+// deleteAt removes the entry described by segments from the directory dirNd,
+// and returns the new version of dirNd.
+func (bb *BlocksBackend) deleteAt(ctx context.Context, dirNd format.Node, segments []string) (format.Node, error) {
+	dir, err := bb.directoryFromNode(dirNd)
+	if err != nil {
+		return nil, err
+	}
+
+	name := segments[0]
+	if len(segments) == 1 {
+		err = dir.RemoveChild(ctx, name)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, NewErrorStatusCode(&resolver.ErrNoLink{Name: name, Node: dirNd.Cid()}, http.StatusNotFound)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return bb.addDirectoryNode(ctx, dir)
+	}
+
+	child, err := dir.Find(ctx, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, NewErrorStatusCode(&resolver.ErrNoLink{Name: name, Node: dirNd.Cid()}, http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	newChild, err := bb.deleteAt(ctx, child, segments[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dir.AddChild(ctx, name, newChild); err != nil {
+		return nil, err
+	}
+
+	return bb.addDirectoryNode(ctx, dir)
+}
+
+func (bb *BlocksBackend) directoryFromNode(nd format.Node) (uio.Directory, error) {
+	dir, err := uio.NewDirectoryFromNode(bb.dagService, nd)
+	if errors.Is(err, uio.ErrNotADir) {
+		return nil, NewErrorStatusCode(fmt.Errorf("%s is not a UnixFS directory", nd.Cid()), http.StatusConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir.SetCidBuilder(nd.Cid().Prefix())
+	return dir, nil
+}
+
+func (bb *BlocksBackend) addDirectoryNode(ctx context.Context, dir uio.Directory) (format.Node, error) {
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if err := bb.dagService.Add(ctx, nd); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+// modifyFile overwrites the contents of the existing UnixFS file nd with the
+// contents of file. Unchanged leaves are preserved.
+func (bb *BlocksBackend) modifyFile(ctx context.Context, nd format.Node, file files.File) (format.Node, error) {
+	dm, err := mod.NewDagModifier(ctx, nd, bb.dagService, chunker.SizeSplitterGen(chunker.DefaultBlockSize))
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(dm, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dm.Truncate(n); err != nil {
+		return nil, err
+	}
+
+	newNd, err := dm.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if err := bb.dagService.Add(ctx, newNd); err != nil {
+		return nil, err
+	}
+	return newNd, nil
+}
+
+// importNode imports a UnixFS file, symlink or directory into the DAG service
+// and returns its root node.
+func (bb *BlocksBackend) importNode(ctx context.Context, node files.Node, builder cid.Builder) (format.Node, error) {
+	switch n := node.(type) {
+	case *files.Symlink:
+		data, err := unixfs.SymlinkData(n.Target)
+		if err != nil {
+			return nil, err
+		}
+		nd := merkledag.NodeWithData(data)
+		if err := nd.SetCidBuilder(builder); err != nil {
+			return nil, err
+		}
+		if err := bb.dagService.Add(ctx, nd); err != nil {
+			return nil, err
+		}
+		return nd, nil
+	case files.File:
+		params := helpers.DagBuilderParams{
+			Dagserv:    bb.dagService,
+			Maxlinks:   helpers.DefaultLinksPerBlock,
+			RawLeaves:  cidVersion(builder) > 0,
+			CidBuilder: builder,
+		}
+		db, err := params.New(chunker.DefaultSplitter(n))
+		if err != nil {
+			return nil, err
+		}
+		return balanced.Layout(db)
+	case files.Directory:
+		dir := uio.NewDirectory(bb.dagService)
+		dir.SetCidBuilder(builder)
+
+		it := n.Entries()
+		for it.Next() {
+			child, err := bb.importNode(ctx, it.Node(), builder)
+			if err != nil {
+				return nil, err
+			}
+			if err := dir.AddChild(ctx, it.Name(), child); err != nil {
+				return nil, err
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+
+		return bb.addDirectoryNode(ctx, dir)
+	default:
+		return nil, NewErrorStatusCode(fmt.Errorf("unsupported file type %T", node), http.StatusBadRequest)
+	}
+}
+
+// isUnixFSFile returns whether nd is a raw block or a dag-pb UnixFS file that
+// can be modified with a [mod.DagModifier].
+func isUnixFSFile(nd format.Node) bool {
+	switch nd := nd.(type) {
+	case *merkledag.RawNode:
+		return true
+	case *merkledag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return false
+		}
+		return fsn.Type() == unixfs.TFile || fsn.Type() == unixfs.TRaw
+	default:
+		return false
+	}
+}
+
+func cidVersion(builder cid.Builder) uint64 {
+	switch b := builder.(type) {
+	case cid.Prefix:
+		return b.Version
+	case cid.V0Builder:
+		return 0
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,519 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultMutableCacheTTL is the time during which the resolution of a
+	// mutable path is cached, when its TTL is unknown.
+	DefaultMutableCacheTTL = time.Minute
+
+	// DefaultMaxMutableCacheTTL is the maximum time during which the resolution
+	// of a mutable path is cached, regardless of its TTL.
+	DefaultMaxMutableCacheTTL = time.Hour
+
+	// DefaultStaleWhileRevalidate is the time after expiry during which the
+	// resolution of a mutable path is still served, while it is refreshed in
+	// the background.
+	DefaultStaleWhileRevalidate = 30 * time.Second
+
+	// DefaultMutableCacheSize is the default number of cached resolutions of
+	// mutable paths.
+	DefaultMutableCacheSize = 1024
+
+	// DefaultImmutableCacheSize is the default number of cached metadata of
+	// immutable paths, for each of ResolvePath and Head.
+	DefaultImmutableCacheSize = 16384
+
+	// cachingBackendRefreshTimeout is the timeout of background refreshes.
+	cachingBackendRefreshTimeout = time.Minute
+
+	// headCacheStartingBytes is the number of bytes kept from the beginning of
+	// files in Head responses, which is enough for content type sniffing.
+	headCacheStartingBytes = 3072
+)
+
+type cachingBackendOptions struct {
+	defaultTTL           time.Duration
+	maxTTL               time.Duration
+	staleWhileRevalidate time.Duration
+	mutableCacheSize     int
+	immutableCacheSize   int
+}
+
+// CachingBackendOption is an option for [NewCachingBackend].
+type CachingBackendOption func(options *cachingBackendOptions) error
+
+// WithMutableCacheTTL sets the time during which the resolution of mutable
+// paths with an unknown TTL, such as DNSLink names, are cached, and the maximum
+// time during which any resolution is cached. Defaults to
+// [DefaultMutableCacheTTL] and [DefaultMaxMutableCacheTTL].
+func WithMutableCacheTTL(defaultTTL, maxTTL time.Duration) CachingBackendOption {
+	return func(opts *cachingBackendOptions) error {
+		if defaultTTL < 0 || maxTTL < 0 {
+			return errors.New("mutable cache TTLs must not be negative")
+		}
+		opts.defaultTTL = defaultTTL
+		opts.maxTTL = maxTTL
+		return nil
+	}
+}
+
+// WithStaleWhileRevalidate sets the time after expiry during which the
+// resolution of a mutable path is still served, while it is refreshed in the
+// background. Zero disables it. Defaults to [DefaultStaleWhileRevalidate].
+func WithStaleWhileRevalidate(d time.Duration) CachingBackendOption {
+	return func(opts *cachingBackendOptions) error {
+		if d < 0 {
+			return errors.New("stale-while-revalidate duration must not be negative")
+		}
+		opts.staleWhileRevalidate = d
+		return nil
+	}
+}
+
+// WithCacheSizes sets the maximum number of entries in the cache of mutable
+// paths, and in each of the caches of immutable paths. Defaults to
+// [DefaultMutableCacheSize] and [DefaultImmutableCacheSize].
+func WithCacheSizes(mutable, immutable int) CachingBackendOption {
+	return func(opts *cachingBackendOptions) error {
+		if mutable <= 0 || immutable <= 0 {
+			return errors.New("cache sizes must be positive")
+		}
+		opts.mutableCacheSize = mutable
+		opts.immutableCacheSize = immutable
+		return nil
+	}
+}
+
+type mutableCacheEntry struct {
+	value     path.ImmutablePath
+	expiresAt time.Time
+}
+
+type headCacheEntry struct {
+	md            ContentPathMetadata
+	bytesSize     int64
+	startingBytes []byte
+	isFile        bool
+	isSymLink     bool
+	isDir         bool
+}
+
+func (e headCacheEntry) response() *HeadResponse {
+	resp := &HeadResponse{
+		bytesSize: e.bytesSize,
+		isFile:    e.isFile,
+		isSymLink: e.isSymLink,
+		isDir:     e.isDir,
+	}
+	if e.startingBytes != nil {
+		resp.startingBytes = files.NewBytesFile(e.startingBytes)
+	}
+	return resp
+}
+
+type cachingBackend struct {
+	backend IPFSBackend
+	opts    cachingBackendOptions
+	now     func() time.Time
+
+	mutable  *lru.Cache[string, mutableCacheEntry]
+	resolved *lru.Cache[string, ContentPathMetadata]
+	heads    *lru.Cache[string, headCacheEntry]
+	group    singleflight.Group
+
+	// noIPNSRecords is set once the wrapped backend reported that it cannot
+	// serve IPNS records, which are then no longer fetched to learn TTLs.
+	noIPNSRecords atomic.Bool
+
+	requestsMetric *prometheus.CounterVec
+}
+
+// NewCachingBackend wraps an [IPFSBackend] with caches for the resolution of
+// paths.
+//
+// Mutable paths resolved with ResolveMutable are cached for the TTL of their
+// IPNS record, which is fetched once with GetIPNSRecord and also gives the
+// resolved value. DNSLink names are cached for [DefaultMutableCacheTTL] rather
+// than the TTL of their TXT record, as the backend does not expose the TTL of
+// DNS answers. Once expired, they are still served for the duration set with
+// [WithStaleWhileRevalidate], while they are refreshed in the background.
+//
+// The metadata returned by ResolvePath and Head for immutable paths cannot
+// change, and is cached until evicted from the size-bounded caches.
+//
+// If the given backend implements [WritableIPFSBackend] or
+// [IPNSPublishingBackend], so does the returned backend. Records published
+// through it evict the cached resolution of their name.
+func NewCachingBackend(backend IPFSBackend, opts ...CachingBackendOption) (IPFSBackend, error) {
+	compiledOptions := cachingBackendOptions{
+		defaultTTL:           DefaultMutableCacheTTL,
+		maxTTL:               DefaultMaxMutableCacheTTL,
+		staleWhileRevalidate: DefaultStaleWhileRevalidate,
+		mutableCacheSize:     DefaultMutableCacheSize,
+		immutableCacheSize:   DefaultImmutableCacheSize,
+	}
+	for _, o := range opts {
+		if err := o(&compiledOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	cb, err := newCachingBackend(backend, compiledOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only expose the optional interfaces that the wrapped backend implements,
+	// as the handler enables the matching HTTP methods based on them.
+	wb, writable := backend.(WritableIPFSBackend)
+	pb, publishing := backend.(IPNSPublishingBackend)
+	switch {
+	case writable && publishing:
+		return &writablePublishingCachingBackend{&publishingCachingBackend{cb, pb}, wb}, nil
+	case writable:
+		return &writableCachingBackend{cb, wb}, nil
+	case publishing:
+		return &publishingCachingBackend{cb, pb}, nil
+	default:
+		return cb, nil
+	}
+}
+
+func newCachingBackend(backend IPFSBackend, opts cachingBackendOptions) (*cachingBackend, error) {
+	mutable, err := lru.New[string, mutableCacheEntry](opts.mutableCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := lru.New[string, ContentPathMetadata](opts.immutableCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	heads, err := lru.New[string, headCacheEntry](opts.immutableCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachingBackend{
+		backend:  backend,
+		opts:     opts,
+		now:      time.Now,
+		mutable:  mutable,
+		resolved: resolved,
+		heads:    heads,
+		requestsMetric: newCounterMetric(
+			"gw_backend_cache_requests_total",
+			"The number of lookups in the caches of the caching backend, per cache and result.",
+			"cache", "result",
+		),
+	}, nil
+}
+
+func (b *cachingBackend) updateCacheMetric(cache, result string) {
+	b.requestsMetric.WithLabelValues(cache, result).Inc()
+}
+
+func (b *cachingBackend) Get(ctx context.Context, path path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	return b.backend.Get(ctx, path, ranges...)
+}
+
+func (b *cachingBackend) GetAll(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, files.Node, error) {
+	return b.backend.GetAll(ctx, path)
+}
+
+func (b *cachingBackend) GetBlock(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, files.File, error) {
+	return b.backend.GetBlock(ctx, path)
+}
+
+func (b *cachingBackend) Head(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, *HeadResponse, error) {
+	key := path.String()
+	if e, ok := b.heads.Get(key); ok {
+		b.updateCacheMetric("head", "hit")
+		return e.md, e.response(), nil
+	}
+	b.updateCacheMetric("head", "miss")
+
+	md, resp, err := b.backend.Head(ctx, path)
+	if err != nil {
+		return md, resp, err
+	}
+	defer resp.Close()
+
+	e := headCacheEntry{
+		md:        md,
+		bytesSize: resp.bytesSize,
+		isFile:    resp.isFile,
+		isSymLink: resp.isSymLink,
+		isDir:     resp.isDir,
+	}
+	if resp.startingBytes != nil {
+		e.startingBytes, err = io.ReadAll(io.LimitReader(resp.startingBytes, headCacheStartingBytes))
+		if err != nil {
+			return ContentPathMetadata{}, nil, err
+		}
+	}
+
+	b.heads.Add(key, e)
+	return md, e.response(), nil
+}
+
+func (b *cachingBackend) ResolvePath(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, error) {
+	key := path.String()
+	if md, ok := b.resolved.Get(key); ok {
+		b.updateCacheMetric("resolve_path", "hit")
+		return md, nil
+	}
+	b.updateCacheMetric("resolve_path", "miss")
+
+	md, err := b.backend.ResolvePath(ctx, path)
+	if err != nil {
+		return md, err
+	}
+
+	b.resolved.Add(key, md)
+	return md, nil
+}
+
+func (b *cachingBackend) GetCAR(ctx context.Context, path path.ImmutablePath, params CarParams) (ContentPathMetadata, io.ReadCloser, error) {
+	return b.backend.GetCAR(ctx, path, params)
+}
+
+func (b *cachingBackend) IsCached(ctx context.Context, path path.Path) bool {
+	return b.backend.IsCached(ctx, path)
+}
+
+func (b *cachingBackend) GetIPNSRecord(ctx context.Context, cid cid.Cid) ([]byte, error) {
+	return b.backend.GetIPNSRecord(ctx, cid)
+}
+
+// evictIPNSName removes the cached resolution of the IPNS name encoded in c.
+func (b *cachingBackend) evictIPNSName(c cid.Cid) {
+	// The name may have been requested with any of its encodings.
+	keys := []string{c.String()}
+	if name, err := ipns.NameFromCid(c); err == nil {
+		keys = append(keys, name.String(), name.Peer().String())
+	}
+	for _, key := range keys {
+		b.mutable.Remove("/ipns/" + key)
+	}
+}
+
+func (b *cachingBackend) ResolveMutable(ctx context.Context, p path.Path) (path.ImmutablePath, error) {
+	segments := p.Segments()
+	if p.Namespace() != path.IPNSNamespace || len(segments) < 2 {
+		return b.backend.ResolveMutable(ctx, p)
+	}
+
+	// Only the name is resolved, the remainder of the path is appended to the
+	// resolved value.
+	root, err := path.NewPathFromSegments(segments[:2]...)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	key := root.String()
+
+	if e, ok := b.mutable.Get(key); ok {
+		now := b.now()
+		if now.Before(e.expiresAt) {
+			b.updateCacheMetric("mutable", "hit")
+			return joinImmutablePath(e.value, segments[2:])
+		}
+		if now.Before(e.expiresAt.Add(b.opts.staleWhileRevalidate)) {
+			b.updateCacheMetric("mutable", "stale")
+			b.group.DoChan(key, func() (any, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), cachingBackendRefreshTimeout)
+				defer cancel()
+				return b.resolveMutableRoot(ctx, root)
+			})
+			return joinImmutablePath(e.value, segments[2:])
+		}
+	}
+	b.updateCacheMetric("mutable", "miss")
+
+	// The resolution is shared by concurrent callers, so it must not be
+	// canceled with the context of the first one. Each caller waits for it
+	// until its own context is done.
+	ch := b.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), cachingBackendRefreshTimeout)
+		defer cancel()
+		return b.resolveMutableRoot(ctx, root)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return path.ImmutablePath{}, res.Err
+		}
+		return joinImmutablePath(res.Val.(path.ImmutablePath), segments[2:])
+	case <-ctx.Done():
+		return path.ImmutablePath{}, ctx.Err()
+	}
+}
+
+// resolveMutableRoot resolves an /ipns/{name} path, and caches the result for
+// its TTL.
+func (b *cachingBackend) resolveMutableRoot(ctx context.Context, root path.Path) (path.ImmutablePath, error) {
+	value, ttl, err := b.resolveMutableName(ctx, root)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	if ttl > b.opts.maxTTL {
+		ttl = b.opts.maxTTL
+	}
+	if ttl > 0 {
+		b.mutable.Add(root.String(), mutableCacheEntry{value: value, expiresAt: b.now().Add(ttl)})
+	} else {
+		b.mutable.Remove(root.String())
+	}
+	return value, nil
+}
+
+// resolveMutableName resolves an /ipns/{name} path and returns its TTL.
+//
+// IPNS names are resolved from their record, which is fetched once and gives
+// both the value and the TTL. DNSLink names, and IPNS names whose record cannot
+// be fetched, are resolved with the wrapped backend and get the default TTL, as
+// neither ResolveMutable nor GetDNSLinkRecord expose the TTL of DNS answers.
+func (b *cachingBackend) resolveMutableName(ctx context.Context, root path.Path) (path.ImmutablePath, time.Duration, error) {
+	if name, err := ipns.NameFromString(root.Segments()[1]); err == nil && !b.noIPNSRecords.Load() {
+		value, ttl, err := b.resolveIPNSRecord(ctx, name)
+		if err == nil {
+			return value, ttl, nil
+		}
+		if isErrNotSupported(err) {
+			// Do not try again with a backend that cannot serve records.
+			b.noIPNSRecords.Store(true)
+		}
+		log.Debugw("failed to resolve IPNS record, resolving with backend", "name", name, "error", err)
+	}
+
+	value, err := b.backend.ResolveMutable(ctx, root)
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+	return value, b.opts.defaultTTL, nil
+}
+
+// resolveIPNSRecord fetches and validates the IPNS record of the given name,
+// and returns its value and TTL.
+func (b *cachingBackend) resolveIPNSRecord(ctx context.Context, name ipns.Name) (path.ImmutablePath, time.Duration, error) {
+	raw, err := b.backend.GetIPNSRecord(ctx, name.Cid())
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+
+	rec, err := ipns.UnmarshalRecord(raw)
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+	if err := ipns.ValidateWithName(rec, name); err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+
+	ttl, err := rec.TTL()
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+
+	// Never cache beyond the validity of the record.
+	eol, err := rec.Validity()
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+	if untilEOL := eol.Sub(b.now()); untilEOL < ttl {
+		ttl = untilEOL
+	}
+
+	value, err := rec.Value()
+	if err != nil {
+		return path.ImmutablePath{}, 0, err
+	}
+	if value.Namespace() == path.IPFSNamespace {
+		resolved, err := path.NewImmutablePath(value)
+		return resolved, ttl, err
+	}
+
+	// The record points to another mutable path, which is resolved with the
+	// wrapped backend rather than through the cache, so that cycles between
+	// names cannot wait on each other.
+	resolved, err := b.backend.ResolveMutable(ctx, value)
+	return resolved, ttl, err
+}
+
+// isErrNotSupported returns true if the backend cannot serve IPNS records.
+func isErrNotSupported(err error) bool {
+	if errors.Is(err, routing.ErrNotSupported) {
+		return true
+	}
+	var statusErr *ErrorStatusCode
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotImplemented
+}
+
+func (b *cachingBackend) GetDNSLinkRecord(ctx context.Context, fqdn string) (path.Path, error) {
+	return b.backend.GetDNSLinkRecord(ctx, fqdn)
+}
+
+var _ IPFSBackend = (*cachingBackend)(nil)
+
+func joinImmutablePath(p path.ImmutablePath, segments []string) (path.ImmutablePath, error) {
+	if len(segments) == 0 {
+		return p, nil
+	}
+
+	joined, err := path.Join(p, segments...)
+	if err != nil {
+		return path.ImmutablePath{}, fmt.Errorf("failed to join resolved path: %w", err)
+	}
+	return path.NewImmutablePath(joined)
+}
+
+type writableCachingBackend struct {
+	*cachingBackend
+	WritableIPFSBackend
+}
+
+var _ WritableIPFSBackend = (*writableCachingBackend)(nil)
+
+type publishingCachingBackend struct {
+	*cachingBackend
+	publishing IPNSPublishingBackend
+}
+
+var _ IPNSPublishingBackend = (*publishingCachingBackend)(nil)
+
+// PutIPNSRecord publishes the record with the wrapped backend, and evicts the
+// cached resolution of the name.
+func (b *publishingCachingBackend) PutIPNSRecord(ctx context.Context, c cid.Cid, record []byte) error {
+	if err := b.publishing.PutIPNSRecord(ctx, c, record); err != nil {
+		return err
+	}
+	b.evictIPNSName(c)
+	return nil
+}
+
+type writablePublishingCachingBackend struct {
+	*publishingCachingBackend
+	WritableIPFSBackend
+}
+
+var (
+	_ WritableIPFSBackend   = (*writablePublishingCachingBackend)(nil)
+	_ IPNSPublishingBackend = (*writablePublishingCachingBackend)(nil)
+)
@@ -0,0 +1,319 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingBackend struct {
+	IPFSBackend
+	ipnsRecord []byte
+	unblock    chan struct{}
+
+	resolveMutableCalls atomic.Int64
+	getIPNSRecordCalls  atomic.Int64
+	resolvePathCalls    atomic.Int64
+	headCalls           atomic.Int64
+}
+
+func (b *countingBackend) ResolveMutable(ctx context.Context, p path.Path) (path.ImmutablePath, error) {
+	b.resolveMutableCalls.Add(1)
+	if b.unblock != nil {
+		<-b.unblock
+	}
+	return b.IPFSBackend.ResolveMutable(ctx, p)
+}
+
+func (b *countingBackend) ResolvePath(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, error) {
+	b.resolvePathCalls.Add(1)
+	return b.IPFSBackend.ResolvePath(ctx, p)
+}
+
+func (b *countingBackend) Head(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, *HeadResponse, error) {
+	b.headCalls.Add(1)
+	return b.IPFSBackend.Head(ctx, p)
+}
+
+func (b *countingBackend) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte, error) {
+	b.getIPNSRecordCalls.Add(1)
+	if b.ipnsRecord != nil {
+		return b.ipnsRecord, nil
+	}
+	return b.IPFSBackend.GetIPNSRecord(ctx, c)
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCachingBackend(t *testing.T, backend IPFSBackend, opts ...CachingBackendOption) (*cachingBackend, *fakeClock) {
+	b, err := NewCachingBackend(backend, opts...)
+	require.NoError(t, err)
+
+	cb := b.(*cachingBackend)
+	clock := &fakeClock{now: time.Now()}
+	cb.now = clock.Now
+	return cb, clock
+}
+
+func TestCachingBackendResolveMutable(t *testing.T) {
+	ctx := context.Background()
+
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/example.net"] = path.FromCid(root)
+
+	counting := &countingBackend{IPFSBackend: backend}
+	cb, clock := newTestCachingBackend(t, counting, WithMutableCacheTTL(time.Minute, time.Hour), WithStaleWhileRevalidate(time.Minute))
+
+	resolve := func(t *testing.T, p string) path.ImmutablePath {
+		mutablePath, err := path.NewPath(p)
+		require.NoError(t, err)
+		resolved, err := cb.ResolveMutable(ctx, mutablePath)
+		require.NoError(t, err)
+		return resolved
+	}
+
+	t.Run("Caches for the default TTL", func(t *testing.T) {
+		assert.Equal(t, path.FromCid(root).String()+"/subdir", resolve(t, "/ipns/example.net/subdir").String())
+		assert.Equal(t, path.FromCid(root).String(), resolve(t, "/ipns/example.net").String())
+		assert.EqualValues(t, 1, counting.resolveMutableCalls.Load())
+	})
+
+	t.Run("Serves stale value while revalidating", func(t *testing.T) {
+		newValue, err := path.Join(path.FromCid(root), "subdir")
+		require.NoError(t, err)
+		backend.namesys["/ipns/example.net"] = newValue
+
+		clock.Advance(90 * time.Second)
+		assert.Equal(t, path.FromCid(root).String(), resolve(t, "/ipns/example.net").String())
+
+		assert.Eventually(t, func() bool {
+			return counting.resolveMutableCalls.Load() == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return resolve(t, "/ipns/example.net/fnord").String() == newValue.String()+"/fnord"
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.EqualValues(t, 2, counting.resolveMutableCalls.Load())
+	})
+
+	t.Run("Resolves again once stale value expired", func(t *testing.T) {
+		clock.Advance(3 * time.Minute)
+		resolve(t, "/ipns/example.net")
+		assert.EqualValues(t, 3, counting.resolveMutableCalls.Load())
+	})
+
+	t.Run("Does not cache immutable paths", func(t *testing.T) {
+		assert.Equal(t, path.FromCid(root).String(), resolve(t, path.FromCid(root).String()).String())
+		assert.EqualValues(t, 4, counting.resolveMutableCalls.Load())
+	})
+}
+
+func TestCachingBackendResolveMutableCanceled(t *testing.T) {
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/example.net"] = path.FromCid(root)
+
+	counting := &countingBackend{IPFSBackend: backend, unblock: make(chan struct{})}
+	cb, _ := newTestCachingBackend(t, counting)
+
+	p, err := path.NewPath("/ipns/example.net")
+	require.NoError(t, err)
+
+	// The first caller gives up, but the shared resolution goes on.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cb.ResolveMutable(ctx, p)
+		errCh <- err
+	}()
+	require.Eventually(t, func() bool {
+		return counting.resolveMutableCalls.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+
+	close(counting.unblock)
+	require.Eventually(t, func() bool {
+		_, ok := cb.mutable.Get("/ipns/example.net")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resolved, err := cb.ResolveMutable(context.Background(), p)
+	require.NoError(t, err)
+	assert.Equal(t, path.FromCid(root).String(), resolved.String())
+	assert.EqualValues(t, 1, counting.resolveMutableCalls.Load())
+}
+
+func TestCachingBackendIPNSRecordTTL(t *testing.T) {
+	ctx := context.Background()
+
+	sk, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid)
+
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/"+name.String()] = path.FromCid(root)
+
+	rec, err := ipns.NewRecord(sk, path.FromCid(root), 1, time.Now().Add(24*time.Hour), 5*time.Minute)
+	require.NoError(t, err)
+	raw, err := ipns.MarshalRecord(rec)
+	require.NoError(t, err)
+
+	counting := &countingBackend{IPFSBackend: backend, ipnsRecord: raw}
+	cb, clock := newTestCachingBackend(t, counting, WithMutableCacheTTL(time.Minute, time.Hour), WithStaleWhileRevalidate(0))
+
+	p, err := path.NewPath("/ipns/" + name.String())
+	require.NoError(t, err)
+
+	_, err = cb.ResolveMutable(ctx, p)
+	require.NoError(t, err)
+
+	// Past the default TTL, but within the TTL of the record.
+	clock.Advance(2 * time.Minute)
+	resolved, err := cb.ResolveMutable(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, path.FromCid(root).String(), resolved.String())
+	assert.EqualValues(t, 1, counting.getIPNSRecordCalls.Load())
+
+	clock.Advance(4 * time.Minute)
+	_, err = cb.ResolveMutable(ctx, p)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, counting.getIPNSRecordCalls.Load())
+
+	// The record gives both the value and the TTL, so it is not fetched again
+	// to resolve the name.
+	assert.EqualValues(t, 0, counting.resolveMutableCalls.Load())
+}
+
+func TestCachingBackendIPNSRecordNotSupported(t *testing.T) {
+	ctx := context.Background()
+
+	sk, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid)
+
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/"+name.String()] = path.FromCid(root)
+
+	counting := &countingBackend{IPFSBackend: backend}
+	cb, clock := newTestCachingBackend(t, counting, WithMutableCacheTTL(time.Minute, time.Hour), WithStaleWhileRevalidate(0))
+
+	p, err := path.NewPath("/ipns/" + name.String())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		resolved, err := cb.ResolveMutable(ctx, p)
+		require.NoError(t, err)
+		assert.Equal(t, path.FromCid(root).String(), resolved.String())
+		clock.Advance(2 * time.Minute)
+	}
+
+	// Falls back to the default TTL, and does not ask again for records the
+	// backend cannot serve.
+	assert.EqualValues(t, 3, counting.resolveMutableCalls.Load())
+	assert.EqualValues(t, 1, counting.getIPNSRecordCalls.Load())
+}
+
+func TestCachingBackendImmutable(t *testing.T) {
+	ctx := context.Background()
+
+	backend, root := newMockBackend(t, "fixtures.car")
+	counting := &countingBackend{IPFSBackend: backend}
+	cb, _ := newTestCachingBackend(t, counting)
+
+	p, err := path.Join(path.FromCid(root), "subdir", "fnord")
+	require.NoError(t, err)
+	imPath, err := path.NewImmutablePath(p)
+	require.NoError(t, err)
+
+	t.Run("ResolvePath", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			md, err := cb.ResolvePath(ctx, imPath)
+			require.NoError(t, err)
+			assert.NotEqual(t, root, md.LastSegment.RootCid())
+		}
+		assert.EqualValues(t, 1, counting.resolvePathCalls.Load())
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, resp, err := cb.Head(ctx, imPath)
+			require.NoError(t, err)
+			assert.True(t, resp.isFile)
+			assert.EqualValues(t, 5, resp.bytesSize)
+
+			data, err := io.ReadAll(resp.startingBytes)
+			require.NoError(t, err)
+			assert.Equal(t, "fnord", string(data))
+			require.NoError(t, resp.Close())
+		}
+		assert.EqualValues(t, 1, counting.headCalls.Load())
+	})
+
+	t.Run("Errors are not cached", func(t *testing.T) {
+		missing, err := path.Join(path.FromCid(root), "missing")
+		require.NoError(t, err)
+		imMissing, err := path.NewImmutablePath(missing)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = cb.ResolvePath(ctx, imMissing)
+			require.Error(t, err)
+		}
+		assert.EqualValues(t, 3, counting.resolvePathCalls.Load())
+	})
+}
+
+func TestCachingBackendWritable(t *testing.T) {
+	b, err := NewCachingBackend(newWritableBlocksBackend(t))
+	require.NoError(t, err)
+	assert.Implements(t, (*WritableIPFSBackend)(nil), b)
+
+	backend, _ := newMockBackend(t, "fixtures.car")
+	b, err = NewCachingBackend(backend)
+	require.NoError(t, err)
+	_, ok := b.(WritableIPFSBackend)
+	assert.False(t, ok)
+}
+
+func TestCachingBackendIPNSPublishing(t *testing.T) {
+	backend, _ := newMockBackend(t, "fixtures.car")
+	b, err := NewCachingBackend(backend)
+	require.NoError(t, err)
+	_, ok := b.(IPNSPublishingBackend)
+	assert.False(t, ok)
+
+	b, err = NewCachingBackend(&ipnsPublishingMockBackend{mockBackend: backend, records: map[cid.Cid][]byte{}})
+	require.NoError(t, err)
+	assert.Implements(t, (*IPNSPublishingBackend)(nil), b)
+	_, ok = b.(WritableIPFSBackend)
+	assert.False(t, ok)
+}
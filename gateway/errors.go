@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,18 +10,25 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ipfs/boxo/denylist"
 	"github.com/ipfs/boxo/gateway/assets"
 	"github.com/ipfs/boxo/path/resolver"
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime/datamodel"
 )
 
+const problemJSONContentType = "application/problem+json"
+
 var (
 	ErrInternalServerError = NewErrorStatusCodeFromStatus(http.StatusInternalServerError)
 	ErrGatewayTimeout      = NewErrorStatusCodeFromStatus(http.StatusGatewayTimeout)
 	ErrBadGateway          = NewErrorStatusCodeFromStatus(http.StatusBadGateway)
 	ErrServiceUnavailable  = NewErrorStatusCodeFromStatus(http.StatusServiceUnavailable)
 	ErrTooManyRequests     = NewErrorStatusCodeFromStatus(http.StatusTooManyRequests)
+
+	// ErrResponseLimitExceeded is returned when a response would exceed the
+	// [ResponseLimits] of the request.
+	ErrResponseLimitExceeded = errors.New("response limit exceeded")
 )
 
 // ErrorRetryAfter wraps any error with "retry after" hint. When an error of this type
@@ -127,9 +135,99 @@ func (e *ErrorStatusCode) Unwrap() error {
 	return e.Err
 }
 
+// ErrorNameResolution wraps an error that occurred while resolving a mutable
+// path, such as an IPNS name or a DNSLink, into an immutable path.
+type ErrorNameResolution struct {
+	Path string
+	Err  error
+}
+
+func NewErrorNameResolution(path string, err error) *ErrorNameResolution {
+	return &ErrorNameResolution{
+		Path: path,
+		Err:  err,
+	}
+}
+
+func (e *ErrorNameResolution) Is(err error) bool {
+	switch err.(type) {
+	case *ErrorNameResolution:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *ErrorNameResolution) Error() string {
+	return fmt.Sprintf("failed to resolve %s: %v", e.Path, e.Err)
+}
+
+func (e *ErrorNameResolution) Unwrap() error {
+	return e.Err
+}
+
+// Problem types of the application/problem+json error responses. They identify
+// the class of a failure, and do not change between releases.
+const (
+	// ProblemTypeNotFound is the type of errors for paths that do not exist,
+	// such as a missing link.
+	ProblemTypeNotFound = "urn:ipfs:gateway:problem:not-found"
+	// ProblemTypeInvalidCID is the type of errors for invalid CIDs.
+	ProblemTypeInvalidCID = "urn:ipfs:gateway:problem:invalid-cid"
+	// ProblemTypeNameResolution is the type of errors for IPNS names and
+	// DNSLinks that could not be resolved.
+	ProblemTypeNameResolution = "urn:ipfs:gateway:problem:name-resolution"
+	// ProblemTypeTimeout is the type of errors for requests that timed out.
+	ProblemTypeTimeout = "urn:ipfs:gateway:problem:timeout"
+	// ProblemTypeBlocked is the type of errors for content blocked by the
+	// gateway [Config.Denylist].
+	ProblemTypeBlocked = "urn:ipfs:gateway:problem:blocked"
+	// ProblemTypeRetryAfter is the type of errors for requests that should be
+	// retried later, as indicated by the Retry-After header, if any.
+	ProblemTypeRetryAfter = "urn:ipfs:gateway:problem:retry-after"
+	// ProblemTypeResponseLimitExceeded is the type of errors for responses that
+	// exceed the [Config.ResponseLimits].
+	ProblemTypeResponseLimitExceeded = "urn:ipfs:gateway:problem:response-limit-exceeded"
+)
+
+var problemTitles = map[string]string{
+	ProblemTypeNotFound:              "Content not found",
+	ProblemTypeInvalidCID:            "Invalid CID",
+	ProblemTypeNameResolution:        "Name resolution failed",
+	ProblemTypeTimeout:               "Request timed out",
+	ProblemTypeBlocked:               "Content blocked",
+	ProblemTypeRetryAfter:            "Retry later",
+	ProblemTypeResponseLimitExceeded: "Response limit exceeded",
+}
+
+// Problem is an [RFC 9457] problem details object: the body of the error
+// responses to requests that accept application/problem+json.
+//
+// [RFC 9457]: https://www.rfc-editor.org/rfc/rfc9457
+type Problem struct {
+	// Type is one of the ProblemType constants, or "about:blank" for errors
+	// that are only described by their status code.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	// RetryAfter is the number of seconds to wait before retrying the request,
+	// for [ProblemTypeRetryAfter] errors.
+	RetryAfter int `json:"retryAfter,omitempty"`
+
+	// Link and Node are the name of the missing link and the CID of the node
+	// it was looked up in, for [ProblemTypeNotFound] errors caused by a missing
+	// link.
+	Link string `json:"link,omitempty"`
+	Node string `json:"node,omitempty"`
+}
+
 func webError(w http.ResponseWriter, r *http.Request, c *Config, err error, defaultCode int) {
 	code := defaultCode
 
+	problem := Problem{Type: "about:blank"}
+
 	// Pass Retry-After hint to the client
 	var era *ErrorRetryAfter
 	if errors.As(err, &era) {
@@ -139,18 +237,34 @@ func webError(w http.ResponseWriter, r *http.Request, c *Config, err error, defa
 			if code != http.StatusTooManyRequests && code != http.StatusServiceUnavailable {
 				code = http.StatusTooManyRequests
 			}
+			problem.RetryAfter, _ = strconv.Atoi(era.RetryAfterHeader())
 		}
 		err = era.Unwrap()
 	}
 
 	// Handle status code
+	var errNoLink *resolver.ErrNoLink
 	switch {
 	case errors.Is(err, &cid.ErrInvalidCid{}):
 		code = http.StatusBadRequest
+		problem.Type = ProblemTypeInvalidCID
 	case isErrNotFound(err):
 		code = http.StatusNotFound
+		problem.Type = ProblemTypeNotFound
+		if errors.As(err, &errNoLink) {
+			problem.Link, problem.Node = errNoLink.Name, errNoLink.Node.String()
+		}
+	case errors.Is(err, denylist.ErrBlocked):
+		code = http.StatusGone
+		problem.Type = ProblemTypeBlocked
+	case errors.Is(err, ErrResponseLimitExceeded):
+		code = http.StatusRequestEntityTooLarge
+		problem.Type = ProblemTypeResponseLimitExceeded
 	case errors.Is(err, context.DeadlineExceeded):
 		code = http.StatusGatewayTimeout
+		problem.Type = ProblemTypeTimeout
+	case errors.Is(err, &ErrorNameResolution{}):
+		problem.Type = ProblemTypeNameResolution
 	}
 
 	// Handle explicit code in ErrorResponse
@@ -159,8 +273,28 @@ func webError(w http.ResponseWriter, r *http.Request, c *Config, err error, defa
 		code = gwErr.StatusCode
 	}
 
+	switch {
+	case era != nil || code == http.StatusTooManyRequests:
+		problem.Type = ProblemTypeRetryAfter
+	case code == http.StatusGatewayTimeout:
+		problem.Type = ProblemTypeTimeout
+	}
+
 	acceptsHTML := !c.DisableHTMLErrors && strings.Contains(r.Header.Get("Accept"), "text/html")
-	if acceptsHTML {
+	switch {
+	case strings.Contains(r.Header.Get("Accept"), problemJSONContentType):
+		problem.Status = code
+		problem.Detail = err.Error()
+		problem.Title = problemTitles[problem.Type]
+		if problem.Title == "" {
+			problem.Title = http.StatusText(code)
+		}
+
+		w.Header().Set("Content-Type", problemJSONContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(problem)
+	case acceptsHTML:
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(code)
 		_ = assets.ErrorTemplate.Execute(w, assets.ErrorTemplateData{
@@ -171,7 +305,7 @@ func webError(w http.ResponseWriter, r *http.Request, c *Config, err error, defa
 			StatusText: http.StatusText(code),
 			Error:      err.Error(),
 		})
-	} else {
+	default:
 		http.Error(w, err.Error(), code)
 	}
 }
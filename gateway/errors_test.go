@@ -1,6 +1,8 @@
 package gateway
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,6 +10,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/boxo/denylist"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -122,3 +128,98 @@ func TestWebError(t *testing.T) {
 		require.Contains(t, w.Result().Header.Get("Content-Type"), "text/plain")
 	})
 }
+
+func TestWebErrorProblemJSON(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{Headers: map[string][]string{}}
+	node := cid.MustParse("bafkqaaa")
+
+	for _, test := range []struct {
+		name     string
+		err      error
+		code     int
+		expected Problem
+	}{
+		{
+			name:     "Missing link",
+			err:      fmt.Errorf("wrapped: %w", &resolver.ErrNoLink{Name: "missing", Node: node}),
+			code:     http.StatusNotFound,
+			expected: Problem{Type: ProblemTypeNotFound, Link: "missing", Node: node.String()},
+		},
+		{
+			name:     "Invalid CID",
+			err:      cid.ErrInvalidCid{Err: errors.New("invalid")},
+			code:     http.StatusBadRequest,
+			expected: Problem{Type: ProblemTypeInvalidCID},
+		},
+		{
+			name:     "Name resolution",
+			err:      NewErrorNameResolution("/ipns/example.net", namesys.ErrResolveFailed),
+			code:     http.StatusInternalServerError,
+			expected: Problem{Type: ProblemTypeNameResolution},
+		},
+		{
+			name:     "Name resolution timeout",
+			err:      NewErrorNameResolution("/ipns/example.net", context.DeadlineExceeded),
+			code:     http.StatusGatewayTimeout,
+			expected: Problem{Type: ProblemTypeTimeout},
+		},
+		{
+			name:     "Timeout",
+			err:      ErrGatewayTimeout,
+			code:     http.StatusGatewayTimeout,
+			expected: Problem{Type: ProblemTypeTimeout},
+		},
+		{
+			name:     "Blocked",
+			err:      fmt.Errorf("/ipfs/%s: %w", node, denylist.ErrBlocked),
+			code:     http.StatusGone,
+			expected: Problem{Type: ProblemTypeBlocked},
+		},
+		{
+			name:     "Retry after",
+			err:      NewErrorRetryAfter(ErrServiceUnavailable, 25*time.Second),
+			code:     http.StatusServiceUnavailable,
+			expected: Problem{Type: ProblemTypeRetryAfter, RetryAfter: 25},
+		},
+		{
+			name:     "Too many requests",
+			err:      ErrTooManyRequests,
+			code:     http.StatusTooManyRequests,
+			expected: Problem{Type: ProblemTypeRetryAfter},
+		},
+		{
+			name:     "Unclassified error",
+			err:      NewErrorStatusCodeFromStatus(http.StatusTeapot),
+			code:     http.StatusTeapot,
+			expected: Problem{Type: "about:blank", Title: http.StatusText(http.StatusTeapot)},
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/blah", nil)
+			r.Header.Set("Accept", "application/problem+json, text/html")
+			webError(w, r, config, test.err, http.StatusInternalServerError)
+
+			res := w.Result()
+			require.Equal(t, test.code, res.StatusCode)
+			require.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+			require.Equal(t, test.code, problem.Status)
+			require.NotEmpty(t, problem.Title)
+			require.NotEmpty(t, problem.Detail)
+
+			test.expected.Status, test.expected.Detail = problem.Status, problem.Detail
+			if test.expected.Title == "" {
+				test.expected.Title = problemTitles[test.expected.Type]
+			}
+			require.Equal(t, test.expected, problem)
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ipfs/boxo/denylist"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/gateway/assets"
 	"github.com/ipfs/boxo/ipld/unixfs"
@@ -53,6 +54,105 @@ type Config struct {
 	// directory listings, DAG previews and errors. These will be displayed to the
 	// right of "About IPFS" and "Install IPFS".
 	Menu []assets.MenuItem
+
+	// DirectoryListingPageSize is the number of entries in a page of the
+	// generated HTML directory listings, the next pages being linked from the
+	// previous ones. Zero means 1000 entries, like JSON listings. A negative
+	// value lists all the entries in a single page, unless the request has a
+	// limit or cursor query parameter.
+	DirectoryListingPageSize int
+
+	// Writable enables the HTTP methods that add or modify content: POST, PUT
+	// and DELETE. The [IPFSBackend] must also implement [WritableIPFSBackend],
+	// otherwise this flag has no effect. Only immutable /ipfs/ paths can be
+	// modified, and every modification results in a new root CID.
+	//
+	// This should never be enabled on a gateway exposed to the public internet.
+	Writable bool
+
+	// IPNSRecordPublishing enables PUT /ipns/{name}, which publishes the IPNS
+	// record in the request body. The [IPFSBackend] must also implement
+	// [IPNSPublishingBackend], otherwise this flag has no effect. Records must be
+	// valid for the name, and have a higher sequence number than the current
+	// record of the name, if any.
+	IPNSRecordPublishing bool
+
+	// Denylist blocks content from being served. Requests whose path, or
+	// resolved immutable path, is blocked fail with 410 Gone. The other blocks
+	// of the DAG are not checked by the gateway: to also refuse content that
+	// includes blocked CIDs, pass the same denylist to
+	// [blockservice.WithDenylist], whose [denylist.ErrBlocked] errors are
+	// returned as 410 Gone too.
+	Denylist *denylist.Denylist
+
+	// ServerTiming enables the [Server-Timing] header, with the duration of each
+	// [IPFSBackend] call made before the response header is sent.
+	//
+	// [Server-Timing]: https://www.w3.org/TR/server-timing/
+	ServerTiming bool
+
+	// OnRequestSummary, if set, is called with a [RequestSummary] once each
+	// request has been handled. It is called synchronously, and should not block.
+	OnRequestSummary func(RequestSummary)
+
+	// ResponseLimits limits the size of the CAR, TAR and ZIP responses, which
+	// would otherwise walk arbitrarily large DAGs for a single request.
+	ResponseLimits ResponseLimits
+
+	// Compression enables the negotiation of the Content-Encoding of
+	// deserialized UnixFS file responses, based on the Accept-Encoding request
+	// header.
+	Compression Compression
+}
+
+// ResponseLimits are per-request limits on the responses that traverse DAGs.
+// A zero value means no limit.
+//
+// When a limit is known to be exceeded before the response is sent, the request
+// fails with 413 Content Too Large. Otherwise, the response is cut off once the
+// limit is reached, at a block boundary for CAR responses and at an entry
+// boundary for TAR and ZIP responses, whenever possible.
+type ResponseLimits struct {
+	// MaxBlocks is the maximum number of blocks fetched for a response.
+	MaxBlocks int
+
+	// MaxBytes is the maximum number of block bytes of a CAR response, and the
+	// maximum number of file bytes of a TAR or ZIP response.
+	MaxBytes int64
+
+	// MaxDepth is the maximum number of links between the requested node and any
+	// block of a dag-scope=all CAR response, and the maximum nesting of entries
+	// of a TAR or ZIP response.
+	MaxDepth int
+}
+
+func (l ResponseLimits) isZero() bool {
+	return l == ResponseLimits{}
+}
+
+// Compression configures the Content-Encoding of deserialized UnixFS file
+// responses. A zero value disables compression.
+//
+// Encoded responses have their own ETag, and a Vary: Accept-Encoding header.
+type Compression struct {
+	// Precompressed serves the pre-compressed sibling of a file, such as
+	// file.js.br or file.js.gz, when it exists in the same directory and the
+	// client accepts its encoding. The encoding with the highest q-value in
+	// Accept-Encoding is picked, Brotli winning ties. Range requests apply to
+	// the pre-compressed sibling. Whether siblings exist is cached per file
+	// path, so missing siblings are only looked up once.
+	Precompressed bool
+
+	// MaxSize is the maximum size of the files that are compressed with Brotli
+	// or gzip on the fly, when no pre-compressed sibling is served, picking the
+	// encoding like pre-compressed siblings. Only files with a compressible
+	// Content-Type are compressed, and range requests are always served
+	// uncompressed. Zero disables on-the-fly compression.
+	MaxSize int64
+}
+
+func (c Compression) isZero() bool {
+	return c == Compression{}
 }
 
 // PublicGateway is the specification of an IPFS Public Gateway.
@@ -252,6 +352,30 @@ func (r *GetResponse) Close() error {
 	return nil
 }
 
+// Bytes returns the reader and the size of the file or block of the response,
+// or a nil reader if the response is not a file or a block.
+func (r *GetResponse) Bytes() (io.ReadCloser, int64) {
+	if r.bytes == nil {
+		return nil, 0
+	}
+	return r.bytes, r.bytesSize
+}
+
+// Symlink returns the symlink of the response, or nil if the response is not
+// a symlink.
+func (r *GetResponse) Symlink() *files.Symlink {
+	return r.symlink
+}
+
+// DirectoryListing returns the cumulative DAG size and the entries of the
+// directory of the response, and false if the response is not a directory.
+func (r *GetResponse) DirectoryListing() (uint64, <-chan unixfs.LinkResult, bool) {
+	if r.directoryMetadata == nil {
+		return 0, nil, false
+	}
+	return r.directoryMetadata.dagSize, r.directoryMetadata.entries, true
+}
+
 var _ io.Closer = (*GetResponse)(nil)
 
 type directoryMetadata struct {
@@ -287,6 +411,33 @@ func (r *HeadResponse) Close() error {
 	return nil
 }
 
+// IsFile returns whether the response is for a file or a block.
+func (r *HeadResponse) IsFile() bool {
+	return r.isFile
+}
+
+// IsSymlink returns whether the response is for a symlink.
+func (r *HeadResponse) IsSymlink() bool {
+	return r.isSymLink
+}
+
+// IsDirectory returns whether the response is for a directory.
+func (r *HeadResponse) IsDirectory() bool {
+	return r.isDir
+}
+
+// Size returns the size of the file, block or symlink, or the cumulative DAG
+// size of the directory.
+func (r *HeadResponse) Size() int64 {
+	return r.bytesSize
+}
+
+// StartingBytes returns the reader from the beginning of the file, which may
+// be nil, or may not include the whole file.
+func (r *HeadResponse) StartingBytes() io.ReadCloser {
+	return r.startingBytes
+}
+
 func NewHeadResponseForFile(startingBytes io.ReadCloser, size int64) *HeadResponse {
 	return &HeadResponse{startingBytes: startingBytes, isFile: true, bytesSize: size}
 }
@@ -317,6 +468,11 @@ type IPFSBackend interface {
 	// format, e.g. (DAG-)CBOR/JSON.
 	//
 	// Returned Directories are preferably a minimum info required for enumeration: Name, Size, and Cid.
+	// When the context has a [DirectoryListingPage] under [DirectoryListingPageKey],
+	// the generated listing is paginated after the name of the last entry of
+	// the previous page: the entries must then be returned in the same order
+	// for the same CID, and may start at the entry named After instead of the
+	// first one.
 	//
 	// Optional ranges follow [HTTP Byte Ranges] notation and can be used for
 	// pre-fetching specific sections of a file or a block.
@@ -327,6 +483,11 @@ type IPFSBackend interface {
 	//     file will still need magic bytes from the very beginning for content
 	//     type sniffing).
 	//   - A range request for a directory currently holds no semantic meaning.
+	//   - When more than one range is passed, the returned response bytes should
+	//     start at the beginning of the first range, and implement [io.Seeker]
+	//     so that the handler can seek to the others. Otherwise, only the first
+	//     range is served. The backend may prefetch the data of the remaining
+	//     ranges, as [BlocksBackend] does.
 	//   - For non-UnixFS (and non-raw data) such as terminal IPLD dag-cbor/json, etc. blocks the returned response
 	//     bytes should be the complete block and returned as an [io.ReadSeekCloser] starting at the beginning of the
 	//     block rather than as an [io.ReadCloser] that starts at the beginning of the range request.
@@ -382,6 +543,44 @@ type IPFSBackend interface {
 	GetDNSLinkRecord(context.Context, string) (path.Path, error)
 }
 
+// WritableIPFSBackend is an optional interface that can be implemented by an
+// [IPFSBackend] in order to support a writable gateway. See [Config.Writable].
+//
+// Since content is immutable, methods that modify a DAG do not change it in
+// place. Instead, they return the path of the new root, which must be
+// retrievable from the same backend.
+type WritableIPFSBackend interface {
+	// AddFile imports a UnixFS file or directory, including all of its
+	// children, and returns its immutable path.
+	AddFile(context.Context, files.Node) (path.ImmutablePath, error)
+
+	// AddCAR imports all the blocks from a CAR stream and returns the immutable
+	// path of its root. CARs must have exactly one root, and the root block
+	// must be present in the CAR.
+	AddCAR(context.Context, io.Reader) (path.ImmutablePath, error)
+
+	// Put writes a UnixFS file or directory to the given path, which must have
+	// at least one segment after the root CID. Missing parent directories are
+	// created, and an existing entry with the same name is replaced. It returns
+	// the immutable path of the new root.
+	Put(context.Context, path.ImmutablePath, files.Node) (path.ImmutablePath, error)
+
+	// Delete removes the entry at the given path, which must have at least one
+	// segment after the root CID. It returns the immutable path of the new root.
+	// If the entry does not exist, it should return an error of type:
+	// NewErrorStatusCode(fmt.Errorf("no link named %q under %s", name, cid), http.StatusNotFound)
+	Delete(context.Context, path.ImmutablePath) (path.ImmutablePath, error)
+}
+
+// IPNSPublishingBackend is an optional interface that can be implemented by an
+// [IPFSBackend] in order to accept IPNS record uploads. See
+// [Config.IPNSRecordPublishing].
+type IPNSPublishingBackend interface {
+	// PutIPNSRecord publishes the raw IPNS record of the name encoded in the
+	// given CID. The record has already been validated for the name.
+	PutIPNSRecord(ctx context.Context, c cid.Cid, record []byte) error
+}
+
 // cleanHeaderSet is an helper function that cleans a set of headers by
 // (1) canonicalizing, (2) de-duplicating and (3) sorting.
 func cleanHeaderSet(headers []string) []string {
@@ -475,4 +674,20 @@ const (
 
 	// ContentPathKey is the key for the original [http.Request] URL Path, as an [ipath.Path].
 	ContentPathKey RequestContextKey = "content-path"
+
+	// ResponseLimitsKey is the key for the [ResponseLimits] of the request. It is
+	// only set if [Config.ResponseLimits] is set.
+	ResponseLimitsKey RequestContextKey = "response-limits"
+
+	// DirectoryListingPageKey is the key for the [DirectoryListingPage] of the
+	// request. It is only set if the requested listing is paginated.
+	DirectoryListingPageKey RequestContextKey = "directory-listing-page"
 )
+
+// DirectoryListingPage is the page of a generated directory listing requested
+// from [IPFSBackend.Get].
+type DirectoryListingPage struct {
+	// After is the name of the last entry of the previous page, or empty for
+	// the first page.
+	After string
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ipfs/boxo/denylist"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/path"
@@ -190,18 +192,21 @@ func TestHeaders(t *testing.T) {
 		test(carResponseFormat, dirPath, `W/"%s.car.7of9u8ojv38vd"`, rootCID) // ETags of CARs on a Path have the root CID in the Etag and hashed information to derive the correct Etag of the full request.
 		test(rawResponseFormat, dirPath, `"%s.raw"`, dirCID)
 		test(tarResponseFormat, dirPath, `W/"%s.x-tar"`, dirCID)
+		test(zipResponseFormat, dirPath, `W/"%s.zip"`, dirCID)
 
 		test("", hamtFilePath, `"%s"`, hamtFileCID)
 		test("text/html", hamtFilePath, `"%s"`, hamtFileCID)
 		test(carResponseFormat, hamtFilePath, `W/"%s.car.2uq26jdcsk50p"`, rootCID) // ETags of CARs on a Path have the root CID in the Etag and hashed information to derive the correct Etag of the full request.
 		test(rawResponseFormat, hamtFilePath, `"%s.raw"`, hamtFileCID)
 		test(tarResponseFormat, hamtFilePath, `W/"%s.x-tar"`, hamtFileCID)
+		test(zipResponseFormat, hamtFilePath, `W/"%s.zip"`, hamtFileCID)
 
 		test("", filePath, `"%s"`, fileCID)
 		test("text/html", filePath, `"%s"`, fileCID)
 		test(carResponseFormat, filePath, `W/"%s.car.fgq8i0qnhsq01"`, rootCID)
 		test(rawResponseFormat, filePath, `"%s.raw"`, fileCID)
 		test(tarResponseFormat, filePath, `W/"%s.x-tar"`, fileCID)
+		test(zipResponseFormat, filePath, `W/"%s.zip"`, fileCID)
 
 		test("", dagCborPath, `"%s.dag-cbor"`, dagCborCID)
 		test("text/html", dagCborPath+"/", `"DagIndex-(.*)_CID-%s"`, dagCborCID)
@@ -902,3 +907,52 @@ func TestBrowserErrorHTML(t *testing.T) {
 		require.Contains(t, string(body), "<!DOCTYPE html>")
 	})
 }
+
+func TestDenylist(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend, root := newMockBackend(t, "fixtures.car")
+
+	p, err := path.Join(path.FromCid(root), "subdir", "fnord")
+	require.NoError(t, err)
+	k, err := backend.resolvePathNoRootsReturned(ctx, p)
+	require.NoError(t, err)
+
+	backend.namesys["/ipns/example.com"] = path.FromCid(k.RootCid())
+	backend.namesys["/ipns/blocked.example.com"] = path.FromCid(root)
+
+	dl, err := denylist.New(strings.NewReader(strings.Join([]string{
+		"/ipfs/" + root.String() + "/subdir",
+		"/ipfs/" + k.RootCid().String(),
+		"/ipns/blocked.example.com",
+	}, "\n")))
+	require.NoError(t, err)
+
+	ts := newTestServerWithConfig(t, backend, Config{
+		Headers:               map[string][]string{},
+		DeserializedResponses: true,
+		Denylist:              dl,
+	})
+
+	for _, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/ipfs/" + root.String() + "/empty-dir/", http.StatusOK},
+		{"/ipfs/" + root.String() + "/subdir", http.StatusGone},
+		{"/ipfs/" + root.String() + "/subdir/fnord", http.StatusGone},
+		{"/ipfs/" + k.RootCid().String(), http.StatusGone},
+		{"/ipfs/" + k.RootCid().String() + "?format=raw", http.StatusGone},
+		{"/ipns/example.com", http.StatusGone},
+		{"/ipns/blocked.example.com/empty-dir/", http.StatusGone},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			res := mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, ts.URL+test.path, nil))
+			defer res.Body.Close()
+			assert.Equal(t, test.status, res.StatusCode)
+		})
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"strings"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/denylist"
 	"github.com/ipfs/boxo/gateway/assets"
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
@@ -69,6 +71,19 @@ type handler struct {
 	config  *Config
 	backend IPFSBackend
 
+	// writableBackend is set if backend implements [WritableIPFSBackend].
+	writableBackend WritableIPFSBackend
+
+	// ipnsPublishingBackend is set if backend implements [IPNSPublishingBackend].
+	ipnsPublishingBackend IPNSPublishingBackend
+
+	// headersCache caches the rules of `_headers` files per root CID.
+	headersCache *lru.Cache[cid.Cid, headersCacheEntry]
+
+	// precompressedCache caches whether the pre-compressed siblings of files
+	// exist, per immutable file path and encoding.
+	precompressedCache *lru.Cache[string, map[string]bool]
+
 	// response type metrics
 	requestTypeMetric            *prometheus.CounterVec
 	getMetric                    *prometheus.HistogramVec
@@ -80,6 +95,8 @@ type handler struct {
 	rawBlockGetMetric            *prometheus.HistogramVec
 	tarStreamGetMetric           *prometheus.HistogramVec
 	tarStreamFailMetric          *prometheus.HistogramVec
+	zipStreamGetMetric           *prometheus.HistogramVec
+	zipStreamFailMetric          *prometheus.HistogramVec
 	jsoncborDocumentGetMetric    *prometheus.HistogramVec
 	ipnsRecordGetMetric          *prometheus.HistogramVec
 }
@@ -100,7 +117,8 @@ func NewHandler(c Config, backend IPFSBackend) http.Handler {
 // Notes:
 // 1. For HEAD requests the io.Reader may be nil/undefined
 // 2. When the io.Reader is needed it must start at the beginning of the first Range Request component if it exists
-// 3. Only a single HTTP Range Request is supported, if more than one are requested only the first will be honored
+// 3. Multiple HTTP Range Requests are served as multipart/byteranges if the io.Reader is an io.Seeker,
+// otherwise only the first will be honored
 // 4. The Content-Type header must already be set
 func serveContent(w http.ResponseWriter, req *http.Request, modtime time.Time, size int64, content io.Reader) (int, bool, error) {
 	ew := &errRecordingResponseWriter{ResponseWriter: w}
@@ -114,22 +132,35 @@ func serveContent(w http.ResponseWriter, req *http.Request, modtime time.Time, s
 	return ew.code, dataSent, ew.err
 }
 
-// errRecordingResponseWriter wraps a ResponseWriter to record the status code and any write error.
+// errRecordingResponseWriter wraps a ResponseWriter to record the status code,
+// the number of bytes written and any write error.
 type errRecordingResponseWriter struct {
 	http.ResponseWriter
-	code int
-	err  error
+	code  int
+	bytes int64
+	err   error
+
+	// beforeWriteHeader, if set, is called once before the header is written,
+	// and can still modify it.
+	beforeWriteHeader func()
 }
 
 func (w *errRecordingResponseWriter) WriteHeader(code int) {
 	if w.code == 0 {
 		w.code = code
+		if w.beforeWriteHeader != nil {
+			w.beforeWriteHeader()
+		}
 	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
 func (w *errRecordingResponseWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
 	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
 	if err != nil && w.err == nil {
 		w.err = err
 	}
@@ -139,7 +170,11 @@ func (w *errRecordingResponseWriter) Write(p []byte) (int, error) {
 // ReadFrom exposes errRecordingResponseWriter's underlying ResponseWriter to io.Copy
 // to allow optimized methods to be taken advantage of.
 func (w *errRecordingResponseWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
 	n, err = io.Copy(w.ResponseWriter, r)
+	w.bytes += n
 	if err != nil && w.err == nil {
 		w.err = err
 	}
@@ -154,6 +189,12 @@ func (i *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	r = r.WithContext(ctx)
 
+	if i.config.ServerTiming || i.config.OnRequestSummary != nil {
+		var done func()
+		w, r, done = i.withRequestSummary(w, r)
+		defer done()
+	}
+
 	switch r.Method {
 	case http.MethodGet, http.MethodHead:
 		i.getOrHeadHandler(w, r)
@@ -161,16 +202,38 @@ func (i *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodOptions:
 		i.optionsHandler(w, r)
 		return
+	case http.MethodPut:
+		if i.isIPNSPublishable() && strings.HasPrefix(r.URL.Path, ipnsPathPrefix) {
+			i.putIpnsRecordHandler(w, r)
+			return
+		}
 	}
 
-	addAllowHeader(w)
+	if i.isWritable() {
+		switch r.Method {
+		case http.MethodPost:
+			i.postHandler(w, r)
+			return
+		case http.MethodPut:
+			i.putHandler(w, r)
+			return
+		case http.MethodDelete:
+			i.deleteHandler(w, r)
+			return
+		}
+	}
+
+	i.addAllowHeader(w)
 
-	errmsg := "Method " + r.Method + " not allowed: read only access"
+	errmsg := "Method " + r.Method + " not allowed"
+	if !i.isWritable() {
+		errmsg += ": read only access"
+	}
 	http.Error(w, errmsg, http.StatusMethodNotAllowed)
 }
 
 func (i *handler) optionsHandler(w http.ResponseWriter, r *http.Request) {
-	addAllowHeader(w)
+	i.addAllowHeader(w)
 	// OPTIONS is a noop request that is used by the browsers to check if server accepts
 	// cross-site XMLHttpRequest, which is indicated by the presence of CORS headers:
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Access_control_CORS#Preflighted_requests
@@ -178,10 +241,17 @@ func (i *handler) optionsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // addAllowHeader sets Allow header with supported HTTP methods
-func addAllowHeader(w http.ResponseWriter) {
+func (i *handler) addAllowHeader(w http.ResponseWriter) {
 	w.Header().Add("Allow", http.MethodGet)
 	w.Header().Add("Allow", http.MethodHead)
 	w.Header().Add("Allow", http.MethodOptions)
+	if i.isWritable() {
+		w.Header().Add("Allow", http.MethodPost)
+		w.Header().Add("Allow", http.MethodPut)
+		w.Header().Add("Allow", http.MethodDelete)
+	} else if i.isIPNSPublishable() {
+		w.Header().Add("Allow", http.MethodPut)
+	}
 }
 
 type requestData struct {
@@ -235,7 +305,17 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if i.handleDenylist(w, r, contentPath) {
+		return
+	}
+
+	summary := requestSummaryFromContext(r.Context())
+	summary.setContentPath(contentPath)
+
 	ctx := context.WithValue(r.Context(), ContentPathKey, contentPath)
+	if !i.config.ResponseLimits.isZero() {
+		ctx = context.WithValue(ctx, ResponseLimitsKey, i.config.ResponseLimits)
+	}
 	r = r.WithContext(ctx)
 
 	defer func() {
@@ -256,6 +336,7 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("ResponseFormat", responseFormat))
 	i.requestTypeMetric.WithLabelValues(contentPath.Namespace(), responseFormat).Inc()
+	summary.setRequestType(responseFormat)
 
 	addCustomHeaders(w, i.config.Headers) // ok, _now_ write user's headers.
 	w.Header().Set("X-Ipfs-Path", contentPath.String())
@@ -287,10 +368,13 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 	if contentPath.Mutable() {
 		rq.immutablePath, err = i.backend.ResolveMutable(r.Context(), contentPath)
 		if err != nil {
-			err = fmt.Errorf("failed to resolve %s: %w", debugStr(contentPath.String()), err)
+			err = NewErrorNameResolution(debugStr(contentPath.String()), err)
 			i.webError(w, r, err, http.StatusInternalServerError)
 			return
 		}
+		if i.handleDenylist(w, r, rq.immutablePath) {
+			return
+		}
 	} else {
 		rq.immutablePath, err = path.NewImmutablePath(contentPath)
 		if err != nil {
@@ -299,6 +383,7 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	summary.setResolvedPath(rq.immutablePath)
 
 	// CAR response format can be handled now, since (1) it explicitly needs the
 	// full immutable path to include in the CAR, and (2) has custom If-None-Match
@@ -324,6 +409,9 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 	case tarResponseFormat:
 		logger.Debugw("serving tar file", "path", contentPath)
 		success = i.serveTAR(r.Context(), w, r, rq)
+	case zipResponseFormat:
+		logger.Debugw("serving zip file", "path", contentPath)
+		success = i.serveZIP(r.Context(), w, r, rq)
 	case dagJsonResponseFormat, dagCborResponseFormat:
 		logger.Debugw("serving codec", "path", contentPath)
 		success = i.serveCodec(r.Context(), w, r, rq)
@@ -580,7 +668,7 @@ func getEtag(r *http.Request, cid cid.Cid, responseFormat string) string {
 	case carResponseFormat, ipnsRecordResponseFormat:
 		// CARs and IPNS Record ETags are handled differently, in their respective handler.
 		return ""
-	case tarResponseFormat:
+	case tarResponseFormat, zipResponseFormat:
 		// Weak Etag W/ for formats that we can't guarantee byte-for-byte identical
 		// responses, but still want to benefit from HTTP Caching.
 		prefix = "W/" + prefix
@@ -600,6 +688,7 @@ const (
 	rawResponseFormat        = "application/vnd.ipld.raw"
 	carResponseFormat        = "application/vnd.ipld.car"
 	tarResponseFormat        = "application/x-tar"
+	zipResponseFormat        = "application/zip"
 	jsonResponseFormat       = "application/json"
 	cborResponseFormat       = "application/cbor"
 	dagJsonResponseFormat    = "application/vnd.ipld.dag-json"
@@ -623,6 +712,7 @@ func customResponseFormat(r *http.Request) (mediaType string, params map[string]
 			if strings.HasPrefix(accept, "application/vnd.ipld") ||
 				strings.HasPrefix(accept, "application/vnd.ipfs") ||
 				strings.HasPrefix(accept, tarResponseFormat) ||
+				strings.HasPrefix(accept, zipResponseFormat) ||
 				strings.HasPrefix(accept, jsonResponseFormat) ||
 				strings.HasPrefix(accept, cborResponseFormat) {
 				mediatype, params, err := mime.ParseMediaType(accept)
@@ -643,6 +733,8 @@ func customResponseFormat(r *http.Request) (mediaType string, params map[string]
 			return carResponseFormat, nil, nil
 		case "tar":
 			return tarResponseFormat, nil, nil
+		case "zip":
+			return zipResponseFormat, nil, nil
 		case "json":
 			return jsonResponseFormat, nil, nil
 		case "cbor":
@@ -695,7 +787,8 @@ func (i *handler) handleIfNoneMatch(w http.ResponseWriter, r *http.Request, rq *
 		// Checks against both file, dir listing, and dag index Etags.
 		// This is an inexpensive check, and it happens before we do any I/O.
 		cidEtag := getEtag(r, pathCid, rq.responseFormat)
-		dirEtag := getDirListingEtag(pathCid)
+		page, _ := parseDirListingPage(r, i.dirListingDefaultLimit(rq.responseFormat))
+		dirEtag := getDirListingEtag(pathCid, rq.responseFormat, page)
 		dagEtag := getDagIndexEtag(pathCid)
 
 		if etagMatch(ifNoneMatch, cidEtag, dirEtag, dagEtag) {
@@ -782,6 +875,17 @@ func (i *handler) handleWebRequestErrors(w http.ResponseWriter, r *http.Request,
 	return path.ImmutablePath{}, false
 }
 
+// handleDenylist responds with 410 Gone and returns true if the content path is
+// blocked by the configured denylist.
+func (i *handler) handleDenylist(w http.ResponseWriter, r *http.Request, contentPath path.Path) bool {
+	if !i.config.Denylist.IsPathBlocked(contentPath) {
+		return false
+	}
+	err := fmt.Errorf("%s: %w", debugStr(contentPath.String()), denylist.ErrBlocked)
+	i.webError(w, r, err, http.StatusGone)
+	return true
+}
+
 // Detect 'Cache-Control: only-if-cached' in request and return data if it is already in the local datastore.
 // https://github.com/ipfs/specs/blob/main/http-gateways/PATH_GATEWAY.md#cache-control-request-header
 func (i *handler) handleOnlyIfCached(w http.ResponseWriter, r *http.Request, contentPath path.Path) bool {
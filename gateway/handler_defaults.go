@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ipfs/boxo/path"
 	mc "github.com/multiformats/go-multicodec"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -26,6 +27,10 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 		ranges       []ByteRange
 		headResp     *HeadResponse
 		getResp      *GetResponse
+
+		// filePath is the path of the served content, which differs from the
+		// requested path if the request is forwarded by a _redirects rule.
+		filePath = rq.immutablePath
 	)
 
 	switch r.Method {
@@ -37,6 +42,7 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 				if !continueProcessing {
 					return false
 				}
+				filePath = forwardedPath
 				pathMetadata, headResp, err = i.backend.Head(ctx, forwardedPath)
 				if err != nil {
 					err = fmt.Errorf("failed to resolve %s: %w", debugStr(rq.contentPath.String()), err)
@@ -61,6 +67,13 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 			}
 		}
 
+		// Let the backend only enumerate the directory entries of the page, if
+		// the listing is paginated. Invalid pages are rejected when serving
+		// the directory.
+		if page, err := parseDirListingPage(r, i.dirListingDefaultLimit(rq.responseFormat)); err == nil && (page.limit > 0 || page.cursor != "") {
+			ctx = context.WithValue(ctx, DirectoryListingPageKey, DirectoryListingPage{After: page.after})
+		}
+
 		// TODO: passing only resolved path here, instead of contentPath is
 		// harming content routing. Knowing original immutableContentPath will
 		// allow backend to find providers for parents, even when internal
@@ -73,6 +86,7 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 				if !continueProcessing {
 					return false
 				}
+				filePath = forwardedPath
 				pathMetadata, getResp, err = i.backend.Get(ctx, forwardedPath, ranges...)
 				if err != nil {
 					err = fmt.Errorf("failed to resolve %s: %w", debugStr(rq.contentPath.String()), err)
@@ -118,17 +132,19 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 		ctx, span := spanTrace(ctx, "Handler.ServeUnixFS", trace.WithAttributes(attribute.String("path", resolvedPath.String())))
 		defer span.End()
 
+		w := i.withCustomHeaders(w, r, rq)
+
 		// Handle UnixFS HEAD requests
 		if headResp != nil {
 			if headResp.isFile {
 				rq.logger.Debugw("serving unixfs file", "path", rq.contentPath)
-				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, headResp.bytesSize, headResp.startingBytes, false, true, pathMetadata.ContentType, rq.begin)
+				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, filePath, headResp.bytesSize, headResp.startingBytes, false, true, pathMetadata.ContentType, rq.begin)
 			} else if headResp.isSymLink {
 				rq.logger.Debugw("serving unixfs file", "path", rq.contentPath)
-				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, headResp.bytesSize, nil, true, true, pathMetadata.ContentType, rq.begin)
+				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, path.ImmutablePath{}, headResp.bytesSize, nil, true, true, pathMetadata.ContentType, rq.begin)
 			} else if headResp.isDir {
 				rq.logger.Debugw("serving unixfs directory", "path", rq.contentPath)
-				return i.serveDirectory(ctx, w, r, resolvedPath, rq.contentPath, true, nil, ranges, rq.begin, rq.logger)
+				return i.serveDirectory(ctx, w, r, resolvedPath, rq.contentPath, rq.responseFormat, true, nil, ranges, rq.begin, rq.logger)
 			}
 		} else {
 			if getResp.bytes != nil {
@@ -140,14 +156,14 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 						rangeRequestStartsAtZero = false
 					}
 				}
-				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, getResp.bytesSize, getResp.bytes, false, rangeRequestStartsAtZero, pathMetadata.ContentType, rq.begin)
+				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, filePath, getResp.bytesSize, getResp.bytes, false, rangeRequestStartsAtZero, pathMetadata.ContentType, rq.begin)
 			} else if getResp.symlink != nil {
 				rq.logger.Debugw("serving unixfs file", "path", rq.contentPath)
 				// Note: this ignores range requests against symlinks
-				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, getResp.bytesSize, getResp.symlink, true, true, pathMetadata.ContentType, rq.begin)
+				return i.serveFile(ctx, w, r, resolvedPath, rq.contentPath, path.ImmutablePath{}, getResp.bytesSize, getResp.symlink, true, true, pathMetadata.ContentType, rq.begin)
 			} else if getResp.directoryMetadata != nil {
 				rq.logger.Debugw("serving unixfs directory", "path", rq.contentPath)
-				return i.serveDirectory(ctx, w, r, resolvedPath, rq.contentPath, false, getResp.directoryMetadata, ranges, rq.begin, rq.logger)
+				return i.serveDirectory(ctx, w, r, resolvedPath, rq.contentPath, rq.responseFormat, false, getResp.directoryMetadata, ranges, rq.begin, rq.logger)
 			}
 		}
 
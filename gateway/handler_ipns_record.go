@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +14,8 @@ import (
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -95,3 +98,87 @@ func (i *handler) serveIpnsRecord(ctx context.Context, w http.ResponseWriter, r
 
 	return false
 }
+
+// isIPNSPublishable returns true if the gateway is configured to accept IPNS
+// record uploads, and the backend supports it.
+func (i *handler) isIPNSPublishable() bool {
+	return i.config.IPNSRecordPublishing && i.ipnsPublishingBackend != nil
+}
+
+// putIpnsRecordHandler publishes the IPNS record in the request body, after
+// checking that it is valid for the name, and newer than the current record.
+//
+// Example: PUT /ipns/{name}
+func (i *handler) putIpnsRecordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := spanTrace(r.Context(), "Handler.ServePUTIPNSRecord", trace.WithAttributes(attribute.String("path", r.URL.Path)))
+	defer span.End()
+
+	key := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, ipnsPathPrefix), "/")
+	if key == "" || strings.Contains(key, "/") {
+		i.webError(w, r, errors.New("IPNS records can only be published with PUT /ipns/{name}"), http.StatusBadRequest)
+		return
+	}
+
+	name, err := ipns.NameFromString(key)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	// Raw bodies without a specific media type are accepted as records too.
+	if mediaType, _, err := parseRequestContentType(r); err != nil || (mediaType != ipnsRecordResponseFormat && mediaType != "application/octet-stream") {
+		i.webError(w, r, fmt.Errorf("request body must be an %s", ipnsRecordResponseFormat), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	rawRecord, err := io.ReadAll(io.LimitReader(r.Body, int64(ipns.MaxRecordSize)+1))
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if len(rawRecord) > ipns.MaxRecordSize {
+		i.webError(w, r, ipns.ErrRecordSize, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	record, err := ipns.UnmarshalRecord(rawRecord)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := ipns.ValidateWithName(record, name); err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	sequence, err := record.Sequence()
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	// Only records that supersede the current one are accepted. A missing or
+	// invalid current record does not prevent publishing. Backends report a
+	// missing record from the routing system or from their local datastore.
+	currentRaw, err := i.backend.GetIPNSRecord(ctx, name.Cid())
+	switch {
+	case errors.Is(err, routing.ErrNotFound), errors.Is(err, datastore.ErrNotFound):
+	case err != nil:
+		i.webError(w, r, fmt.Errorf("failed to get current IPNS record: %w", err), http.StatusInternalServerError)
+		return
+	default:
+		if current, err := ipns.UnmarshalRecord(currentRaw); err == nil && ipns.ValidateWithName(current, name) == nil {
+			if currentSequence, err := current.Sequence(); err == nil && sequence <= currentSequence {
+				err := fmt.Errorf("record sequence number %d is not higher than the current sequence number %d", sequence, currentSequence)
+				i.webError(w, r, err, http.StatusConflict)
+				return
+			}
+		}
+	}
+
+	if err := i.ipnsPublishingBackend.PutIPNSRecord(ctx, name.Cid(), rawRecord); err != nil {
+		i.webError(w, r, fmt.Errorf("failed to publish IPNS record: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
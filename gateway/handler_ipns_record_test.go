@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ipnsPublishingMockBackend is a mockBackend that stores IPNS records in memory.
+type ipnsPublishingMockBackend struct {
+	*mockBackend
+
+	lk      sync.Mutex
+	records map[cid.Cid][]byte
+
+	// notFound is returned for missing records, [routing.ErrNotFound] if nil.
+	notFound error
+}
+
+var _ IPNSPublishingBackend = (*ipnsPublishingMockBackend)(nil)
+
+func (mb *ipnsPublishingMockBackend) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte, error) {
+	mb.lk.Lock()
+	defer mb.lk.Unlock()
+
+	record, ok := mb.records[c]
+	if !ok {
+		if mb.notFound != nil {
+			return nil, mb.notFound
+		}
+		return nil, routing.ErrNotFound
+	}
+	return record, nil
+}
+
+func (mb *ipnsPublishingMockBackend) PutIPNSRecord(ctx context.Context, c cid.Cid, record []byte) error {
+	mb.lk.Lock()
+	defer mb.lk.Unlock()
+
+	mb.records[c] = record
+	return nil
+}
+
+func TestPutIPNSRecord(t *testing.T) {
+	t.Parallel()
+
+	sk, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid)
+
+	mb, root := newMockBackend(t, "fixtures.car")
+	backend := &ipnsPublishingMockBackend{mockBackend: mb, records: map[cid.Cid][]byte{}}
+
+	makeRecord := func(t *testing.T, sk crypto.PrivKey, seq uint64) []byte {
+		rec, err := ipns.NewRecord(sk, path.FromCid(root), seq, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		raw, err := ipns.MarshalRecord(rec)
+		require.NoError(t, err)
+		return raw
+	}
+
+	put := func(t *testing.T, ts string, urlPath string, contentType string, body []byte) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, ts+urlPath, bytes.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		res := mustDo(t, req)
+		res.Body.Close()
+		return res
+	}
+
+	ts := newTestServerWithConfig(t, backend, Config{
+		DeserializedResponses: true,
+		IPNSRecordPublishing:  true,
+	})
+
+	t.Run("PUT is not allowed unless enabled", func(t *testing.T) {
+		ts := newTestServer(t, backend)
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, makeRecord(t, sk, 1))
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	})
+
+	t.Run("Valid record is published", func(t *testing.T) {
+		raw := makeRecord(t, sk, 1)
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, raw)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		stored, err := backend.GetIPNSRecord(context.Background(), name.Cid())
+		require.NoError(t, err)
+		assert.Equal(t, raw, stored)
+	})
+
+	t.Run("Valid record is published when the datastore has no record", func(t *testing.T) {
+		backend := &ipnsPublishingMockBackend{
+			mockBackend: mb,
+			records:     map[cid.Cid][]byte{},
+			notFound:    fmt.Errorf("failed to get record: %w", datastore.ErrNotFound),
+		}
+		ts := newTestServerWithConfig(t, backend, Config{
+			DeserializedResponses: true,
+			IPNSRecordPublishing:  true,
+		})
+
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, makeRecord(t, sk, 1))
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("Record with the same sequence number is rejected", func(t *testing.T) {
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, makeRecord(t, sk, 1))
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("Record with a higher sequence number is published", func(t *testing.T) {
+		res := put(t, ts.URL, "/ipns/"+name.String(), "", makeRecord(t, sk, 2))
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("Record signed by another key is rejected", func(t *testing.T) {
+		otherSk, _, err := crypto.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, makeRecord(t, otherSk, 3))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Invalid record is rejected", func(t *testing.T) {
+		res := put(t, ts.URL, "/ipns/"+name.String(), ipnsRecordResponseFormat, []byte("not a record"))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Unsupported media type is rejected", func(t *testing.T) {
+		res := put(t, ts.URL, "/ipns/"+name.String(), "text/plain", makeRecord(t, sk, 3))
+		assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+	})
+
+	t.Run("Path with a remainder is rejected", func(t *testing.T) {
+		res := put(t, ts.URL, "/ipns/"+name.String()+"/subdir", ipnsRecordResponseFormat, makeRecord(t, sk, 3))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
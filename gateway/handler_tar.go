@@ -27,6 +27,17 @@ func (i *handler) serveTAR(ctx context.Context, w http.ResponseWriter, r *http.R
 	}
 	defer file.Close()
 
+	// Enforce the response limits on each entry before it is written. A file
+	// that already exceeds them fails the request before anything is sent.
+	if !i.config.ResponseLimits.isZero() {
+		budget := &archiveBudget{limits: i.config.ResponseLimits}
+		file, err = budget.limitNode(file, 0)
+		if err != nil {
+			i.webError(w, r, err, http.StatusInternalServerError)
+			return false
+		}
+	}
+
 	setIpfsRootsHeader(w, rq, &pathMetadata)
 	rootCid := pathMetadata.LastSegment.RootCid()
 
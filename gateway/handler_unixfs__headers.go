@@ -0,0 +1,325 @@
+package gateway
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"go.uber.org/zap"
+)
+
+// A `_headers` file at the root of a website sets custom response headers on
+// the files and directories that match its rules, in the Netlify format:
+//
+//	# Comment
+//	/path/*
+//	  Header-Name: value
+//
+// A rule is a path pattern, followed by indented header lines. Patterns match
+// the request path from the root of the website: `*` matches any sequence of
+// characters, including `/`, and `:placeholder` matches a single path segment.
+// A pattern that ends in `/*` also matches the directory itself. The headers of
+// every matching rule are combined, in order.
+//
+// Like `_redirects`, `_headers` files are only processed when the request has
+// origin isolation, as their headers apply to the whole origin.
+
+const (
+	// maxHeadersFileSize is the maximum size of a `_headers` file.
+	maxHeadersFileSize = 64 << 10
+
+	// headersCacheSize is the number of website roots whose `_headers` rules
+	// are cached.
+	headersCacheSize = 1024
+
+	// headersErrorCacheTTL is the time during which a `_headers` file that
+	// could not be retrieved is not retrieved again.
+	headersErrorCacheTTL = time.Minute
+)
+
+// forbiddenCustomHeaders are the headers that `_headers` files cannot set,
+// because they describe the framing or the identity of the response, which are
+// managed by the gateway.
+var forbiddenCustomHeaders = map[string]struct{}{
+	"Accept-Ranges":     {},
+	"Connection":        {},
+	"Content-Encoding":  {},
+	"Content-Length":    {},
+	"Content-Location":  {},
+	"Content-Range":     {},
+	"Date":              {},
+	"Etag":              {},
+	"Keep-Alive":        {},
+	"Last-Modified":     {},
+	"Location":          {},
+	"Proxy-Connection":  {},
+	"Server-Timing":     {},
+	"Set-Cookie":        {},
+	"Te":                {},
+	"Trailer":           {},
+	"Transfer-Encoding": {},
+	"Upgrade":           {},
+}
+
+// isForbiddenCustomHeader reports whether a `_headers` file cannot set the
+// canonical header name.
+func isForbiddenCustomHeader(name string) bool {
+	if _, ok := forbiddenCustomHeaders[name]; ok {
+		return true
+	}
+	// X-Ipfs-Path, X-Ipfs-Roots, etc. are part of the gateway specifications.
+	return strings.HasPrefix(name, "X-Ipfs-")
+}
+
+// isValidHeaderName reports whether name is a valid HTTP header field name,
+// that is a non-empty token as defined by RFC 9110.
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c >= 0x7f || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// headersRule is a rule of a `_headers` file.
+type headersRule struct {
+	re      *regexp.Regexp
+	headers http.Header
+}
+
+var headersPlaceholderRegex = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+// newHeadersRule compiles the path pattern of a rule.
+func newHeadersRule(pattern string) (headersRule, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return headersRule{}, fmt.Errorf("path pattern %q must start with /", pattern)
+	}
+
+	p := pattern
+	suffix := ""
+	if p != "/*" && strings.HasSuffix(p, "/*") {
+		p = strings.TrimSuffix(p, "/*")
+		suffix = "(?:/.*)?"
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, part := range strings.Split(p, "*") {
+		if i > 0 {
+			expr.WriteString(".*")
+		}
+		last := 0
+		for _, loc := range headersPlaceholderRegex.FindAllStringIndex(part, -1) {
+			expr.WriteString(regexp.QuoteMeta(part[last:loc[0]]))
+			expr.WriteString("[^/]+")
+			last = loc[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(part[last:]))
+	}
+	expr.WriteString(suffix)
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return headersRule{}, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+	}
+	return headersRule{re: re, headers: http.Header{}}, nil
+}
+
+// parseHeadersFile parses the rules of a `_headers` file. Headers that cannot
+// be set by websites are dropped.
+func parseHeadersFile(r io.Reader, logger *zap.SugaredLogger) ([]headersRule, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxHeadersFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxHeadersFileSize {
+		return nil, fmt.Errorf("_headers file is larger than %d bytes", maxHeadersFileSize)
+	}
+
+	var rules []headersRule
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Unindented lines start a new rule.
+		if line[0] != ' ' && line[0] != '\t' {
+			rule, err := newHeadersRule(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			rules = append(rules, rule)
+			continue
+		}
+
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("line %d: header outside of a path rule", lineNum)
+		}
+		name, value, ok := strings.Cut(trimmed, ":")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if !ok || !isValidHeaderName(name) || strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("line %d: invalid header %q", lineNum, trimmed)
+		}
+
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if isForbiddenCustomHeader(name) {
+			logger.Debugw("ignoring forbidden header in _headers file", "header", name, "line", lineNum)
+			continue
+		}
+		rules[len(rules)-1].headers.Add(name, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// matchHeadersRules returns the combined headers of the rules that match the
+// URL path.
+func matchHeadersRules(rules []headersRule, urlPath string) http.Header {
+	headers := http.Header{}
+	for _, rule := range rules {
+		if !rule.re.MatchString(urlPath) {
+			continue
+		}
+		for name, values := range rule.headers {
+			headers[name] = append(headers[name], values...)
+		}
+	}
+	return headers
+}
+
+// headersCacheEntry is the cached result of reading the `_headers` file of a
+// website root.
+type headersCacheEntry struct {
+	rules []headersRule
+
+	// expiresAt is set when the `_headers` file could not be retrieved, as the
+	// error may be transient. Other results cannot change for a given root.
+	expiresAt time.Time
+}
+
+func newHeadersCache() *lru.Cache[cid.Cid, headersCacheEntry] {
+	cache, err := lru.New[cid.Cid, headersCacheEntry](headersCacheSize)
+	if err != nil {
+		// Only happens with a non-positive size.
+		panic(err)
+	}
+	return cache
+}
+
+// getHeadersRules returns the rules of the `_headers` file of the website at
+// the root of the given path, which are cached per root CID. A missing
+// `_headers` file has no rules. So does a `_headers` file that cannot be
+// retrieved or parsed, as it must not prevent serving the website: the error is
+// logged, and cached for [headersErrorCacheTTL] to not retry on every request.
+func (i *handler) getHeadersRules(r *http.Request, immutableContentPath path.ImmutablePath, logger *zap.SugaredLogger) []headersRule {
+	rootCid := immutableContentPath.RootCid()
+	if e, ok := i.headersCache.Get(rootCid); ok && (e.expiresAt.IsZero() || time.Now().Before(e.expiresAt)) {
+		return e.rules
+	}
+
+	rules, err := i.readHeadersFile(r, rootCid, logger)
+	if err != nil {
+		logger.Warnw("ignoring _headers file", "root", rootCid.String(), "error", err)
+	}
+
+	var e headersCacheEntry
+	if err != nil && !errors.Is(err, errInvalidHeadersFile) {
+		e.expiresAt = time.Now().Add(headersErrorCacheTTL)
+	}
+	e.rules = rules
+	i.headersCache.Add(rootCid, e)
+	return rules
+}
+
+// errInvalidHeadersFile is returned for `_headers` files that were retrieved,
+// but are not files or cannot be parsed.
+var errInvalidHeadersFile = errors.New("invalid _headers file")
+
+// readHeadersFile retrieves and parses the `_headers` file at the given root.
+func (i *handler) readHeadersFile(r *http.Request, rootCid cid.Cid, logger *zap.SugaredLogger) ([]headersRule, error) {
+	headersPath, err := path.Join(path.FromCid(rootCid), "_headers")
+	if err != nil {
+		return nil, err
+	}
+	imHeadersPath, err := path.NewImmutablePath(headersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, getResp, err := i.backend.Get(r.Context(), imHeadersPath)
+	if err != nil {
+		if isErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not retrieve _headers: %w", err)
+	}
+	defer getResp.Close()
+
+	if getResp.bytes == nil {
+		return nil, fmt.Errorf("%w: not a file", errInvalidHeadersFile)
+	}
+	rules, err := parseHeadersFile(getResp.bytes, logger)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidHeadersFile, err)
+	}
+	return rules, nil
+}
+
+// withCustomHeaders returns a ResponseWriter that sets the headers of the
+// `_headers` rules that match the request, once the response is written, so
+// that they take precedence over the headers set by the gateway.
+func (i *handler) withCustomHeaders(w http.ResponseWriter, r *http.Request, rq *requestData) http.ResponseWriter {
+	if !hasOriginIsolation(r) {
+		return w
+	}
+
+	rules := i.getHeadersRules(r, rq.immutablePath, rq.logger)
+	if len(rules) == 0 {
+		return w
+	}
+
+	// All paths start with /ipfs/cid/, so match the path after that.
+	urlPath := "/"
+	if pathParts := strings.Split(rq.immutablePath.String(), "/"); len(pathParts) > 3 {
+		urlPath += strings.Join(pathParts[3:], "/")
+	}
+	if urlPath != "/" {
+		urlPath = strings.TrimSuffix(urlPath, "/")
+	}
+
+	headers := matchHeadersRules(rules, urlPath)
+	if len(headers) == 0 {
+		return w
+	}
+
+	rq.logger.Debugw("applying headers from _headers file", "path", urlPath)
+	return &errRecordingResponseWriter{
+		ResponseWriter: w,
+		beforeWriteHeader: func() {
+			for name, values := range headers {
+				w.Header()[name] = values
+			}
+		},
+	}
+}
@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseHeadersFile(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseHeadersFile(strings.NewReader(`
+# Comment
+/*
+  X-Frame-Options: DENY
+  Content-Length: 1
+
+/blog/:slug/index.html
+  Cache-Control: no-cache
+
+/assets/*
+	Cache-Control: public, max-age=31536000
+	Link: </style.css>; rel=preload
+	Link: </app.js>; rel=preload
+`), zap.NewNop().Sugar())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+
+	for _, test := range []struct {
+		path     string
+		expected http.Header
+	}{
+		{"/", http.Header{"X-Frame-Options": {"DENY"}}},
+		{"/blog/hello/index.html", http.Header{"X-Frame-Options": {"DENY"}, "Cache-Control": {"no-cache"}}},
+		{"/blog/hello/world/index.html", http.Header{"X-Frame-Options": {"DENY"}}},
+		{"/assets", http.Header{"X-Frame-Options": {"DENY"}, "Cache-Control": {"public, max-age=31536000"}, "Link": {"</style.css>; rel=preload", "</app.js>; rel=preload"}}},
+		{"/assets/css/style.css", http.Header{"X-Frame-Options": {"DENY"}, "Cache-Control": {"public, max-age=31536000"}, "Link": {"</style.css>; rel=preload", "</app.js>; rel=preload"}}},
+		{"/assets-old/style.css", http.Header{"X-Frame-Options": {"DENY"}}},
+	} {
+		assert.Equal(t, test.expected, matchHeadersRules(rules, test.path), test.path)
+	}
+
+	for _, invalid := range []string{
+		"  X-Frame-Options: DENY\n",
+		"/*\n  X-Frame-Options\n",
+		"/*\n  Invalid Header: value\n",
+		"relative/*\n  X-Frame-Options: DENY\n",
+		"/*\n  X-Frame-Options: DENY\n" + strings.Repeat("#", maxHeadersFileSize),
+	} {
+		_, err := parseHeadersFile(strings.NewReader(invalid), zap.NewNop().Sugar())
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHeadersFile(t *testing.T) {
+	t.Parallel()
+
+	backend, root := newMockBackend(t, "headers-file.car")
+	backend.namesys["/ipns/example.com"] = path.FromCid(root)
+
+	ts := newTestServerWithConfig(t, backend, Config{
+		Headers:   map[string][]string{},
+		NoDNSLink: false,
+		PublicGateways: map[string]*PublicGateway{
+			"example.com": {
+				UseSubdomains:         true,
+				DeserializedResponses: true,
+			},
+		},
+		DeserializedResponses: true,
+	})
+
+	do := func(t *testing.T, method, host, urlPath string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+urlPath, nil)
+		require.NoError(t, err)
+		if host != "" {
+			req.Host = host
+		}
+
+		res := mustDoWithoutRedirect(t, req)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		return res
+	}
+
+	t.Run("Headers are set on files", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			res := do(t, method, "example.com", "/about.html")
+			assert.Equal(t, "DENY", res.Header.Get("X-Frame-Options"))
+			assert.Equal(t, "default-src 'self'", res.Header.Get("Content-Security-Policy"))
+			// The rules for /assets/* do not apply.
+			assert.NotEqual(t, "public, max-age=31536000", res.Header.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("Headers override the headers of the gateway", func(t *testing.T) {
+		res := do(t, http.MethodGet, "example.com", "/assets/style.css")
+		assert.Equal(t, "DENY", res.Header.Get("X-Frame-Options"))
+		assert.Equal(t, "public, max-age=31536000", res.Header.Get("Cache-Control"))
+		assert.Equal(t, "*", res.Header.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, res.Header.Get("Content-Security-Policy"))
+	})
+
+	t.Run("Headers are set on directories", func(t *testing.T) {
+		res := do(t, http.MethodGet, "example.com", "/")
+		assert.Equal(t, "DENY", res.Header.Get("X-Frame-Options"))
+		assert.Empty(t, res.Header.Get("Content-Security-Policy"))
+
+		res = do(t, http.MethodGet, "example.com", "/assets/")
+		assert.Equal(t, "public, max-age=31536000", res.Header.Get("Cache-Control"))
+	})
+
+	t.Run("Gateway headers cannot be set", func(t *testing.T) {
+		res := do(t, http.MethodGet, "example.com", "/about.html")
+		assert.NotEqual(t, "/ipfs/bafkqaaa", res.Header.Get("X-Ipfs-Path"))
+	})
+
+	t.Run("Headers are ignored without origin isolation", func(t *testing.T) {
+		res := do(t, http.MethodGet, "", "/ipfs/"+root.String()+"/about.html")
+		assert.Empty(t, res.Header.Get("X-Frame-Options"))
+		assert.Empty(t, res.Header.Get("Content-Security-Policy"))
+	})
+}
+
+// brokenHeadersBackend is a mockBackend that cannot serve `_headers` files.
+type brokenHeadersBackend struct {
+	*mockBackend
+
+	// headersErr is returned for `_headers` files, which are directories if
+	// it is nil.
+	headersErr  error
+	headersGets atomic.Int64
+}
+
+func (b *brokenHeadersBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	if !strings.HasSuffix(p.String(), "/_headers") {
+		return b.mockBackend.Get(ctx, p, ranges...)
+	}
+
+	b.headersGets.Add(1)
+	if b.headersErr != nil {
+		return ContentPathMetadata{}, nil, b.headersErr
+	}
+	entries := make(chan unixfs.LinkResult)
+	close(entries)
+	return ContentPathMetadata{}, NewGetResponseFromDirectoryListing(0, entries, nil), nil
+}
+
+func TestHeadersFileErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"Backend error", errors.New("backend is unavailable")},
+		{"Directory", nil},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mb, root := newMockBackend(t, "headers-file.car")
+			mb.namesys["/ipns/example.com"] = path.FromCid(root)
+			backend := &brokenHeadersBackend{mockBackend: mb, headersErr: test.err}
+
+			ts := newTestServerWithConfig(t, backend, Config{
+				PublicGateways: map[string]*PublicGateway{
+					"example.com": {
+						UseSubdomains:         true,
+						DeserializedResponses: true,
+					},
+				},
+				DeserializedResponses: true,
+			})
+
+			// The website is served without custom headers, and the
+			// `_headers` file is not retrieved again for the next request.
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest(http.MethodGet, ts.URL+"/about.html", nil)
+				require.NoError(t, err)
+				req.Host = "example.com"
+
+				res := mustDoWithoutRedirect(t, req)
+				res.Body.Close()
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+				assert.Empty(t, res.Header.Get("X-Frame-Options"))
+			}
+			assert.EqualValues(t, 1, backend.headersGets.Load())
+		})
+	}
+}
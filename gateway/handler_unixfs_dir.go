@@ -2,28 +2,35 @@ package gateway
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	gopath "path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/gateway/assets"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
 	"github.com/ipfs/boxo/path"
 	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 // serveDirectory returns the best representation of UnixFS directory
 //
 // It will return index.html if present, or generate directory listing otherwise.
-func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath path.ImmutablePath, contentPath path.Path, isHeadRequest bool, directoryMetadata *directoryMetadata, ranges []ByteRange, begin time.Time, logger *zap.SugaredLogger) bool {
+func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath path.ImmutablePath, contentPath path.Path, responseFormat string, isHeadRequest bool, directoryMetadata *directoryMetadata, ranges []ByteRange, begin time.Time, logger *zap.SugaredLogger) bool {
 	ctx, span := spanTrace(ctx, "Handler.ServeDirectory", trace.WithAttributes(attribute.String("path", resolvedPath.String())))
 	defer span.End()
 
@@ -39,6 +46,18 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 	}
 	originalURLPath := requestURI.Path
 
+	page, err := parseDirListingPage(r, i.dirListingDefaultLimit(responseFormat))
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return false
+	}
+
+	// JSON listings are meant for programmatic access: they are never
+	// redirected, and do not serve index.html.
+	if responseFormat == jsonResponseFormat {
+		return i.serveDirectoryJSON(ctx, w, r, resolvedPath, contentPath, directoryMetadata, page, begin)
+	}
+
 	// Ensure directory paths end with '/'
 	if originalURLPath[len(originalURLPath)-1] != '/' {
 		// don't redirect to trailing slash if it's go get
@@ -115,7 +134,7 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 	if err == nil {
 		logger.Debugw("serving index.html file", "path", idxPath)
 		// write to request
-		success := i.serveFile(ctx, w, r, resolvedPath, idxPath, idxFileSize, idxFileBytes, false, returnRangeStartsAtZero, "text/html", begin)
+		success := i.serveFile(ctx, w, r, resolvedPath, idxPath, imIndexPath, idxFileSize, idxFileBytes, false, returnRangeStartsAtZero, "text/html", begin)
 		if success {
 			i.unixfsDirIndexGetMetric.WithLabelValues(contentPath.Namespace()).Observe(time.Since(begin).Seconds())
 		}
@@ -134,7 +153,7 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 	w.Header().Set("Content-Type", "text/html")
 
 	// Generated dir index requires custom Etag (output may change between go-libipfs versions)
-	dirEtag := getDirListingEtag(resolvedPath.RootCid())
+	dirEtag := getDirListingEtag(resolvedPath.RootCid(), "", page)
 	w.Header().Set("Etag", dirEtag)
 
 	if r.Method == http.MethodHead {
@@ -142,16 +161,17 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 		return true
 	}
 
-	var dirListing []assets.DirectoryItem
-	for l := range directoryMetadata.entries {
-		if l.Err != nil {
-			i.webError(w, r, l.Err, http.StatusInternalServerError)
-			return false
-		}
+	links, nextCursor, err := readDirListingPage(directoryMetadata.entries, page)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return false
+	}
 
-		name := l.Link.Name
-		sz := l.Link.Size
-		linkCid := l.Link.Cid
+	var dirListing []assets.DirectoryItem
+	for _, l := range links {
+		name := l.Name
+		sz := l.Size
+		linkCid := l.Cid
 
 		hash := linkCid.String()
 		di := assets.DirectoryItem{
@@ -188,6 +208,14 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 		}
 	}
 
+	// Link to the next page, keeping the other query parameters.
+	var nextPage string
+	if nextCursor != "" {
+		query := r.URL.Query()
+		query.Set(dirListingCursorParam, nextCursor)
+		nextPage = (&url.URL{Path: originalURLPath, RawQuery: query.Encode()}).String()
+	}
+
 	size := humanize.Bytes(directoryMetadata.dagSize)
 	hash := resolvedPath.RootCid().String()
 	globalData := i.getTemplateGlobalData(r, contentPath)
@@ -201,6 +229,7 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 		Breadcrumbs: assets.Breadcrumbs(contentPath.String(), globalData.DNSLink),
 		BackLink:    backLink,
 		Hash:        hash,
+		NextPage:    nextPage,
 	}
 
 	logger.Debugw("request processed", "tplDataDNSLink", globalData.DNSLink, "tplDataSize", size, "tplDataBackLink", backLink, "tplDataHash", hash)
@@ -215,6 +244,248 @@ func (i *handler) serveDirectory(ctx context.Context, w http.ResponseWriter, r *
 	return true
 }
 
-func getDirListingEtag(dirCid cid.Cid) string {
-	return `"DirIndex-` + assets.AssetHash + `_CID-` + dirCid.String() + `"`
+// getDirListingEtag returns the Etag of a generated directory listing, which
+// depends on the listing format and on the requested page. The first page of
+// the default size keeps the Etag of unpaginated listings.
+func getDirListingEtag(dirCid cid.Cid, responseFormat string, page dirListingPage) string {
+	etag := `"DirIndex-` + assets.AssetHash + `_CID-` + dirCid.String()
+	if responseFormat == jsonResponseFormat {
+		etag += "_JSON"
+	}
+	if page.limit != page.defaultLimit {
+		etag += "_Limit-" + strconv.Itoa(page.limit)
+	}
+	if page.cursor != "" {
+		etag += "_Cursor-" + page.cursor
+	}
+	return etag + `"`
+}
+
+const (
+	// dirListingCursorParam is the query parameter with the cursor of the page
+	// of a generated directory listing.
+	dirListingCursorParam = "cursor"
+
+	// dirListingLimitParam is the query parameter with the maximum number of
+	// entries in a page of a generated directory listing.
+	dirListingLimitParam = "limit"
+
+	// dirListingDefaultLimit is the number of entries in a page of a JSON
+	// directory listing, when no limit is requested.
+	dirListingDefaultLimit = 1000
+
+	// dirListingMaxLimit is the maximum number of entries in a page of a
+	// generated directory listing.
+	dirListingMaxLimit = 10000
+
+	// dirListingTypeConcurrency is the maximum number of entries whose type is
+	// resolved in parallel for JSON listings.
+	dirListingTypeConcurrency = 16
+)
+
+// dirListingPage is a page of a generated directory listing. Entries are listed
+// in the order of the directory links, which is stable for a given CID, and
+// the cursor is the opaque encoding of the last name of the previous page.
+type dirListingPage struct {
+	cursor string
+	after  string
+	// limit is the maximum number of entries of the page, zero means all the
+	// remaining entries.
+	limit int
+	// defaultLimit is the limit of the pages when none is requested.
+	defaultLimit int
+}
+
+// dirListingDefaultLimit returns the number of entries in a page of a listing
+// in the given format, when no limit is requested. JSON listings are always
+// paginated, HTML ones unless [Config.DirectoryListingPageSize] is negative.
+func (i *handler) dirListingDefaultLimit(responseFormat string) int {
+	if responseFormat == jsonResponseFormat || i.config.DirectoryListingPageSize == 0 {
+		return dirListingDefaultLimit
+	}
+	if i.config.DirectoryListingPageSize < 0 {
+		return 0
+	}
+	return i.config.DirectoryListingPageSize
+}
+
+// parseDirListingPage returns the page requested by r. defaultLimit is used
+// when the request has no limit.
+func parseDirListingPage(r *http.Request, defaultLimit int) (dirListingPage, error) {
+	query := r.URL.Query()
+	if defaultLimit > dirListingMaxLimit {
+		defaultLimit = dirListingMaxLimit
+	}
+	page := dirListingPage{
+		cursor:       query.Get(dirListingCursorParam),
+		limit:        defaultLimit,
+		defaultLimit: defaultLimit,
+	}
+
+	if page.cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(page.cursor)
+		if err != nil || len(after) == 0 {
+			return page, fmt.Errorf("invalid %s parameter: %q", dirListingCursorParam, page.cursor)
+		}
+		page.after = string(after)
+	}
+
+	if limitStr := query.Get(dirListingLimitParam); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("invalid %s parameter: %q", dirListingLimitParam, limitStr)
+		}
+		if limit > dirListingMaxLimit {
+			limit = dirListingMaxLimit
+		}
+		page.limit = limit
+	}
+
+	return page, nil
+}
+
+func encodeDirListingCursor(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+// readDirListingPage reads the entries of a directory up to the end of the
+// given page, and returns the entries of the page and the cursor of the next
+// page, if any. The entries may start at the cursor entry instead of the first
+// one, and the entries after the page are not read.
+func readDirListingPage(entries <-chan unixfs.LinkResult, page dirListingPage) ([]*ipld.Link, string, error) {
+	var links []*ipld.Link
+	found := page.cursor == ""
+	for l := range entries {
+		if l.Err != nil {
+			return nil, "", l.Err
+		}
+
+		if !found {
+			found = l.Link.Name == page.after
+			continue
+		}
+
+		if page.limit > 0 && len(links) == page.limit {
+			return links, encodeDirListingCursor(links[len(links)-1].Name), nil
+		}
+		links = append(links, l.Link)
+	}
+
+	if !found {
+		err := fmt.Errorf("invalid %s parameter: no entry named %q", dirListingCursorParam, page.after)
+		return nil, "", NewErrorStatusCode(err, http.StatusBadRequest)
+	}
+	return links, "", nil
+}
+
+// DirectoryListing is the JSON representation of a page of a UnixFS directory
+// listing, returned for requests with "Accept: application/json".
+type DirectoryListing struct {
+	Path       string
+	Cid        string
+	Size       uint64
+	Entries    []DirectoryListingEntry
+	NextCursor string `json:",omitempty"`
+}
+
+// DirectoryListingEntry is an entry of a [DirectoryListing]. Size is the
+// cumulative size of the entry DAG, and Type is one of "file", "directory" or
+// "symlink".
+type DirectoryListingEntry struct {
+	Name string
+	Cid  string
+	Size uint64
+	Type string
+}
+
+func (i *handler) serveDirectoryJSON(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath path.ImmutablePath, contentPath path.Path, directoryMetadata *directoryMetadata, page dirListingPage, begin time.Time) bool {
+	w.Header().Set("Content-Type", jsonResponseFormat)
+	w.Header().Set("Etag", getDirListingEtag(resolvedPath.RootCid(), jsonResponseFormat, page))
+
+	if r.Method == http.MethodHead {
+		return true
+	}
+
+	links, nextCursor, err := readDirListingPage(directoryMetadata.entries, page)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return false
+	}
+
+	entries := make([]DirectoryListingEntry, len(links))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(dirListingTypeConcurrency)
+	for idx, l := range links {
+		idx, l := idx, l
+		entries[idx] = DirectoryListingEntry{
+			Name: l.Name,
+			Cid:  l.Cid.String(),
+			Size: l.Size,
+		}
+		g.Go(func() error {
+			entryType, err := i.unixfsEntryType(gctx, l.Cid)
+			if err != nil {
+				return fmt.Errorf("failed to resolve type of %q: %w", l.Name, err)
+			}
+			entries[idx].Type = entryType
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return false
+	}
+
+	err = json.NewEncoder(w).Encode(DirectoryListing{
+		Path:       contentPath.String(),
+		Cid:        resolvedPath.RootCid().String(),
+		Size:       directoryMetadata.dagSize,
+		Entries:    entries,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return false
+	}
+
+	i.unixfsGenDirListingGetMetric.WithLabelValues(contentPath.Namespace()).Observe(time.Since(begin).Seconds())
+	return true
+}
+
+// unixfsEntryType returns the type of the UnixFS node with the given CID. The
+// directory links do not tell the type of their target, so it is read from the
+// root block of the entry, without fetching the rest of its DAG.
+func (i *handler) unixfsEntryType(ctx context.Context, c cid.Cid) (string, error) {
+	// Raw blocks are always file leaves, no need to fetch them.
+	if c.Prefix().Codec == cid.Raw {
+		return "file", nil
+	}
+
+	_, block, err := i.backend.GetBlock(ctx, path.FromCid(c))
+	if err != nil {
+		return "", err
+	}
+	defer block.Close()
+
+	data, err := io.ReadAll(block)
+	if err != nil {
+		return "", err
+	}
+	nd, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return "", err
+	}
+	fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case fsn.IsDir():
+		return "directory", nil
+	case fsn.Type() == unixfs.TSymlink:
+		return "symlink", nil
+	default:
+		return "file", nil
+	}
 }
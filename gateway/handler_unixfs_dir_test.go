@@ -2,11 +2,19 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
 	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -88,3 +96,214 @@ func TestIPNSHostnameBacklinks(t *testing.T) {
 	require.Contains(t, s, "<a href=\"/foo%3F%20%23%3C%27/bar/file.txt\">", "expected file in directory listing")
 	require.Contains(t, s, k3.RootCid().String(), "expected hash in directory listing")
 }
+
+func newDirectoryListingTestServer(t *testing.T, config Config) (string, path.ImmutablePath) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := newWritableBlocksBackend(t)
+	config.DeserializedResponses = true
+	ts := newTestServerWithConfig(t, backend, config)
+
+	root, err := backend.AddFile(ctx, files.NewMapDirectory(map[string]files.Node{
+		"c.txt": files.NewBytesFile([]byte("hello c")),
+		"a.txt": files.NewBytesFile([]byte("hello a")),
+		"d":     files.NewMapDirectory(map[string]files.Node{}),
+		"b":     files.NewLinkFile("a.txt", nil),
+	}))
+	require.NoError(t, err)
+
+	return ts.URL, root
+}
+
+func TestDirectoryListingJSON(t *testing.T) {
+	tsURL, root := newDirectoryListingTestServer(t, Config{})
+
+	getListing := func(t *testing.T, url string) DirectoryListing {
+		req := mustNewRequest(t, http.MethodGet, url, nil)
+		req.Header.Set("Accept", jsonResponseFormat)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, jsonResponseFormat, res.Header.Get("Content-Type"))
+
+		var listing DirectoryListing
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+		return listing
+	}
+
+	t.Run("Lists all entries in link order", func(t *testing.T) {
+		listing := getListing(t, tsURL+root.String())
+		assert.Equal(t, root.String(), listing.Path)
+		assert.Equal(t, root.RootCid().String(), listing.Cid)
+		assert.Empty(t, listing.NextCursor)
+
+		require.Len(t, listing.Entries, 4)
+		var names, types []string
+		for _, e := range listing.Entries {
+			names = append(names, e.Name)
+			types = append(types, e.Type)
+			assert.NotEmpty(t, e.Cid)
+		}
+		assert.Equal(t, []string{"a.txt", "b", "c.txt", "d"}, names)
+		assert.Equal(t, []string{"file", "symlink", "file", "directory"}, types)
+	})
+
+	t.Run("Paginates with a cursor", func(t *testing.T) {
+		first := getListing(t, tsURL+root.String()+"/?limit=3")
+		require.Len(t, first.Entries, 3)
+		assert.Equal(t, "c.txt", first.Entries[2].Name)
+		require.NotEmpty(t, first.NextCursor)
+
+		second := getListing(t, tsURL+root.String()+"/?limit=3&cursor="+first.NextCursor)
+		require.Len(t, second.Entries, 1)
+		assert.Equal(t, "d", second.Entries[0].Name)
+		assert.Empty(t, second.NextCursor)
+	})
+
+	t.Run("Each page has its own Etag", func(t *testing.T) {
+		req := mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/?limit=3", nil)
+		req.Header.Set("Accept", jsonResponseFormat)
+		res := mustDoWithoutRedirect(t, req)
+		res.Body.Close()
+		etag := res.Header.Get("Etag")
+		assert.Regexp(t, `^"DirIndex-(.*)_CID-`+root.RootCid().String()+`_JSON_Limit-3"$`, etag)
+
+		req = mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/?limit=2", nil)
+		req.Header.Set("Accept", jsonResponseFormat)
+		req.Header.Set("If-None-Match", etag)
+		res = mustDoWithoutRedirect(t, req)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		req = mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/?limit=3", nil)
+		req.Header.Set("Accept", jsonResponseFormat)
+		req.Header.Set("If-None-Match", etag)
+		res = mustDoWithoutRedirect(t, req)
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("Invalid cursor or limit returns 400", func(t *testing.T) {
+		for _, query := range []string{"cursor=!!!", "cursor=" + encodeDirListingCursor("missing"), "limit=0", "limit=abc"} {
+			req := mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/?"+query, nil)
+			req.Header.Set("Accept", jsonResponseFormat)
+			res := mustDoWithoutRedirect(t, req)
+			res.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+		}
+	})
+}
+
+func TestDirectoryListingHTMLPagination(t *testing.T) {
+	tsURL, root := newDirectoryListingTestServer(t, Config{})
+
+	t.Run("Paginated by default", func(t *testing.T) {
+		i := &handler{config: &Config{}}
+		assert.Equal(t, dirListingDefaultLimit, i.dirListingDefaultLimit(""))
+
+		res := mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/", nil))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		s := mustReadBody(t, res)
+		assert.Contains(t, s, "a.txt")
+		assert.Contains(t, s, root.String()+"/d\"")
+		assert.NotContains(t, s, `rel="next"`)
+	})
+
+	t.Run("Not paginated when disabled", func(t *testing.T) {
+		i := &handler{config: &Config{DirectoryListingPageSize: -1}}
+		assert.Zero(t, i.dirListingDefaultLimit(""))
+		assert.Equal(t, dirListingDefaultLimit, i.dirListingDefaultLimit(jsonResponseFormat))
+	})
+
+	t.Run("Paginated with the configured page size", func(t *testing.T) {
+		tsURL, root := newDirectoryListingTestServer(t, Config{DirectoryListingPageSize: 3})
+		res := mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/", nil))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		s := mustReadBody(t, res)
+		assert.Contains(t, s, "c.txt")
+		assert.NotContains(t, s, root.String()+"/d\"")
+		assert.Contains(t, s, `rel="next"`)
+	})
+
+	res := mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, tsURL+root.String()+"/?limit=2", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	s := mustReadBody(t, res)
+
+	assert.Contains(t, s, "a.txt")
+	assert.Contains(t, s, root.String()+"/b\"")
+	assert.NotContains(t, s, "c.txt")
+
+	// Follow the link to the next page.
+	start := strings.Index(s, `href="`+root.String()+`/?`)
+	require.NotEqual(t, -1, start, "expected link to the next page")
+	nextPage := s[start+len(`href="`):]
+	nextPage = strings.ReplaceAll(nextPage[:strings.Index(nextPage, `"`)], "&amp;", "&")
+	assert.Contains(t, nextPage, "limit=2")
+
+	res = mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, tsURL+nextPage, nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	s = mustReadBody(t, res)
+
+	assert.NotContains(t, s, "a.txt")
+	assert.Contains(t, s, "c.txt")
+	assert.Contains(t, s, root.String()+"/d\"")
+	assert.NotContains(t, s, `rel="next"`)
+}
+
+func TestDirectoryListingHAMTPagination(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := newWritableBlocksBackend(t)
+	ts := newTestServerWithConfig(t, backend, Config{DeserializedResponses: true})
+
+	// A narrow HAMT, so that the entries are spread over nested shards.
+	shard, err := hamt.NewShard(backend.dagService, 16)
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("file-%d", i)
+		nd := merkledag.NodeWithData(unixfs.FilePBData([]byte(name), uint64(len(name))))
+		require.NoError(t, backend.dagService.Add(ctx, nd))
+		require.NoError(t, shard.Set(ctx, name, nd))
+		names[name] = true
+	}
+	nd, err := shard.Node()
+	require.NoError(t, err)
+	require.NoError(t, backend.dagService.Add(ctx, nd))
+	root := path.FromCid(nd.Cid())
+
+	getListing := func(t *testing.T, query string) DirectoryListing {
+		req := mustNewRequest(t, http.MethodGet, ts.URL+root.String()+"/?"+query, nil)
+		req.Header.Set("Accept", jsonResponseFormat)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var listing DirectoryListing
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+		return listing
+	}
+
+	all := getListing(t, "limit=1000")
+	require.Len(t, all.Entries, len(names))
+
+	// The pages resume at the cursor, and list every entry once, in order.
+	var paged []DirectoryListingEntry
+	listing := getListing(t, "limit=70")
+	for {
+		paged = append(paged, listing.Entries...)
+		if listing.NextCursor == "" {
+			break
+		}
+		listing = getListing(t, "limit=70&cursor="+listing.NextCursor)
+	}
+	assert.Equal(t, all.Entries, paged)
+
+	req := mustNewRequest(t, http.MethodGet, ts.URL+root.String()+"/?cursor="+encodeDirListingCursor("missing"), nil)
+	req.Header.Set("Accept", jsonResponseFormat)
+	res := mustDoWithoutRedirect(t, req)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
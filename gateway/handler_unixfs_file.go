@@ -3,6 +3,7 @@ package gateway
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -12,14 +13,20 @@ import (
 	"time"
 
 	"github.com/gabriel-vasile/mimetype"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // serveFile returns data behind a file along with HTTP headers based on
 // the file itself, its CID and the contentPath used for accessing it.
-func (i *handler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath path.ImmutablePath, contentPath path.Path, fileSize int64, fileBytes io.ReadCloser, isSymlink bool, returnRangeStartsAtZero bool, fileContentType string, begin time.Time) bool {
+//
+// filePath is the immutable path of the file within its parent directory, where
+// pre-compressed siblings are looked up. It is the zero value if the file has
+// no parent directory, or if it is not known.
+func (i *handler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath path.ImmutablePath, contentPath path.Path, filePath path.ImmutablePath, fileSize int64, fileBytes io.ReadCloser, isSymlink bool, returnRangeStartsAtZero bool, fileContentType string, begin time.Time) bool {
 	_, span := spanTrace(ctx, "Handler.ServeFile", trace.WithAttributes(attribute.String("path", resolvedPath.String())))
 	defer span.End()
 
@@ -69,6 +76,13 @@ func (i *handler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.
 
 			ctype = mimeType.String()
 			content = io.MultiReader(&buf, fileBytes)
+			// Seekable files are rewound instead, so that they stay seekable
+			// for multi-range requests.
+			if seeker, ok := fileBytes.(io.Seeker); ok {
+				if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+					content = fileBytes
+				}
+			}
 		}
 		// Strip the encoding from the HTML Content-Type header and let the
 		// browser figure it out.
@@ -82,9 +96,49 @@ func (i *handler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.
 	// (unifies behavior across gateways and web browsers)
 	w.Header().Set("Content-Type", ctype)
 
+	var cw *compressResponseWriter
+	if !isSymlink && !i.config.Compression.isZero() {
+		// The response depends on the encodings accepted by the client.
+		w.Header().Add("Vary", "Accept-Encoding")
+		encodings := acceptedEncodings(r)
+
+		if i.config.Compression.Precompressed {
+			if sibling := i.getPrecompressedSibling(ctx, r, filePath, encodings); sibling != nil {
+				defer sibling.close()
+				w.Header().Set("Content-Encoding", sibling.encoding)
+				w.Header().Set("Etag", `"`+sibling.cid.String()+`"`)
+				fileSize, content = sibling.size, sibling.content
+				// Pre-compressed siblings are not compressed again.
+				encodings = nil
+			}
+		}
+
+		if i.config.Compression.MaxSize > 0 && fileSize <= i.config.Compression.MaxSize &&
+			r.Header.Get("Range") == "" && isCompressibleContentType(ctype) {
+			// The encodings are ordered by the preference of the client.
+			for _, name := range encodings {
+				newWriter, ok := compressOnTheFly(name)
+				if !ok {
+					continue
+				}
+				enc, _ := contentEncodingByName(name)
+				// The compressed output is not guaranteed to be identical
+				// across implementations, hence the weak ETag.
+				w.Header().Set("Content-Encoding", name)
+				w.Header().Set("Etag", `W/"`+resolvedPath.RootCid().String()+enc.ext+`"`)
+				cw = &compressResponseWriter{ResponseWriter: w, newWriter: newWriter}
+				w = cw
+				break
+			}
+		}
+	}
+
 	// ServeContent will take care of
 	// If-None-Match+Etag, Content-Length and range requests
 	_, dataSent, _ := serveContent(w, r, modtime, fileSize, content)
+	if cw != nil && cw.Close() != nil {
+		dataSent = false
+	}
 
 	// Was response successful?
 	if dataSent {
@@ -94,3 +148,131 @@ func (i *handler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.
 
 	return dataSent
 }
+
+// precompressedSibling is a pre-compressed sibling of a file, such as
+// file.js.br for file.js.
+type precompressedSibling struct {
+	encoding string
+	cid      cid.Cid
+	size     int64
+	content  io.Reader
+	close    func() error
+}
+
+// precompressedCacheSize is the number of files whose known pre-compressed
+// siblings are cached.
+const precompressedCacheSize = 4096
+
+func newPrecompressedCache() *lru.Cache[string, map[string]bool] {
+	cache, err := lru.New[string, map[string]bool](precompressedCacheSize)
+	if err != nil {
+		// Only happens with a non-positive size.
+		panic(err)
+	}
+	return cache
+}
+
+// getPrecompressedSibling returns the pre-compressed sibling of the file at
+// filePath with the most preferred of the accepted encodings, or nil if there
+// is none. Siblings are fetched with the range of the request, if any.
+//
+// Whether a sibling exists is cached per file path, which is immutable, so
+// that missing siblings are not looked up again on every request.
+func (i *handler) getPrecompressedSibling(ctx context.Context, r *http.Request, filePath path.ImmutablePath, encodings []string) *precompressedSibling {
+	if !filePath.RootCid().Defined() || len(filePath.Segments()) <= 2 {
+		return nil
+	}
+
+	ranges, err := parseRangeWithoutLength(r.Header.Get("Range"))
+	if err != nil {
+		return nil
+	}
+
+	key := filePath.String()
+	known, _ := i.precompressedCache.Get(key)
+
+	// The encodings are ordered by the preference of the client.
+	for _, name := range encodings {
+		enc, ok := contentEncodingByName(name)
+		if !ok {
+			continue
+		}
+		if exists, ok := known[name]; ok && !exists {
+			continue
+		}
+
+		p, err := path.NewPath(strings.TrimSuffix(key, "/") + enc.ext)
+		if err != nil {
+			continue
+		}
+		siblingPath, err := path.NewImmutablePath(p)
+		if err != nil {
+			continue
+		}
+
+		if r.Method == http.MethodHead {
+			md, headResp, err := i.backend.Head(ctx, siblingPath)
+			if err != nil {
+				i.cachePrecompressedSibling(key, name, err)
+				continue
+			}
+			if !headResp.isFile {
+				headResp.Close()
+				i.cachePrecompressedSibling(key, name, errNotAFile)
+				continue
+			}
+			i.cachePrecompressedSibling(key, name, nil)
+			return &precompressedSibling{encoding: name, cid: md.LastSegment.RootCid(), size: headResp.bytesSize, close: headResp.Close}
+		}
+
+		md, getResp, err := i.backend.Get(ctx, siblingPath, ranges...)
+		if err != nil {
+			i.cachePrecompressedSibling(key, name, err)
+			continue
+		}
+		if getResp.bytes == nil {
+			getResp.Close()
+			i.cachePrecompressedSibling(key, name, errNotAFile)
+			continue
+		}
+		i.cachePrecompressedSibling(key, name, nil)
+		return &precompressedSibling{encoding: name, cid: md.LastSegment.RootCid(), size: getResp.bytesSize, content: getResp.bytes, close: getResp.Close}
+	}
+
+	return nil
+}
+
+// errNotAFile is the error of pre-compressed siblings that are not files.
+var errNotAFile = errors.New("not a file")
+
+// cachePrecompressedSibling records whether the pre-compressed sibling of the
+// file at key with the given encoding exists, based on the error of its lookup.
+// Errors other than the sibling being missing or not a file may be transient,
+// and are not cached.
+func (i *handler) cachePrecompressedSibling(key, encoding string, err error) {
+	if err != nil && err != errNotAFile && !isErrNotFound(err) {
+		return
+	}
+
+	// Entries are replaced rather than updated, as they may be read
+	// concurrently.
+	known, _ := i.precompressedCache.Get(key)
+	if exists, ok := known[encoding]; ok && exists == (err == nil) {
+		return
+	}
+	updated := make(map[string]bool, len(known)+1)
+	for name, exists := range known {
+		updated[name] = exists
+	}
+	updated[encoding] = err == nil
+	i.precompressedCache.Add(key, updated)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		header   string
+		expected []string
+	}{
+		{"", nil},
+		{"identity", nil},
+		{"gzip", []string{"gzip"}},
+		{"gzip, deflate, br", []string{"br", "gzip"}},
+		{"br;q=0.5, gzip", []string{"gzip", "br"}},
+		{"br;q=0, gzip", []string{"gzip"}},
+		{"*", []string{"br", "gzip"}},
+		{"*;q=0.1, GZIP", []string{"gzip", "br"}},
+	} {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		r.Header.Set("Accept-Encoding", test.header)
+		assert.Equal(t, test.expected, acceptedEncodings(r), test.header)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	backend, root := newMockBackend(t, "compression.car")
+	rootURL := "/ipfs/" + root.String()
+
+	newServer := func(t *testing.T, compression Compression) string {
+		ts := newTestServerWithConfig(t, backend, Config{
+			DeserializedResponses: true,
+			Compression:           compression,
+		})
+		return ts.URL
+	}
+
+	do := func(t *testing.T, method, url string, headers map[string]string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		// Transparent decompression would hide the Content-Encoding.
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	_, appJs := do(t, http.MethodGet, newServer(t, Compression{})+rootURL+"/app.js", nil)
+	_, styleCss := do(t, http.MethodGet, newServer(t, Compression{})+rootURL+"/style.css", nil)
+	brRes, appJsBr := do(t, http.MethodGet, newServer(t, Compression{})+rootURL+"/app.js.br", nil)
+	gzRes, appJsGz := do(t, http.MethodGet, newServer(t, Compression{})+rootURL+"/app.js.gz", nil)
+
+	t.Run("Compression is disabled by default", func(t *testing.T) {
+		res, body := do(t, http.MethodGet, newServer(t, Compression{})+rootURL+"/app.js", map[string]string{"Accept-Encoding": "br, gzip"})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		assert.Empty(t, res.Header.Get("Vary"))
+		assert.Equal(t, appJs, body)
+	})
+
+	url := newServer(t, Compression{Precompressed: true, MaxSize: 1 << 20})
+
+	t.Run("Pre-compressed siblings are served by preference", func(t *testing.T) {
+		for _, test := range []struct {
+			acceptEncoding string
+			encoding       string
+			etag           string
+			body           []byte
+		}{
+			{"gzip, br", "br", brRes.Header.Get("Etag"), appJsBr},
+			{"gzip", "gzip", gzRes.Header.Get("Etag"), appJsGz},
+			{"br;q=0.5, gzip", "gzip", gzRes.Header.Get("Etag"), appJsGz},
+		} {
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				res, body := do(t, method, url+rootURL+"/app.js", map[string]string{"Accept-Encoding": test.acceptEncoding})
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+				assert.Equal(t, test.encoding, res.Header.Get("Content-Encoding"))
+				assert.Equal(t, test.etag, res.Header.Get("Etag"))
+				assert.Equal(t, "Accept-Encoding", res.Header.Get("Vary"))
+				assert.True(t, strings.HasPrefix(res.Header.Get("Content-Type"), "text/javascript"), res.Header.Get("Content-Type"))
+				if method == http.MethodGet {
+					assert.Equal(t, test.body, body)
+				}
+			}
+		}
+	})
+
+	t.Run("Range requests apply to pre-compressed siblings", func(t *testing.T) {
+		res, body := do(t, http.MethodGet, url+rootURL+"/app.js", map[string]string{"Accept-Encoding": "br", "Range": "bytes=2-5"})
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, "br", res.Header.Get("Content-Encoding"))
+		assert.Equal(t, appJsBr[2:6], body)
+	})
+
+	t.Run("Pre-compressed sibling is not modified", func(t *testing.T) {
+		res, _ := do(t, http.MethodGet, url+rootURL+"/app.js", map[string]string{"Accept-Encoding": "br", "If-None-Match": brRes.Header.Get("Etag")})
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("Files are compressed on the fly by preference", func(t *testing.T) {
+		styleCssRes, _ := do(t, http.MethodGet, url+rootURL+"/style.css", nil)
+		styleCssCid := strings.Trim(styleCssRes.Header.Get("Etag"), `"`)
+
+		for _, test := range []struct {
+			acceptEncoding string
+			encoding       string
+			etag           string
+			newReader      func(io.Reader) (io.Reader, error)
+		}{
+			{"gzip, br", "br", `W/"` + styleCssCid + `.br"`, func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+			{"br;q=0.5, gzip", "gzip", `W/"` + styleCssCid + `.gz"`, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		} {
+			res, body := do(t, http.MethodGet, url+rootURL+"/style.css", map[string]string{"Accept-Encoding": test.acceptEncoding})
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, test.encoding, res.Header.Get("Content-Encoding"))
+			assert.Equal(t, test.etag, res.Header.Get("Etag"))
+			assert.Equal(t, "Accept-Encoding", res.Header.Get("Vary"))
+			assert.Less(t, len(body), len(styleCss))
+
+			r, err := test.newReader(bytes.NewReader(body))
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, styleCss, decompressed)
+
+			res, body = do(t, http.MethodGet, url+rootURL+"/style.css", map[string]string{"Accept-Encoding": test.acceptEncoding, "If-None-Match": test.etag})
+			assert.Equal(t, http.StatusNotModified, res.StatusCode)
+			assert.Empty(t, body)
+		}
+	})
+
+	t.Run("Range requests are not compressed on the fly", func(t *testing.T) {
+		res, body := do(t, http.MethodGet, url+rootURL+"/style.css", map[string]string{"Accept-Encoding": "br, gzip", "Range": "bytes=0-3"})
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		assert.Equal(t, styleCss[:4], body)
+	})
+
+	t.Run("Files are not compressed on the fly above the maximum size", func(t *testing.T) {
+		url := newServer(t, Compression{MaxSize: 16})
+		res, body := do(t, http.MethodGet, url+rootURL+"/style.css", map[string]string{"Accept-Encoding": "br, gzip"})
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		assert.Equal(t, styleCss, body)
+	})
+
+	t.Run("Incompressible files are not compressed", func(t *testing.T) {
+		res, _ := do(t, http.MethodGet, url+rootURL+"/image.png", map[string]string{"Accept-Encoding": "br, gzip"})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("Files are not compressed without Accept-Encoding", func(t *testing.T) {
+		res, body := do(t, http.MethodGet, url+rootURL+"/app.js", nil)
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", res.Header.Get("Vary"))
+		assert.Equal(t, appJs, body)
+	})
+
+	t.Run("Missing pre-compressed siblings are looked up once", func(t *testing.T) {
+		counting := &siblingCountingBackend{IPFSBackend: backend}
+		ts := newTestServerWithConfig(t, counting, Config{
+			DeserializedResponses: true,
+			Compression:           Compression{Precompressed: true},
+		})
+
+		for i := 0; i < 3; i++ {
+			res, body := do(t, http.MethodGet, ts.URL+rootURL+"/style.css", map[string]string{"Accept-Encoding": "br, gzip"})
+			assert.Empty(t, res.Header.Get("Content-Encoding"))
+			assert.Equal(t, styleCss, body)
+		}
+		assert.EqualValues(t, 2, counting.siblingLookups.Load())
+	})
+}
+
+// siblingCountingBackend counts the lookups of pre-compressed siblings.
+type siblingCountingBackend struct {
+	IPFSBackend
+	siblingLookups atomic.Int64
+}
+
+func (b *siblingCountingBackend) count(p path.ImmutablePath) {
+	if strings.HasSuffix(p.String(), ".br") || strings.HasSuffix(p.String(), ".gz") {
+		b.siblingLookups.Add(1)
+	}
+}
+
+func (b *siblingCountingBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	b.count(p)
+	return b.IPFSBackend.Get(ctx, p, ranges...)
+}
+
+func (b *siblingCountingBackend) Head(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, *HeadResponse, error) {
+	b.count(p)
+	return b.IPFSBackend.Head(ctx, p)
+}
+
+func TestMultiRangeRequests(t *testing.T) {
+	t.Parallel()
+
+	backend, root := newMockBackend(t, "compression.car")
+	ts := newTestServer(t, backend)
+	fileURL := ts.URL + "/ipfs/" + root.String() + "/style.css"
+
+	do := func(t *testing.T, method, url, rangeHeader string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", rangeHeader)
+
+		res := mustDo(t, req)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	_, content := do(t, http.MethodGet, fileURL, "")
+	size := strconv.Itoa(len(content))
+
+	readParts := func(t *testing.T, res *http.Response, body []byte, contentType string) map[string][]byte {
+		mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		parts := map[string][]byte{}
+		mr := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return parts
+			}
+			require.NoError(t, err)
+			assert.Equal(t, contentType, part.Header.Get("Content-Type"))
+			data, err := io.ReadAll(part)
+			require.NoError(t, err)
+			parts[part.Header.Get("Content-Range")] = data
+		}
+	}
+
+	last := len(content) - 5
+	expected := map[string][]byte{
+		"bytes 0-3/" + size:   content[0:4],
+		"bytes 10-14/" + size: content[10:15],
+		"bytes " + strconv.Itoa(last) + "-" + strconv.Itoa(len(content)-1) + "/" + size: content[last:],
+	}
+
+	for _, test := range []struct {
+		name        string
+		url         string
+		contentType string
+	}{
+		{"UnixFS file", fileURL, "text/css; charset=utf-8"},
+		{"Raw block", fileURL + "?format=raw", rawResponseFormat},
+	} {
+		test := test
+		t.Run("Multiple ranges of a "+test.name, func(t *testing.T) {
+			res, body := do(t, http.MethodGet, test.url, "bytes=0-3, 10-14, -5")
+			require.Equal(t, http.StatusPartialContent, res.StatusCode)
+			assert.Equal(t, strconv.Itoa(len(body)), res.Header.Get("Content-Length"))
+			assert.Equal(t, expected, readParts(t, res, body, test.contentType))
+		})
+	}
+
+	t.Run("Multiple ranges with HEAD", func(t *testing.T) {
+		res, body := do(t, http.MethodHead, fileURL, "bytes=0-3, 10-14")
+		require.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.True(t, strings.HasPrefix(res.Header.Get("Content-Type"), "multipart/byteranges; boundary="))
+		assert.Empty(t, body)
+	})
+
+	t.Run("Single range is not multipart", func(t *testing.T) {
+		res, body := do(t, http.MethodGet, fileURL, "bytes=10-14")
+		require.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, "bytes 10-14/"+size, res.Header.Get("Content-Range"))
+		assert.Equal(t, content[10:15], body)
+	})
+}
@@ -0,0 +1,195 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	gopath "path"
+	"strings"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// isWritable returns true if the gateway is configured to accept requests that
+// add or modify content, and the backend supports it.
+func (i *handler) isWritable() bool {
+	return i.config.Writable && i.writableBackend != nil
+}
+
+// postHandler adds new content from the request body and responds with the
+// path of its root. The body can be a CAR, a multipart directory or a raw file.
+//
+// Example: POST /ipfs/
+func (i *handler) postHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := spanTrace(r.Context(), "Handler.ServePOST", trace.WithAttributes(attribute.String("path", r.URL.Path)))
+	defer span.End()
+
+	if strings.TrimSuffix(r.URL.Path, "/") != strings.TrimSuffix(ipfsPathPrefix, "/") {
+		i.webError(w, r, fmt.Errorf("new content can only be added with POST %s", ipfsPathPrefix), http.StatusBadRequest)
+		return
+	}
+
+	mediaType, params, err := parseRequestContentType(r)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	var newPath path.ImmutablePath
+	if mediaType == carResponseFormat {
+		newPath, err = i.writableBackend.AddCAR(ctx, r.Body)
+	} else {
+		var node files.Node
+		node, err = requestBodyToNode(r, mediaType, params)
+		if err != nil {
+			i.webError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		newPath, err = i.writableBackend.AddFile(ctx, node)
+	}
+	if err != nil {
+		i.webError(w, r, fmt.Errorf("failed to add content: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	i.writeCreated(w, r, newPath.String())
+}
+
+// putHandler writes the request body to the given path, which must be an
+// immutable path with at least one segment after the root CID, and responds
+// with the same path under the new root.
+//
+// Example: PUT /ipfs/{cid}/path/to/file
+func (i *handler) putHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := spanTrace(r.Context(), "Handler.ServePUT", trace.WithAttributes(attribute.String("path", r.URL.Path)))
+	defer span.End()
+
+	contentPath, ok := i.parseWritablePath(w, r)
+	if !ok {
+		return
+	}
+
+	mediaType, params, err := parseRequestContentType(r)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if mediaType == carResponseFormat {
+		i.webError(w, r, fmt.Errorf("CAR uploads are only supported with POST %s", ipfsPathPrefix), http.StatusBadRequest)
+		return
+	}
+
+	node, err := requestBodyToNode(r, mediaType, params)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	newRoot, err := i.writableBackend.Put(ctx, contentPath, node)
+	if err != nil {
+		i.webError(w, r, fmt.Errorf("failed to put %s: %w", debugStr(contentPath.String()), err), http.StatusInternalServerError)
+		return
+	}
+
+	i.writeCreated(w, r, gopath.Join(append([]string{newRoot.String()}, contentPath.Segments()[2:]...)...))
+}
+
+// deleteHandler removes the entry at the given path, which must be an immutable
+// path with at least one segment after the root CID, and responds with the
+// parent directory path under the new root.
+//
+// Example: DELETE /ipfs/{cid}/path/to/file
+func (i *handler) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := spanTrace(r.Context(), "Handler.ServeDELETE", trace.WithAttributes(attribute.String("path", r.URL.Path)))
+	defer span.End()
+
+	contentPath, ok := i.parseWritablePath(w, r)
+	if !ok {
+		return
+	}
+
+	newRoot, err := i.writableBackend.Delete(ctx, contentPath)
+	if err != nil {
+		i.webError(w, r, fmt.Errorf("failed to delete %s: %w", debugStr(contentPath.String()), err), http.StatusInternalServerError)
+		return
+	}
+
+	segments := contentPath.Segments()[2:]
+	parent := gopath.Join(append([]string{newRoot.String()}, segments[:len(segments)-1]...)...)
+	i.writeCreated(w, r, parent)
+}
+
+// parseWritablePath parses the request path for PUT and DELETE requests.
+func (i *handler) parseWritablePath(w http.ResponseWriter, r *http.Request) (path.ImmutablePath, bool) {
+	contentPath, err := path.NewPath(r.URL.Path)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return path.ImmutablePath{}, false
+	}
+
+	if contentPath.Namespace() != path.IPFSNamespace {
+		i.webError(w, r, fmt.Errorf("only %s paths can be modified", ipfsPathPrefix), http.StatusBadRequest)
+		return path.ImmutablePath{}, false
+	}
+
+	imPath, err := path.NewImmutablePath(contentPath)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return path.ImmutablePath{}, false
+	}
+
+	if i.handleDenylist(w, r, imPath) {
+		return path.ImmutablePath{}, false
+	}
+
+	if len(imPath.Segments()) < 3 {
+		i.webError(w, r, errors.New("path must have at least one segment after the root CID"), http.StatusBadRequest)
+		return path.ImmutablePath{}, false
+	}
+
+	return imPath, true
+}
+
+// writeCreated finishes a successful write request, pointing the client at the
+// newly created content.
+func (i *handler) writeCreated(w http.ResponseWriter, r *http.Request, newPath string) {
+	addCustomHeaders(w, i.config.Headers)
+	w.Header().Set("X-Ipfs-Path", newPath)
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// parseRequestContentType returns the media type of the request body. It
+// defaults to application/octet-stream if the Content-Type header is missing.
+func parseRequestContentType(r *http.Request) (string, map[string]string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "application/octet-stream", nil, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid Content-Type header: %w", err)
+	}
+	return mediaType, params, nil
+}
+
+// requestBodyToNode converts the request body into a UnixFS node: multipart
+// bodies become directories and any other body becomes a single file.
+func requestBodyToNode(r *http.Request, mediaType string, params map[string]string) (files.Node, error) {
+	if mediaType != "multipart/form-data" {
+		return files.NewReaderFile(r.Body), nil
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart body is missing a boundary")
+	}
+
+	return files.NewFileFromPartReader(multipart.NewReader(r.Body, boundary), mediaType)
+}
@@ -0,0 +1,188 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWritableBlocksBackend(t *testing.T) *BlocksBackend {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	backend, err := NewBlocksBackend(blockservice.New(bs, offline.Exchange(bs)))
+	require.NoError(t, err)
+	return backend
+}
+
+// mustNewWriteRequest is like mustNewRequest, but honours method.
+func mustNewWriteRequest(t *testing.T, method string, url string, body io.Reader) *http.Request {
+	r, err := http.NewRequest(method, url, body)
+	require.NoError(t, err)
+	return r
+}
+
+func mustReadBody(t *testing.T, res *http.Response) string {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestWritableGateway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := newWritableBlocksBackend(t)
+	ts := newTestServerWithConfig(t, backend, Config{
+		Headers:               map[string][]string{},
+		Writable:              true,
+		DeserializedResponses: true,
+	})
+
+	root, err := backend.AddFile(ctx, files.NewMapDirectory(map[string]files.Node{
+		"a.txt": files.NewBytesFile([]byte("hello a")),
+	}))
+	require.NoError(t, err)
+
+	t.Run("POST adds a raw file", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodPost, ts.URL+"/ipfs/", strings.NewReader("posted")))
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		location := res.Header.Get("Location")
+		assert.True(t, strings.HasPrefix(location, "/ipfs/"))
+		assert.Equal(t, location, res.Header.Get("X-Ipfs-Path"))
+
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+location, nil))
+		assert.Equal(t, "posted", mustReadBody(t, res))
+	})
+
+	t.Run("POST adds a multipart directory", func(t *testing.T) {
+		dir := files.NewMapDirectory(map[string]files.Node{
+			"b.txt": files.NewBytesFile([]byte("hello b")),
+		})
+		mfr := files.NewMultiFileReader(dir, true, false)
+
+		req := mustNewWriteRequest(t, http.MethodPost, ts.URL+"/ipfs/", mfr)
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+mfr.Boundary())
+		res := mustDo(t, req)
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+res.Header.Get("Location")+"/b.txt", nil))
+		assert.Equal(t, "hello b", mustReadBody(t, res))
+	})
+
+	t.Run("POST adds a CAR", func(t *testing.T) {
+		car, err := os.ReadFile(filepath.Join("./testdata", "fixtures.car"))
+		require.NoError(t, err)
+
+		req := mustNewWriteRequest(t, http.MethodPost, ts.URL+"/ipfs/", bytes.NewReader(car))
+		req.Header.Set("Content-Type", carResponseFormat)
+		res := mustDo(t, req)
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		_, fixtureRoot := newMockBackend(t, "fixtures.car")
+		assert.Equal(t, path.FromCid(fixtureRoot).String(), res.Header.Get("Location"))
+	})
+
+	t.Run("POST is only allowed on /ipfs/", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodPost, ts.URL+root.String(), strings.NewReader("posted")))
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("PUT writes a file and creates missing directories", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodPut, ts.URL+root.String()+"/sub/c.txt", strings.NewReader("hello c")))
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		location := res.Header.Get("Location")
+		assert.True(t, strings.HasSuffix(location, "/sub/c.txt"))
+		assert.NotEqual(t, root.String()+"/sub/c.txt", location)
+
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+location, nil))
+		assert.Equal(t, "hello c", mustReadBody(t, res))
+
+		newRoot := strings.TrimSuffix(location, "/sub/c.txt")
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+newRoot+"/a.txt", nil))
+		assert.Equal(t, "hello a", mustReadBody(t, res))
+	})
+
+	t.Run("PUT overwrites an existing file", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodPut, ts.URL+root.String()+"/a.txt", strings.NewReader("bye")))
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+res.Header.Get("Location"), nil))
+		assert.Equal(t, "bye", mustReadBody(t, res))
+	})
+
+	t.Run("PUT rejects mutable paths", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodPut, ts.URL+"/ipns/example.com/a.txt", strings.NewReader("nope")))
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("DELETE removes an entry", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodDelete, ts.URL+root.String()+"/a.txt", nil))
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		location := res.Header.Get("Location")
+		assert.NotEqual(t, root.String(), location)
+
+		res = mustDo(t, mustNewWriteRequest(t, http.MethodGet, ts.URL+location+"/a.txt", nil))
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("DELETE of a missing entry returns 404", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodDelete, ts.URL+root.String()+"/missing.txt", nil))
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		missing, err := path.Join(root, "missing.txt")
+		require.NoError(t, err)
+		imMissing, err := path.NewImmutablePath(missing)
+		require.NoError(t, err)
+		_, err = backend.Delete(ctx, imMissing)
+		var gwErr *ErrorStatusCode
+		require.ErrorAs(t, err, &gwErr)
+		assert.Equal(t, http.StatusNotFound, gwErr.StatusCode)
+	})
+
+	t.Run("OPTIONS lists write methods", func(t *testing.T) {
+		res := mustDo(t, mustNewWriteRequest(t, http.MethodOptions, ts.URL+root.String(), nil))
+		res.Body.Close()
+		assert.ElementsMatch(t, []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost, http.MethodPut, http.MethodDelete}, res.Header.Values("Allow"))
+	})
+}
+
+func TestWritableGatewayDisabled(t *testing.T) {
+	backend := newWritableBlocksBackend(t)
+	ts := newTestServerWithConfig(t, backend, Config{
+		Headers: map[string][]string{},
+	})
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		res := mustDo(t, mustNewWriteRequest(t, method, ts.URL+"/ipfs/", strings.NewReader("data")))
+		res.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode, method)
+	}
+}
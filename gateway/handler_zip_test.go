@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZIP(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The fixtures include non-UnixFS entries, which cannot be archived.
+	backend := newWritableBlocksBackend(t)
+	ts := newTestServer(t, backend)
+	root, err := backend.AddFile(ctx, files.NewMapDirectory(map[string]files.Node{
+		"subdir": files.NewMapDirectory(map[string]files.Node{
+			"fnord": files.NewBytesFile([]byte("fnord")),
+		}),
+	}))
+	require.NoError(t, err)
+
+	p, err := path.Join(root, "subdir")
+	require.NoError(t, err)
+	imPath, err := path.NewImmutablePath(p)
+	require.NoError(t, err)
+	md, err := backend.ResolvePath(ctx, imPath)
+	require.NoError(t, err)
+	subdirCid := md.LastSegment.RootCid().String()
+
+	readZip := func(t *testing.T, res *http.Response) map[string]string {
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+
+		entries := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+			entries[f.Name] = string(data)
+		}
+		return entries
+	}
+
+	for _, test := range []struct {
+		name   string
+		format string
+		accept string
+	}{
+		{"format query parameter", "zip", ""},
+		{"Accept header", "", zipResponseFormat},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			url := ts.URL + root.String() + "/subdir"
+			if test.format != "" {
+				url += "?format=" + test.format
+			}
+			req := mustNewRequest(t, http.MethodGet, url, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			res := mustDoWithoutRedirect(t, req)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, zipResponseFormat, res.Header.Get("Content-Type"))
+			assert.Equal(t, `attachment; filename="`+subdirCid+`.zip"; filename*=UTF-8''`+subdirCid+`.zip`, res.Header.Get("Content-Disposition"))
+
+			entries := readZip(t, res)
+			assert.Contains(t, entries, subdirCid+"/")
+			assert.Equal(t, "fnord", entries[subdirCid+"/fnord"])
+		})
+	}
+
+	t.Run("Custom filename", func(t *testing.T) {
+		res := mustDoWithoutRedirect(t, mustNewRequest(t, http.MethodGet, ts.URL+root.String()+"/subdir?format=zip&filename=my-files.zip", nil))
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `attachment; filename="my-files.zip"; filename*=UTF-8''my-files.zip`, res.Header.Get("Content-Disposition"))
+	})
+}
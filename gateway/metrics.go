@@ -51,13 +51,17 @@ func newIPFSBackendWithMetrics(backend IPFSBackend) *ipfsBackendWithMetrics {
 	return &ipfsBackendWithMetrics{backend, backendCallMetric}
 }
 
-func (b *ipfsBackendWithMetrics) updateBackendCallMetric(name string, err error, begin time.Time) {
-	end := time.Since(begin).Seconds()
+func (b *ipfsBackendWithMetrics) updateBackendCallMetric(ctx context.Context, name string, err error, begin time.Time) {
+	duration := time.Since(begin)
+	end := duration.Seconds()
 	if err == nil {
 		b.backendCallMetric.WithLabelValues(name, "success").Observe(end)
 	} else {
 		b.backendCallMetric.WithLabelValues(name, "failure").Observe(end)
 	}
+
+	// Also record the call in the summary of the request, if any.
+	requestSummaryFromContext(ctx).addBackendCall(name, duration, err)
 }
 
 func (b *ipfsBackendWithMetrics) Get(ctx context.Context, path path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
@@ -68,7 +72,7 @@ func (b *ipfsBackendWithMetrics) Get(ctx context.Context, path path.ImmutablePat
 
 	md, f, err := b.backend.Get(ctx, path, ranges...)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, f, err
 }
 
@@ -80,7 +84,7 @@ func (b *ipfsBackendWithMetrics) GetAll(ctx context.Context, path path.Immutable
 
 	md, n, err := b.backend.GetAll(ctx, path)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, n, err
 }
 
@@ -92,7 +96,7 @@ func (b *ipfsBackendWithMetrics) GetBlock(ctx context.Context, path path.Immutab
 
 	md, n, err := b.backend.GetBlock(ctx, path)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, n, err
 }
 
@@ -104,7 +108,7 @@ func (b *ipfsBackendWithMetrics) Head(ctx context.Context, path path.ImmutablePa
 
 	md, n, err := b.backend.Head(ctx, path)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, n, err
 }
 
@@ -116,7 +120,7 @@ func (b *ipfsBackendWithMetrics) ResolvePath(ctx context.Context, path path.Immu
 
 	md, err := b.backend.ResolvePath(ctx, path)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, err
 }
 
@@ -127,7 +131,7 @@ func (b *ipfsBackendWithMetrics) GetCAR(ctx context.Context, path path.Immutable
 	defer span.End()
 
 	md, rc, err := b.backend.GetCAR(ctx, path, params)
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return md, rc, err
 }
 
@@ -139,7 +143,7 @@ func (b *ipfsBackendWithMetrics) IsCached(ctx context.Context, path path.Path) b
 
 	bln := b.backend.IsCached(ctx, path)
 
-	b.updateBackendCallMetric(name, nil, begin)
+	b.updateBackendCallMetric(ctx, name, nil, begin)
 	return bln
 }
 
@@ -151,7 +155,7 @@ func (b *ipfsBackendWithMetrics) GetIPNSRecord(ctx context.Context, cid cid.Cid)
 
 	r, err := b.backend.GetIPNSRecord(ctx, cid)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return r, err
 }
 
@@ -163,7 +167,7 @@ func (b *ipfsBackendWithMetrics) ResolveMutable(ctx context.Context, path path.P
 
 	p, err := b.backend.ResolveMutable(ctx, path)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return p, err
 }
 
@@ -175,22 +179,121 @@ func (b *ipfsBackendWithMetrics) GetDNSLinkRecord(ctx context.Context, fqdn stri
 
 	p, err := b.backend.GetDNSLinkRecord(ctx, fqdn)
 
-	b.updateBackendCallMetric(name, err, begin)
+	b.updateBackendCallMetric(ctx, name, err, begin)
 	return p, err
 }
 
 var _ IPFSBackend = (*ipfsBackendWithMetrics)(nil)
 
+type writableIPFSBackendWithMetrics struct {
+	*ipfsBackendWithMetrics
+	writable WritableIPFSBackend
+}
+
+func newWritableIPFSBackendWithMetrics(b *ipfsBackendWithMetrics, writable WritableIPFSBackend) *writableIPFSBackendWithMetrics {
+	return &writableIPFSBackendWithMetrics{b, writable}
+}
+
+func (b *writableIPFSBackendWithMetrics) AddFile(ctx context.Context, node files.Node) (path.ImmutablePath, error) {
+	begin := time.Now()
+	name := "IPFSBackend.AddFile"
+	ctx, span := spanTrace(ctx, name)
+	defer span.End()
+
+	p, err := b.writable.AddFile(ctx, node)
+
+	b.updateBackendCallMetric(ctx, name, err, begin)
+	return p, err
+}
+
+func (b *writableIPFSBackendWithMetrics) AddCAR(ctx context.Context, r io.Reader) (path.ImmutablePath, error) {
+	begin := time.Now()
+	name := "IPFSBackend.AddCAR"
+	ctx, span := spanTrace(ctx, name)
+	defer span.End()
+
+	p, err := b.writable.AddCAR(ctx, r)
+
+	b.updateBackendCallMetric(ctx, name, err, begin)
+	return p, err
+}
+
+func (b *writableIPFSBackendWithMetrics) Put(ctx context.Context, path path.ImmutablePath, node files.Node) (path.ImmutablePath, error) {
+	begin := time.Now()
+	name := "IPFSBackend.Put"
+	ctx, span := spanTrace(ctx, name, trace.WithAttributes(attribute.String("path", path.String())))
+	defer span.End()
+
+	p, err := b.writable.Put(ctx, path, node)
+
+	b.updateBackendCallMetric(ctx, name, err, begin)
+	return p, err
+}
+
+func (b *writableIPFSBackendWithMetrics) Delete(ctx context.Context, path path.ImmutablePath) (path.ImmutablePath, error) {
+	begin := time.Now()
+	name := "IPFSBackend.Delete"
+	ctx, span := spanTrace(ctx, name, trace.WithAttributes(attribute.String("path", path.String())))
+	defer span.End()
+
+	p, err := b.writable.Delete(ctx, path)
+
+	b.updateBackendCallMetric(ctx, name, err, begin)
+	return p, err
+}
+
+var _ WritableIPFSBackend = (*writableIPFSBackendWithMetrics)(nil)
+
+type ipnsPublishingBackendWithMetrics struct {
+	*ipfsBackendWithMetrics
+	publishing IPNSPublishingBackend
+}
+
+func (b *ipnsPublishingBackendWithMetrics) PutIPNSRecord(ctx context.Context, c cid.Cid, record []byte) error {
+	begin := time.Now()
+	name := "IPFSBackend.PutIPNSRecord"
+	ctx, span := spanTrace(ctx, name, trace.WithAttributes(attribute.String("cid", c.String())))
+	defer span.End()
+
+	err := b.publishing.PutIPNSRecord(ctx, c, record)
+
+	b.updateBackendCallMetric(ctx, name, err, begin)
+	return err
+}
+
+var _ IPNSPublishingBackend = (*ipnsPublishingBackendWithMetrics)(nil)
+
 func newHandlerWithMetrics(c *Config, backend IPFSBackend) *handler {
+	backendWithMetrics := newIPFSBackendWithMetrics(backend)
+
+	var writableBackend WritableIPFSBackend
+	if wb, ok := backend.(WritableIPFSBackend); ok {
+		writableBackend = newWritableIPFSBackendWithMetrics(backendWithMetrics, wb)
+	} else if c.Writable {
+		log.Warnf("gateway is configured as writable, but %T does not implement WritableIPFSBackend", backend)
+	}
+
+	var ipnsPublishingBackend IPNSPublishingBackend
+	if pb, ok := backend.(IPNSPublishingBackend); ok {
+		ipnsPublishingBackend = &ipnsPublishingBackendWithMetrics{backendWithMetrics, pb}
+	} else if c.IPNSRecordPublishing {
+		log.Warnf("gateway is configured to publish IPNS records, but %T does not implement IPNSPublishingBackend", backend)
+	}
+
 	i := &handler{
-		config:  c,
-		backend: newIPFSBackendWithMetrics(backend),
+		config:                c,
+		backend:               backendWithMetrics,
+		writableBackend:       writableBackend,
+		ipnsPublishingBackend: ipnsPublishingBackend,
+		headersCache:          newHeadersCache(),
+		precompressedCache:    newPrecompressedCache(),
 
 		// Response-type specific metrics
 		// ----------------------------
-		requestTypeMetric: newRequestTypeMetric(
+		requestTypeMetric: newCounterMetric(
 			"gw_request_types",
 			"The number of requests per implicit or explicit request type.",
+			"gateway", "type",
 		),
 		// Generic: time it takes to execute a successful gateway request (all request types)
 		getMetric: newHistogramMetric(
@@ -236,6 +339,16 @@ func newHandlerWithMetrics(c *Config, backend IPFSBackend) *handler {
 			"gw_tar_stream_fail_duration_seconds",
 			"How long a TAR was streamed before failing mid-stream.",
 		),
+		// ZIP: time it takes to return requested ZIP stream
+		zipStreamGetMetric: newHistogramMetric(
+			"gw_zip_stream_get_duration_seconds",
+			"The time to GET an entire ZIP stream from the gateway.",
+		),
+		// ZIP: time it takes to return requested ZIP stream
+		zipStreamFailMetric: newHistogramMetric(
+			"gw_zip_stream_fail_duration_seconds",
+			"How long a ZIP was streamed before failing mid-stream.",
+		),
 		// JSON/CBOR: time it takes to return requested DAG-JSON/-CBOR document
 		jsoncborDocumentGetMetric: newHistogramMetric(
 			"gw_jsoncbor_get_duration_seconds",
@@ -250,9 +363,8 @@ func newHandlerWithMetrics(c *Config, backend IPFSBackend) *handler {
 	return i
 }
 
-func newRequestTypeMetric(name string, help string) *prometheus.CounterVec {
-	// We can add buckets as a parameter in the future, but for now using static defaults
-	// suggested in https://github.com/ipfs/kubo/issues/8441
+// newCounterMetric registers a counter partitioned by the given labels.
+func newCounterMetric(name string, help string, labels ...string) *prometheus.CounterVec {
 	metric := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "ipfs",
@@ -260,7 +372,7 @@ func newRequestTypeMetric(name string, help string) *prometheus.CounterVec {
 			Name:      name,
 			Help:      help,
 		},
-		[]string{"gateway", "type"},
+		labels,
 	)
 	if err := prometheus.Register(metric); err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -0,0 +1,449 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultRemoteMaxAttempts is the default number of attempts made to fetch a
+	// single block, across all the upstream gateways, before giving up.
+	DefaultRemoteMaxAttempts = 3
+
+	// DefaultRemoteMaxConcurrency is the default number of blocks that are fetched
+	// in parallel when calling [RemoteExchange.GetBlocks].
+	DefaultRemoteMaxConcurrency = 16
+
+	// DefaultRemoteMaxBlockSize is the default maximum size of a block that
+	// will be read from an upstream gateway. Bitswap and the Trustless Gateway
+	// specification put the limit at 2MiB, so we do the same.
+	DefaultRemoteMaxBlockSize = 2 << 20
+
+	// DefaultRemoteRequestTimeout is the default timeout for a single block
+	// request to a single upstream gateway.
+	DefaultRemoteRequestTimeout = 30 * time.Second
+)
+
+// RemoteGateway is an upstream [Trustless Gateway] used by [RemoteExchange].
+//
+// [Trustless Gateway]: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+type RemoteGateway struct {
+	// URL is the base URL of the gateway, e.g. https://trustless-gateway.link.
+	URL string
+
+	// Weight is the relative weight of this gateway within the pool. Gateways
+	// with a higher weight are tried first more often. Weights lower than or
+	// equal to zero are treated as 1.
+	Weight int
+}
+
+type remoteExchangeOptions struct {
+	httpClient     *http.Client
+	cache          blockstore.Blockstore
+	maxAttempts    int
+	maxConcurrency int
+	maxBlockSize   int64
+	requestTimeout time.Duration
+}
+
+// RemoteExchangeOption is an option for [NewRemoteExchange].
+type RemoteExchangeOption func(options *remoteExchangeOptions) error
+
+// WithRemoteHTTPClient sets the [http.Client] used to make requests to the
+// upstream gateways. By default, a client with no overall timeout is used, and
+// each request is bounded by [WithRemoteRequestTimeout] instead.
+func WithRemoteHTTPClient(client *http.Client) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		if client == nil {
+			return errors.New("http client cannot be nil")
+		}
+		opts.httpClient = client
+		return nil
+	}
+}
+
+// WithRemoteCacheBlockstore sets a local [blockstore.Blockstore] which is
+// checked before making any remote requests, and where every verified block
+// fetched from an upstream gateway is stored.
+func WithRemoteCacheBlockstore(bs blockstore.Blockstore) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		opts.cache = bs
+		return nil
+	}
+}
+
+// WithRemoteMaxAttempts sets the maximum number of attempts made to fetch a
+// single block. Each attempt goes to a different upstream gateway, if available,
+// picked by weight. Defaults to [DefaultRemoteMaxAttempts].
+func WithRemoteMaxAttempts(n int) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		if n < 1 {
+			return fmt.Errorf("max attempts must be at least 1, got %d", n)
+		}
+		opts.maxAttempts = n
+		return nil
+	}
+}
+
+// WithRemoteMaxConcurrency sets the maximum number of blocks fetched in
+// parallel by [RemoteExchange.GetBlocks]. Defaults to [DefaultRemoteMaxConcurrency].
+func WithRemoteMaxConcurrency(n int) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		if n < 1 {
+			return fmt.Errorf("max concurrency must be at least 1, got %d", n)
+		}
+		opts.maxConcurrency = n
+		return nil
+	}
+}
+
+// WithRemoteMaxBlockSize sets the maximum size of a block read from an
+// upstream gateway. Larger responses are discarded. Defaults to [DefaultRemoteMaxBlockSize].
+func WithRemoteMaxBlockSize(size int64) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		if size < 1 {
+			return fmt.Errorf("max block size must be positive, got %d", size)
+		}
+		opts.maxBlockSize = size
+		return nil
+	}
+}
+
+// WithRemoteRequestTimeout sets the timeout of a single request to a single
+// upstream gateway. Defaults to [DefaultRemoteRequestTimeout].
+func WithRemoteRequestTimeout(timeout time.Duration) RemoteExchangeOption {
+	return func(opts *remoteExchangeOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("request timeout must be positive, got %s", timeout)
+		}
+		opts.requestTimeout = timeout
+		return nil
+	}
+}
+
+// RemoteExchange is an [exchange.Interface] that fetches blocks from a weighted
+// pool of upstream [Trustless Gateway]s using [application/vnd.ipld.raw]
+// responses. Every block is verified against its CID before being returned, and
+// failed requests are retried against other upstreams.
+//
+// Together with [NewRemoteBlocksBackend], it can be used to run a gateway that
+// has no local repository and sources all its data over HTTP.
+//
+// [Trustless Gateway]: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+// [application/vnd.ipld.raw]: https://www.iana.org/assignments/media-types/application/vnd.ipld.raw
+type RemoteExchange struct {
+	gateways       []RemoteGateway
+	httpClient     *http.Client
+	cache          blockstore.Blockstore
+	maxAttempts    int
+	maxConcurrency int
+	maxBlockSize   int64
+	requestTimeout time.Duration
+
+	randLk sync.Mutex
+	rand   *rand.Rand
+}
+
+var _ exchange.Interface = (*RemoteExchange)(nil)
+
+// NewRemoteExchange creates a new [RemoteExchange] backed by the given gateways.
+func NewRemoteExchange(gateways []RemoteGateway, opts ...RemoteExchangeOption) (*RemoteExchange, error) {
+	if len(gateways) == 0 {
+		return nil, errors.New("at least one remote gateway must be provided")
+	}
+
+	compiledOptions := remoteExchangeOptions{
+		maxAttempts:    DefaultRemoteMaxAttempts,
+		maxConcurrency: DefaultRemoteMaxConcurrency,
+		maxBlockSize:   DefaultRemoteMaxBlockSize,
+		requestTimeout: DefaultRemoteRequestTimeout,
+	}
+	for _, o := range opts {
+		if err := o(&compiledOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	gws := make([]RemoteGateway, len(gateways))
+	for i, gw := range gateways {
+		u, err := url.Parse(gw.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote gateway URL %q: %w", gw.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid remote gateway URL %q: scheme must be http or https", gw.URL)
+		}
+		if gw.Weight <= 0 {
+			gw.Weight = 1
+		}
+		gw.URL = strings.TrimRight(gw.URL, "/")
+		gws[i] = gw
+	}
+
+	httpClient := compiledOptions.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	return &RemoteExchange{
+		gateways:       gws,
+		httpClient:     httpClient,
+		cache:          compiledOptions.cache,
+		maxAttempts:    compiledOptions.maxAttempts,
+		maxConcurrency: compiledOptions.maxConcurrency,
+		maxBlockSize:   compiledOptions.maxBlockSize,
+		requestTimeout: compiledOptions.requestTimeout,
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// NewRemoteBlocksBackend creates a new [BlocksBackend] that retrieves all of its
+// blocks through the given [RemoteExchange]. If the exchange has a cache
+// blockstore, it is used as the backend's blockstore instead, such that
+// [BlocksBackend.IsCached] reflects its contents and each block is only looked
+// up and stored once. Otherwise, nothing is stored locally.
+func NewRemoteBlocksBackend(exch *RemoteExchange, opts ...BlocksBackendOption) (*BlocksBackend, error) {
+	if exch.cache == nil {
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewNullDatastore()))
+		return NewBlocksBackend(blockservice.New(bs, exch), opts...)
+	}
+
+	// The blockservice already reads and writes the cache.
+	return NewBlocksBackend(blockservice.New(exch.cache, uncachedRemoteExchange{exch}), opts...)
+}
+
+// uncachedRemoteExchange is a [RemoteExchange] that bypasses its cache, for a
+// blockservice that uses the cache as its blockstore.
+type uncachedRemoteExchange struct {
+	*RemoteExchange
+}
+
+func (e uncachedRemoteExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, span := spanTrace(ctx, "RemoteExchange.GetBlock", trace.WithAttributes(attribute.Stringer("cid", c)))
+	defer span.End()
+
+	return e.fetch(ctx, c)
+}
+
+func (e uncachedRemoteExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return e.getBlocks(ctx, cids, e.GetBlock), nil
+}
+
+// GetBlock fetches the block with the given CID from the cache, if present, or
+// from the upstream gateways.
+func (e *RemoteExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, span := spanTrace(ctx, "RemoteExchange.GetBlock", trace.WithAttributes(attribute.Stringer("cid", c)))
+	defer span.End()
+
+	if e.cache != nil {
+		blk, err := e.cache.Get(ctx, c)
+		if err == nil {
+			return blk, nil
+		}
+		if !ipld.IsNotFound(err) {
+			log.Debugw("failed to read block from remote exchange cache", "cid", c, "error", err)
+		}
+	}
+
+	blk, err := e.fetch(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.cache != nil {
+		if err := e.cache.Put(ctx, blk); err != nil {
+			log.Debugw("failed to write block to remote exchange cache", "cid", c, "error", err)
+		}
+	}
+
+	return blk, nil
+}
+
+// GetBlocks fetches the blocks with the given CIDs, in parallel, returning them
+// as they arrive. Blocks that cannot be retrieved from any of the upstream
+// gateways are skipped, as allowed by [exchange.Fetcher].
+func (e *RemoteExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return e.getBlocks(ctx, cids, e.GetBlock), nil
+}
+
+// getBlocks fetches the blocks with get, in parallel.
+func (e *RemoteExchange) getBlocks(ctx context.Context, cids []cid.Cid, get func(context.Context, cid.Cid) (blocks.Block, error)) <-chan blocks.Block {
+	out := make(chan blocks.Block)
+
+	go func() {
+		defer close(out)
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, e.maxConcurrency)
+		seen := make(map[cid.Cid]struct{}, len(cids))
+
+	loop:
+		for _, c := range cids {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+
+			wg.Add(1)
+			go func(c cid.Cid) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				blk, err := get(ctx, c)
+				if err != nil {
+					log.Debugw("failed to fetch block from remote gateways", "cid", c, "error", err)
+					return
+				}
+
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+				}
+			}(c)
+		}
+
+		wg.Wait()
+	}()
+
+	return out
+}
+
+// NotifyNewBlocks is a no-op: upstream gateways cannot be notified of blocks
+// that became available locally.
+func (e *RemoteExchange) NotifyNewBlocks(ctx context.Context, blocks ...blocks.Block) error {
+	return nil
+}
+
+// Close closes idle connections to the upstream gateways.
+func (e *RemoteExchange) Close() error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// fetch retrieves the block from the upstream gateways, trying up to
+// maxAttempts of them, ordered by weighted random selection.
+func (e *RemoteExchange) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	order := e.weightedOrder()
+
+	var errs []error
+	for attempt := 0; attempt < e.maxAttempts; attempt++ {
+		gw := order[attempt%len(order)]
+
+		blk, err := e.fetchFrom(ctx, gw, c)
+		if err == nil {
+			return blk, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		log.Debugw("failed to fetch block from remote gateway", "gateway", gw.URL, "cid", c, "attempt", attempt+1, "error", err)
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("could not fetch block %s from remote gateways: %w", c, errors.Join(errs...))
+}
+
+// fetchFrom retrieves and verifies a single block from the given gateway.
+func (e *RemoteExchange) fetchFrom(ctx context.Context, gw RemoteGateway, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.requestTimeout)
+	defer cancel()
+
+	urlStr := fmt.Sprintf("%s/ipfs/%s?format=raw", gw.URL, c)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", rawResponseFormat)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from remote gateway %s: %s", gw.URL, resp.Status)
+	}
+
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, e.maxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(rb)) > e.maxBlockSize {
+		return nil, fmt.Errorf("block from remote gateway %s exceeds maximum size of %d bytes", gw.URL, e.maxBlockSize)
+	}
+
+	// Validate incoming blocks. This is important since we are proxying block
+	// requests to untrusted gateways.
+	nc, err := c.Prefix().Sum(rb)
+	if err != nil {
+		return nil, blocks.ErrWrongHash
+	}
+	if !nc.Equals(c) {
+		return nil, blocks.ErrWrongHash
+	}
+
+	return blocks.NewBlockWithCid(rb, c)
+}
+
+// weightedOrder returns the gateways in a weighted random order, such that the
+// gateways with higher weights are more likely to show up first. It uses the
+// Efraimidis-Spirakis algorithm: each gateway gets a key u^(1/w), where u is
+// uniformly random in (0, 1), and gateways are sorted by descending key.
+func (e *RemoteExchange) weightedOrder() []RemoteGateway {
+	if len(e.gateways) == 1 {
+		return e.gateways
+	}
+
+	type keyed struct {
+		gw  RemoteGateway
+		key float64
+	}
+
+	ks := make([]keyed, len(e.gateways))
+	e.randLk.Lock()
+	for i, gw := range e.gateways {
+		ks[i] = keyed{gw: gw, key: math.Pow(e.rand.Float64(), 1/float64(gw.Weight))}
+	}
+	e.randLk.Unlock()
+
+	sort.Slice(ks, func(i, j int) bool {
+		return ks[i].key > ks[j].key
+	})
+
+	order := make([]RemoteGateway, len(ks))
+	for i, k := range ks {
+		order[i] = k.gw
+	}
+	return order
+}
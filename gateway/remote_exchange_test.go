@@ -0,0 +1,194 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustNewRawBlock(t *testing.T, data string) blocks.Block {
+	c, err := cid.NewPrefixV1(cid.Raw, multihash.SHA2_256).Sum([]byte(data))
+	require.NoError(t, err)
+	blk, err := blocks.NewBlockWithCid([]byte(data), c)
+	require.NoError(t, err)
+	return blk
+}
+
+func newRemoteBlockServer(t *testing.T, blks map[cid.Cid]blocks.Block, requests *int32) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			atomic.AddInt32(requests, 1)
+		}
+		c, err := cid.Decode(r.URL.Path[len("/ipfs/"):])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		blk, ok := blks[c]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", rawResponseFormat)
+		_, _ = w.Write(blk.RawData())
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRemoteExchange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hello := mustNewRawBlock(t, "hello world")
+	foo := mustNewRawBlock(t, "foo")
+	blks := map[cid.Cid]blocks.Block{hello.Cid(): hello, foo.Cid(): foo}
+
+	t.Run("Verifies blocks against their CID", func(t *testing.T) {
+		bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("wrong data"))
+		}))
+		t.Cleanup(bad.Close)
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: bad.URL}})
+		require.NoError(t, err)
+
+		_, err = exch.GetBlock(ctx, hello.Cid())
+		assert.ErrorIs(t, err, blocks.ErrWrongHash)
+	})
+
+	t.Run("Fails over to other upstreams", func(t *testing.T) {
+		var badRequests int32
+		bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&badRequests, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		t.Cleanup(bad.Close)
+		good := newRemoteBlockServer(t, blks, nil)
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: bad.URL, Weight: 1000}, {URL: good.URL, Weight: 1}}, WithRemoteMaxAttempts(2))
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			blk, err := exch.GetBlock(ctx, hello.Cid())
+			require.NoError(t, err)
+			assert.Equal(t, hello.RawData(), blk.RawData())
+		}
+		assert.NotZero(t, atomic.LoadInt32(&badRequests))
+	})
+
+	t.Run("Stores fetched blocks in cache", func(t *testing.T) {
+		var requests int32
+		good := newRemoteBlockServer(t, blks, &requests)
+		cache := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: good.URL}}, WithRemoteCacheBlockstore(cache))
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := exch.GetBlock(ctx, foo.Cid())
+			require.NoError(t, err)
+		}
+		assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+		has, err := cache.Has(ctx, foo.Cid())
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+
+	t.Run("GetBlocks returns all available blocks", func(t *testing.T) {
+		good := newRemoteBlockServer(t, blks, nil)
+		missing := mustNewRawBlock(t, "missing")
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: good.URL}}, WithRemoteMaxConcurrency(2))
+		require.NoError(t, err)
+
+		ch, err := exch.GetBlocks(ctx, []cid.Cid{hello.Cid(), foo.Cid(), missing.Cid(), hello.Cid()})
+		require.NoError(t, err)
+
+		got := map[cid.Cid]bool{}
+		for blk := range ch {
+			got[blk.Cid()] = true
+		}
+		assert.Equal(t, map[cid.Cid]bool{hello.Cid(): true, foo.Cid(): true}, got)
+	})
+
+	t.Run("Serves a gateway with NewRemoteBlocksBackend", func(t *testing.T) {
+		good := newRemoteBlockServer(t, blks, nil)
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: good.URL}})
+		require.NoError(t, err)
+
+		backend, err := NewRemoteBlocksBackend(exch)
+		require.NoError(t, err)
+
+		ts := newTestServer(t, backend)
+		res := mustDo(t, mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+hello.Cid().String(), nil))
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "hello world", string(body))
+	})
+
+	t.Run("NewRemoteBlocksBackend stores fetched blocks once", func(t *testing.T) {
+		good := newRemoteBlockServer(t, blks, nil)
+		cache := &countingBlockstore{Blockstore: blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))}
+
+		exch, err := NewRemoteExchange([]RemoteGateway{{URL: good.URL}}, WithRemoteCacheBlockstore(cache))
+		require.NoError(t, err)
+
+		backend, err := NewRemoteBlocksBackend(exch)
+		require.NoError(t, err)
+
+		ts := newTestServer(t, backend)
+		res := mustDo(t, mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+hello.Cid().String(), nil))
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&cache.puts))
+		has, err := cache.Has(ctx, hello.Cid())
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+}
+
+// countingBlockstore counts the blocks written to it.
+type countingBlockstore struct {
+	blockstore.Blockstore
+	puts int32
+}
+
+func (bs *countingBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	atomic.AddInt32(&bs.puts, 1)
+	return bs.Blockstore.Put(ctx, blk)
+}
+
+func (bs *countingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	atomic.AddInt32(&bs.puts, int32(len(blks)))
+	return bs.Blockstore.PutMany(ctx, blks)
+}
+
+func TestNewRemoteExchangeValidation(t *testing.T) {
+	_, err := NewRemoteExchange(nil)
+	assert.Error(t, err)
+
+	_, err = NewRemoteExchange([]RemoteGateway{{URL: "ftp://example.com"}})
+	assert.Error(t, err)
+
+	_, err = NewRemoteExchange([]RemoteGateway{{URL: "https://example.com"}}, WithRemoteMaxAttempts(0))
+	assert.Error(t, err)
+}
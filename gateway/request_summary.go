@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+)
+
+// requestSummaryKey is the key for the *requestSummary of a request.
+const requestSummaryKey RequestContextKey = "request-summary"
+
+// RequestSummary is a summary of a request handled by the gateway, passed to
+// [Config.OnRequestSummary].
+type RequestSummary struct {
+	Method string
+	URL    string
+
+	// ContentPath is the requested content path. It is empty for requests that
+	// did not reach content resolution, such as redirects.
+	ContentPath string
+
+	// RequestType is the explicit response format of the request, such as
+	// "application/vnd.ipld.car". It is empty for implicit (web) requests.
+	RequestType string
+
+	// ResolvedPath is the immutable path the content path resolved to.
+	ResolvedPath string
+
+	StatusCode   int
+	BytesSent    int64
+	Duration     time.Duration
+	BackendCalls []BackendCallSummary
+}
+
+// BackendCallSummary is the summary of an [IPFSBackend] call made while
+// handling a request.
+type BackendCallSummary struct {
+	// Name is the name of the called method, such as "IPFSBackend.Get".
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// requestSummary collects the summary of a request. Its methods are safe for
+// concurrent use, and are no-ops on a nil *requestSummary.
+type requestSummary struct {
+	mu      sync.Mutex
+	summary RequestSummary
+	rootCid cid.Cid
+}
+
+func newRequestSummary(r *http.Request) *requestSummary {
+	return &requestSummary{
+		summary: RequestSummary{
+			Method: r.Method,
+			URL:    r.URL.String(),
+		},
+	}
+}
+
+func requestSummaryFromContext(ctx context.Context) *requestSummary {
+	s, _ := ctx.Value(requestSummaryKey).(*requestSummary)
+	return s
+}
+
+func (s *requestSummary) setContentPath(p path.Path) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.summary.ContentPath = p.String()
+	s.mu.Unlock()
+}
+
+func (s *requestSummary) setRequestType(responseFormat string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.summary.RequestType = responseFormat
+	s.mu.Unlock()
+}
+
+func (s *requestSummary) setResolvedPath(p path.ImmutablePath) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.summary.ResolvedPath = p.String()
+	s.rootCid = p.RootCid()
+	s.mu.Unlock()
+}
+
+func (s *requestSummary) addBackendCall(name string, duration time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.summary.BackendCalls = append(s.summary.BackendCalls, BackendCallSummary{
+		Name:     name,
+		Duration: duration,
+		Err:      err,
+	})
+	s.mu.Unlock()
+}
+
+// serverTiming returns the value of the Server-Timing header for the backend
+// calls made so far, for example:
+//
+//	ResolveMutable;dur=12.40, Get;dur=103.25;desc="failed"
+func (s *requestSummary) serverTiming() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := make([]string, 0, len(s.summary.BackendCalls))
+	for _, call := range s.summary.BackendCalls {
+		metric := strings.TrimPrefix(call.Name, "IPFSBackend.") + ";dur=" + strconv.FormatFloat(float64(call.Duration)/float64(time.Millisecond), 'f', 2, 64)
+		if call.Err != nil {
+			metric += `;desc="failed"`
+		}
+		metrics = append(metrics, metric)
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// withRequestSummary prepares the request and the response writer in order to
+// collect the summary of the request, and to send the Server-Timing header if
+// enabled. The returned function must be called once the request is handled.
+func (i *handler) withRequestSummary(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	begin := time.Now()
+
+	// The summary may already have been set up by [NewAccessLogHandler].
+	s := requestSummaryFromContext(r.Context())
+	if s == nil {
+		s = newRequestSummary(r)
+		r = r.WithContext(context.WithValue(r.Context(), requestSummaryKey, s))
+	}
+
+	ew := &errRecordingResponseWriter{ResponseWriter: w}
+	if i.config.ServerTiming {
+		ew.beforeWriteHeader = func() {
+			if value := s.serverTiming(); value != "" {
+				w.Header().Set("Server-Timing", value)
+			}
+		}
+	}
+
+	return ew, r, func() {
+		if i.config.OnRequestSummary == nil {
+			return
+		}
+
+		s.mu.Lock()
+		summary := s.summary
+		summary.BackendCalls = append([]BackendCallSummary(nil), s.summary.BackendCalls...)
+		s.mu.Unlock()
+
+		summary.StatusCode = ew.code
+		if summary.StatusCode == 0 {
+			// Nothing was written, which net/http sends as 200 OK.
+			summary.StatusCode = http.StatusOK
+		}
+		summary.BytesSent = ew.bytes
+		summary.Duration = time.Since(begin)
+
+		i.config.OnRequestSummary(summary)
+	}
+}
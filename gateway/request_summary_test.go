@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSummary(t *testing.T) {
+	backend, root := newMockBackend(t, "fixtures.car")
+	backend.namesys["/ipns/example.net"] = path.FromCid(root)
+
+	summaries := make(chan RequestSummary, 1)
+	ts := newTestServerWithConfig(t, backend, Config{
+		DeserializedResponses: true,
+		ServerTiming:          true,
+		OnRequestSummary: func(s RequestSummary) {
+			summaries <- s
+		},
+	})
+
+	backendCallNames := func(s RequestSummary) []string {
+		var names []string
+		for _, call := range s.BackendCalls {
+			names = append(names, call.Name)
+		}
+		return names
+	}
+
+	t.Run("Immutable path", func(t *testing.T) {
+		contentPath := path.FromCid(root).String() + "/subdir/fnord"
+		res := mustDo(t, mustNewRequest(t, http.MethodGet, ts.URL+contentPath, nil))
+		assert.Equal(t, "fnord", mustReadBody(t, res))
+		assert.Regexp(t, `^Get;dur=\d+\.\d{2}$`, res.Header.Get("Server-Timing"))
+
+		s := <-summaries
+		assert.Equal(t, http.MethodGet, s.Method)
+		assert.Equal(t, contentPath, s.ContentPath)
+		assert.Equal(t, contentPath, s.ResolvedPath)
+		assert.Empty(t, s.RequestType)
+		assert.Equal(t, http.StatusOK, s.StatusCode)
+		assert.EqualValues(t, 5, s.BytesSent)
+		assert.Equal(t, []string{"IPFSBackend.Get"}, backendCallNames(s))
+		assert.Greater(t, s.Duration, s.BackendCalls[0].Duration)
+	})
+
+	t.Run("Mutable path", func(t *testing.T) {
+		req := mustNewRequest(t, http.MethodGet, ts.URL+"/ipns/example.net/subdir/fnord?format=raw", nil)
+		res := mustDo(t, req)
+		res.Body.Close()
+		assert.Regexp(t, `^ResolveMutable;dur=\d+\.\d{2}, GetBlock;dur=\d+\.\d{2}$`, res.Header.Get("Server-Timing"))
+
+		s := <-summaries
+		assert.Equal(t, "/ipns/example.net/subdir/fnord", s.ContentPath)
+		assert.Equal(t, path.FromCid(root).String()+"/subdir/fnord", s.ResolvedPath)
+		assert.Equal(t, rawResponseFormat, s.RequestType)
+		assert.Equal(t, []string{"IPFSBackend.ResolveMutable", "IPFSBackend.GetBlock"}, backendCallNames(s))
+	})
+
+	t.Run("Failed backend call", func(t *testing.T) {
+		res := mustDo(t, mustNewRequest(t, http.MethodGet, ts.URL+"/ipns/missing.example.net", nil))
+		res.Body.Close()
+		assert.Equal(t, `ResolveMutable;dur=`, res.Header.Get("Server-Timing")[:len(`ResolveMutable;dur=`)])
+		assert.Contains(t, res.Header.Get("Server-Timing"), `;desc="failed"`)
+
+		s := <-summaries
+		require.Len(t, s.BackendCalls, 1)
+		assert.Error(t, s.BackendCalls[0].Err)
+		assert.Equal(t, res.StatusCode, s.StatusCode)
+	})
+}
+
+func TestServerTimingDisabled(t *testing.T) {
+	backend, root := newMockBackend(t, "fixtures.car")
+	ts := newTestServer(t, backend)
+
+	res := mustDo(t, mustNewRequest(t, http.MethodGet, ts.URL+path.FromCid(root).String()+"/subdir/fnord", nil))
+	res.Body.Close()
+	assert.Empty(t, res.Header.Get("Server-Timing"))
+}
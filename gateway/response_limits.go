@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+)
+
+func responseLimitsFromContext(ctx context.Context) ResponseLimits {
+	limits, _ := ctx.Value(ResponseLimitsKey).(ResponseLimits)
+	return limits
+}
+
+// blockBudget counts the blocks, and their bytes, sent in a response. A nil
+// *blockBudget has no limits.
+type blockBudget struct {
+	maxBlocks int
+	maxBytes  int64
+
+	mu     sync.Mutex
+	blocks int
+	bytes  int64
+
+	// seen is only set when duplicate blocks are not sent, so that they are
+	// not counted either.
+	seen map[cid.Cid]struct{}
+}
+
+func newBlockBudget(limits ResponseLimits, duplicates bool) *blockBudget {
+	if limits.MaxBlocks == 0 && limits.MaxBytes == 0 {
+		return nil
+	}
+
+	b := &blockBudget{
+		maxBlocks: limits.MaxBlocks,
+		maxBytes:  limits.MaxBytes,
+	}
+	if !duplicates {
+		b.seen = make(map[cid.Cid]struct{})
+	}
+	return b
+}
+
+// spend accounts for a block that is about to be sent, and fails without
+// accounting for it if it would exceed the budget.
+func (b *blockBudget) spend(c cid.Cid, size int) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.seen != nil {
+		if _, ok := b.seen[c]; ok {
+			return nil
+		}
+	}
+
+	if b.maxBlocks > 0 && b.blocks+1 > b.maxBlocks {
+		return fmt.Errorf("%w: more than %d blocks", ErrResponseLimitExceeded, b.maxBlocks)
+	}
+	if b.maxBytes > 0 && b.bytes+int64(size) > b.maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrResponseLimitExceeded, b.maxBytes)
+	}
+
+	b.blocks++
+	b.bytes += int64(size)
+	if b.seen != nil {
+		b.seen[c] = struct{}{}
+	}
+	return nil
+}
+
+// depthLimitedOpener wraps opener in order to fail when loading a block that is
+// more than maxDepth links away from the root of a traversal. The depth of a
+// block is the depth of the closest block whose path is a prefix of the path of
+// its link, plus one, which relies on the traversal loading parents first.
+func depthLimitedOpener(opener ipld.BlockReadOpener, maxDepth int) ipld.BlockReadOpener {
+	var mu sync.Mutex
+	depths := map[string]int{"": 0}
+
+	return func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		mu.Lock()
+		depth := 1
+		for p := lctx.LinkPath.Pop(); ; p = p.Pop() {
+			if d, ok := depths[p.String()]; ok {
+				depth = d + 1
+				break
+			}
+			if p.Len() == 0 {
+				break
+			}
+		}
+		if depth <= maxDepth {
+			depths[lctx.LinkPath.String()] = depth
+		}
+		mu.Unlock()
+
+		if depth > maxDepth {
+			return nil, fmt.Errorf("%w: deeper than %d links", ErrResponseLimitExceeded, maxDepth)
+		}
+		return opener(lctx, lnk)
+	}
+}
+
+// archiveBudget enforces the [ResponseLimits] of TAR and ZIP responses on the
+// entries of a [files.Node], before they are written.
+type archiveBudget struct {
+	limits ResponseLimits
+	bytes  int64
+}
+
+// limitNode returns nd, wrapped in order to enforce the limits on its entries if
+// it is a directory. It fails if nd, at the given depth, exceeds the limits.
+func (b *archiveBudget) limitNode(nd files.Node, depth int) (files.Node, error) {
+	if b.limits.MaxDepth > 0 && depth > b.limits.MaxDepth {
+		return nil, fmt.Errorf("%w: entries nested deeper than %d", ErrResponseLimitExceeded, b.limits.MaxDepth)
+	}
+
+	switch nd := nd.(type) {
+	case *files.Symlink:
+		return nd, nil
+	case files.File:
+		if b.limits.MaxBytes == 0 {
+			return nd, nil
+		}
+		size, err := nd.Size()
+		if err != nil {
+			return nil, err
+		}
+		if b.bytes+size > b.limits.MaxBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseLimitExceeded, b.limits.MaxBytes)
+		}
+		b.bytes += size
+		return nd, nil
+	case files.Directory:
+		return &limitedDirectory{Directory: nd, budget: b, depth: depth}, nil
+	default:
+		return nd, nil
+	}
+}
+
+type limitedDirectory struct {
+	files.Directory
+	budget *archiveBudget
+	depth  int
+}
+
+func (d *limitedDirectory) Entries() files.DirIterator {
+	return &limitedDirIterator{DirIterator: d.Directory.Entries(), dir: d}
+}
+
+type limitedDirIterator struct {
+	files.DirIterator
+	dir  *limitedDirectory
+	node files.Node
+	err  error
+}
+
+func (it *limitedDirIterator) Next() bool {
+	if it.err != nil || !it.DirIterator.Next() {
+		return false
+	}
+
+	it.node, it.err = it.dir.budget.limitNode(it.DirIterator.Node(), it.dir.depth+1)
+	return it.err == nil
+}
+
+func (it *limitedDirIterator) Node() files.Node {
+	return it.node
+}
+
+func (it *limitedDirIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseLimits(t *testing.T) {
+	t.Parallel()
+
+	// The fixtures include non-UnixFS entries, which cannot be archived.
+	backend := newWritableBlocksBackend(t)
+	root, err := backend.AddFile(context.Background(), files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewMapDirectory(map[string]files.Node{
+			"b": files.NewMapDirectory(map[string]files.Node{
+				"c.txt": files.NewBytesFile([]byte("hello")),
+			}),
+		}),
+	}))
+	require.NoError(t, err)
+
+	get := func(t *testing.T, limits ResponseLimits, url, accept string) (*http.Response, []byte) {
+		ts := newTestServerWithConfig(t, backend, Config{
+			DeserializedResponses: true,
+			ResponseLimits:        limits,
+		})
+
+		req := mustNewRequest(t, http.MethodGet, ts.URL+url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	countBlocks := func(t *testing.T, body []byte) int {
+		br, err := car.NewBlockReader(bytes.NewReader(body))
+		require.NoError(t, err)
+
+		var n int
+		for {
+			if _, err := br.Next(); err != nil {
+				return n
+			}
+			n++
+		}
+	}
+
+	_, body := get(t, ResponseLimits{}, root.String()+"?format=car", "")
+	allBlocks := countBlocks(t, body)
+	require.Greater(t, allBlocks, 2)
+
+	t.Run("CAR is cut off after the maximum number of blocks", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxBlocks: 2}, root.String()+"?format=car", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 2, countBlocks(t, body))
+	})
+
+	t.Run("CAR is cut off at the maximum depth", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxDepth: 1}, root.String()+"?format=car", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		n := countBlocks(t, body)
+		assert.Greater(t, n, 1)
+		assert.Less(t, n, allBlocks)
+	})
+
+	t.Run("CAR with duplicates larger than the maximum bytes fails early", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxBytes: 10}, root.String(), "application/vnd.ipld.car; version=1; order=dfs; dups=y")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+		assert.Contains(t, string(body), ErrResponseLimitExceeded.Error())
+	})
+
+	t.Run("TAR of a file larger than the maximum bytes fails early", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxBytes: 2}, root.String()+"/a/b/c.txt?format=tar", "")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+		assert.Contains(t, string(body), ErrResponseLimitExceeded.Error())
+	})
+
+	t.Run("TAR is cut off at the maximum depth", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxDepth: 1}, root.String()+"?format=tar", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, string(body), "entries nested deeper than 1")
+		assert.NotContains(t, string(body), "c.txt")
+	})
+
+	t.Run("TAR within the limits", func(t *testing.T) {
+		res, body := get(t, ResponseLimits{MaxBytes: 1 << 20, MaxDepth: 10}, root.String()+"?format=tar", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		names := map[string]string{}
+		tr := tar.NewReader(bytes.NewReader(body))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			names[hdr.Name] = string(data)
+		}
+		assert.Equal(t, "hello", names[root.RootCid().String()+"/a/b/c.txt"])
+	})
+}
@@ -1,14 +1,20 @@
 package gateway
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/andybalholm/brotli"
 )
 
 // errNoOverlap is returned by serveContent's parseRange if first-byte-pos of
@@ -39,7 +45,8 @@ func headerGetExact(h http.Header, key string) string {
 // Notable differences from http.ServeContent
 // 1. Takes an io.Reader instead of an io.ReaderSeeker
 // 2. Requires the size to be passed in explicitly instead of discovered via Seeker behavior
-// 3. Only handles a single HTTP Range, if multiple are requested it returns the first
+// 3. Handles multiple HTTP Ranges with a multipart/byteranges response only if content
+// is an io.Seeker (or for HEAD requests), otherwise it returns the first one
 // 4. The passed io.Reader must start at wherever the HTTP Range Request will start
 // 4. Requires the Content-Type header to already be set
 // 5. Does not require the name to be passed in for content sniffing
@@ -61,6 +68,8 @@ func httpServeContent(w http.ResponseWriter, r *http.Request, modtime time.Time,
 			if err == errNoOverlap {
 				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
 			}
+			// The error message is not encoded.
+			w.Header().Del("Content-Encoding")
 			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 			return
 		}
@@ -72,9 +81,15 @@ func httpServeContent(w http.ResponseWriter, r *http.Request, modtime time.Time,
 			ranges = nil
 		}
 
-		// We only support a single range request, if more than one is submitted we just send back the first
-		if len(ranges) > 0 {
-			ra := ranges[0]
+		// Multiple ranges can only be served if the content can seek to each
+		// of them, otherwise we just send back the first
+		_, seekable := content.(io.ReadSeeker)
+		if len(ranges) > 1 && !seekable && r.Method != http.MethodHead {
+			ranges = ranges[:1]
+		}
+
+		switch {
+		case len(ranges) == 1:
 			// RFC 7233, Section 4.1:
 			// "If a single part is being transferred, the server
 			// generating the 206 response MUST generate a
@@ -86,10 +101,23 @@ func httpServeContent(w http.ResponseWriter, r *http.Request, modtime time.Time,
 			// a request for a single range, since a client that
 			// does not request multiple parts might not support
 			// multipart responses."
-
+			ra := ranges[0]
 			sendSize = ra.length
 			code = http.StatusPartialContent
 			w.Header().Set("Content-Range", ra.contentRange(size))
+		case len(ranges) > 1:
+			ctype := w.Header().Get("Content-Type")
+			sendSize = rangesMIMESize(ranges, ctype, size)
+			code = http.StatusPartialContent
+
+			pr, pw := io.Pipe()
+			mw := multipart.NewWriter(pw)
+			w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+			defer pr.Close() // cause writing goroutine to fail and exit if CopyN doesn't finish.
+			if r.Method != http.MethodHead {
+				go writeMultipartRanges(pw, mw, content.(io.ReadSeeker), ranges, ctype, size)
+			}
+			content = pr
 		}
 
 		w.Header().Set("Accept-Ranges", "bytes")
@@ -434,6 +462,56 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 	return ranges, nil
 }
 
+func (r httpRange) mimeHeader(contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {r.contentRange(size)},
+		"Content-Type":  {contentType},
+	}
+}
+
+// rangesMIMESize returns the number of bytes it takes to encode the
+// provided ranges as a multipart response.
+func rangesMIMESize(ranges []httpRange, contentType string, contentSize int64) (encSize int64) {
+	var w countingWriter
+	mw := multipart.NewWriter(&w)
+	for _, ra := range ranges {
+		_, _ = mw.CreatePart(ra.mimeHeader(contentType, contentSize))
+		encSize += ra.length
+	}
+	_ = mw.Close()
+	encSize += int64(w)
+	return
+}
+
+// writeMultipartRanges writes the ranges of content to pw, as the parts of a
+// multipart/byteranges body.
+func writeMultipartRanges(pw *io.PipeWriter, mw *multipart.Writer, content io.ReadSeeker, ranges []httpRange, contentType string, size int64) {
+	for _, ra := range ranges {
+		part, err := mw.CreatePart(ra.mimeHeader(contentType, size))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.CopyN(part, content, ra.length); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+	mw.Close()
+	pw.Close()
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (n int, err error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
 func sumRangesSize(ranges []httpRange) (size int64) {
 	for _, ra := range ranges {
 		size += ra.length
@@ -470,3 +548,136 @@ func seekToRangeStart(data io.Seeker, ra *ByteRange) error {
 	}
 	return nil
 }
+
+// contentEncoding is a content coding of encoded responses, with the extension
+// of its pre-compressed sibling files.
+type contentEncoding struct {
+	name string
+	ext  string
+}
+
+// contentEncodings are the supported content codings, from the most to the
+// least preferred.
+var contentEncodings = []contentEncoding{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// contentEncodingByName returns the content coding with the given name.
+func contentEncodingByName(name string) (contentEncoding, bool) {
+	for _, enc := range contentEncodings {
+		if enc.name == name {
+			return enc, true
+		}
+	}
+	return contentEncoding{}, false
+}
+
+// acceptedEncodings returns the names of the contentEncodings accepted by the
+// Accept-Encoding header of the request, ordered by preference.
+func acceptedEncodings(r *http.Request) []string {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return nil
+	}
+
+	weights := map[string]float64{}
+	for _, spec := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(spec, ";")
+		coding = strings.ToLower(textproto.TrimString(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.ToLower(textproto.TrimString(key)) != "q" {
+				continue
+			}
+			if v, err := strconv.ParseFloat(textproto.TrimString(value), 64); err == nil {
+				q = v
+			}
+		}
+		weights[coding] = q
+	}
+
+	weight := func(coding string) float64 {
+		if q, ok := weights[coding]; ok {
+			return q
+		}
+		return weights["*"]
+	}
+
+	var accepted []string
+	for _, enc := range contentEncodings {
+		if weight(enc.name) > 0 {
+			accepted = append(accepted, enc.name)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return weight(accepted[i]) > weight(accepted[j])
+	})
+	return accepted
+}
+
+// isCompressibleContentType reports whether responses with the given
+// Content-Type are worth compressing, which is the case of text formats.
+func isCompressibleContentType(ctype string) bool {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/json", "application/xml", "application/wasm":
+		return true
+	default:
+		return false
+	}
+}
+
+// compressOnTheFly returns a function that compresses with the given content
+// coding, if it is supported on the fly.
+func compressOnTheFly(name string) (func(io.Writer) io.WriteCloser, bool) {
+	switch name {
+	case "br":
+		return func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+		}, true
+	case "gzip":
+		return func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// compressResponseWriter compresses the body written to a ResponseWriter. The
+// compressed stream only starts with the body, so that responses without a
+// body, such as 304 Not Modified, stay empty.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	newWriter func(io.Writer) io.WriteCloser
+	cw        io.WriteCloser
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if w.cw == nil {
+		w.cw = w.newWriter(w.ResponseWriter)
+	}
+	return w.cw.Write(p)
+}
+
+// Close flushes the end of the compressed stream, if the body was written.
+func (w *compressResponseWriter) Close() error {
+	if w.cw == nil {
+		return nil
+	}
+	return w.cw.Close()
+}
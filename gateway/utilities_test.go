@@ -216,6 +216,9 @@ func newTestServer(t *testing.T, backend IPFSBackend) *httptest.Server {
 }
 
 func newTestServerWithConfig(t *testing.T, backend IPFSBackend, config Config) *httptest.Server {
+	if config.Headers == nil {
+		config.Headers = map[string][]string{}
+	}
 	AddAccessControlHeaders(config.Headers)
 
 	handler := NewHandler(config, backend)
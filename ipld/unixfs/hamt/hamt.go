@@ -401,6 +401,18 @@ func (ds *Shard) ForEachLink(ctx context.Context, f func(*ipld.Link) error) erro
 	})
 }
 
+// ForEachLinkFrom walks the Shard like ForEachLink, but starts at the link
+// named name, only loading the shards on its path and after it. It returns
+// os.ErrNotExist if there is no such link.
+func (ds *Shard) ForEachLinkFrom(ctx context.Context, name string, f func(*ipld.Link) error) error {
+	return ds.walkTrieFrom(ctx, newHashBits(name), name, func(sv *Shard) error {
+		lnk := sv.val
+		lnk.Name = sv.key
+
+		return f(lnk)
+	})
+}
+
 // EnumLinksAsync returns a channel which will receive Links in the directory
 // as they are enumerated, where order is not guaranteed
 func (ds *Shard) EnumLinksAsync(ctx context.Context) <-chan format.LinkResult {
@@ -626,6 +638,51 @@ func (ds *Shard) walkTrie(ctx context.Context, cb func(*Shard) error) error {
 	})
 }
 
+// walkTrieFrom walks the trie like walkTrie, starting at the value with the
+// given key, whose hash is hv.
+func (ds *Shard) walkTrieFrom(ctx context.Context, hv *hashBits, key string, cb func(*Shard) error) error {
+	childIndex, err := hv.Next(ds.tableSizeLg2)
+	if err != nil {
+		return err
+	}
+	if !ds.childer.has(childIndex) {
+		return os.ErrNotExist
+	}
+
+	i := ds.childer.sliceIndex(childIndex)
+	child, err := ds.childer.get(ctx, i)
+	if err != nil {
+		return err
+	}
+	if child.isValueNode() {
+		if child.key != key {
+			return os.ErrNotExist
+		}
+		if err := cb(child); err != nil {
+			return err
+		}
+	} else if err := child.walkTrieFrom(ctx, hv, key, cb); err != nil {
+		return err
+	}
+
+	// Then walk the children after the one holding the key.
+	for i++; i < ds.childer.length(); i++ {
+		c, err := ds.childer.get(ctx, i)
+		if err != nil {
+			return err
+		}
+		if c.isValueNode() {
+			err = cb(c)
+		} else {
+			err = c.walkTrie(ctx, cb)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // swapValue sets the link `value` in the given key, either creating the entry
 // if it didn't exist or overwriting the old one. It returns the old entry (if any).
 func (ds *Shard) swapValue(ctx context.Context, hv *hashBits, key string, value *ipld.Link) (*ipld.Link, error) {
@@ -403,6 +403,58 @@ func TestEnumLinksAsync(t *testing.T) {
 	}
 }
 
+func TestForEachLinkFrom(t *testing.T) {
+	ds := mdtest.Mock()
+	_, s, err := makeDirWidth(ds, 300, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	nd, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	nds, err := NewHamtFromDag(ds, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nds.ForEachLink(ctx, func(l *ipld.Link) error {
+		names = append(names, l.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{0, 1, 150, len(names) - 1} {
+		// Walk a freshly loaded shard, to only load the shards from the name.
+		nds, err := NewHamtFromDag(ds, nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var from []string
+		err = nds.ForEachLinkFrom(ctx, names[i], func(l *ipld.Link) error {
+			from = append(from, l.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(from) != fmt.Sprint(names[i:]) {
+			t.Fatalf("expected the links from %q in order, got %v", names[i], from)
+		}
+	}
+
+	err = nds.ForEachLinkFrom(ctx, "missing", func(*ipld.Link) error { return nil })
+	if err != os.ErrNotExist {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
 func TestDuplicateAddShard(t *testing.T) {
 	ds := mdtest.Mock()
 	dir, _ := NewShard(ds, 256)
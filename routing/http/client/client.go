@@ -15,6 +15,7 @@ import (
 	"github.com/benbjohnson/clock"
 	ipns "github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/routing/http/contentrouter"
+	"github.com/ipfs/boxo/routing/http/filters"
 	"github.com/ipfs/boxo/routing/http/internal/drjson"
 	"github.com/ipfs/boxo/routing/http/types"
 	"github.com/ipfs/boxo/routing/http/types/iter"
@@ -55,6 +56,10 @@ type Client struct {
 	addrs    []types.Multiaddr
 	identity crypto.PrivKey
 
+	// Filters of the records of FindProviders and FindPeers (IPIP-484).
+	protocolFilter []string
+	addrFilter     []string
+
 	// Called immediately after signing a provide request. It is used
 	// for testing, e.g., testing the server with a mangled signature.
 	//lint:ignore SA1019 // ignore staticcheck
@@ -117,6 +122,29 @@ func WithStreamResultsRequired() Option {
 	}
 }
 
+// WithProtocolFilter sets the transfer protocols of the records returned by
+// FindProviders and FindPeers, with the filter-protocols query parameter of
+// IPIP-484. Protocols prefixed with `!` are excluded, and `unknown` matches
+// the records without protocols. The filter is also applied to the results, in
+// case the server ignores it.
+func WithProtocolFilter(protocols []string) Option {
+	return func(c *Client) {
+		c.protocolFilter = protocols
+	}
+}
+
+// WithAddrFilter sets the multiaddr protocols of the addresses returned by
+// FindProviders and FindPeers, with the filter-addrs query parameter of
+// IPIP-484. Protocols prefixed with `!` exclude the addresses that contain
+// them, and `unknown` matches the records without addresses. Records without
+// matching addresses are omitted. The filter is also applied to the results,
+// in case the server ignores it.
+func WithAddrFilter(addrs []string) Option {
+	return func(c *Client) {
+		c.addrFilter = addrs
+	}
+}
+
 // New creates a content routing API client.
 // The Provider and identity parameters are option. If they are nil, the [client.ProvideBitswap] method will not function.
 func New(baseURL string, opts ...Option) (*Client, error) {
@@ -164,7 +192,10 @@ func (c *Client) FindProviders(ctx context.Context, key cid.Cid) (providers iter
 	// TODO test measurements
 	m := newMeasurement("FindProviders")
 
-	url := c.baseURL + "/routing/v1/providers/" + key.String()
+	url, err := filters.AddFiltersToURL(c.baseURL+"/routing/v1/providers/"+key.String(), c.protocolFilter, c.addrFilter)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -231,7 +262,8 @@ func (c *Client) FindProviders(ctx context.Context, key cid.Cid) (providers iter
 		return nil, errors.New("unknown content type")
 	}
 
-	return &measuringIter[iter.Result[types.Record]]{Iter: it, ctx: ctx, m: m}, nil
+	it = &measuringIter[iter.Result[types.Record]]{Iter: it, ctx: ctx, m: m}
+	return filters.ApplyFiltersToIter(it, c.protocolFilter, c.addrFilter), nil
 }
 
 // Deprecated: protocol-agnostic provide is being worked on in [IPIP-378]:
@@ -333,9 +365,23 @@ func (c *Client) provideSignedBitswapRecord(ctx context.Context, bswp *types.Wri
 }
 
 func (c *Client) FindPeers(ctx context.Context, pid peer.ID) (peers iter.ResultIter[types.Record], err error) {
-	m := newMeasurement("FindPeers")
+	return c.getPeers(ctx, "FindPeers", c.baseURL+"/routing/v1/peers/"+peer.ToCid(pid).String())
+}
+
+// GetClosestPeers returns the DHT peers closest to the given key, which can be
+// any CID, including the CID of a peer ID.
+func (c *Client) GetClosestPeers(ctx context.Context, key cid.Cid) (peers iter.ResultIter[types.Record], err error) {
+	return c.getPeers(ctx, "GetClosestPeers", c.baseURL+"/routing/v1/dht/closest/peers/"+key.String())
+}
 
-	url := c.baseURL + "/routing/v1/peers/" + peer.ToCid(pid).String()
+// getPeers returns the peer records of a peers response, in JSON or NDJSON.
+func (c *Client) getPeers(ctx context.Context, operation, rawURL string) (iter.ResultIter[types.Record], error) {
+	m := newMeasurement(operation)
+
+	url, err := filters.AddFiltersToURL(rawURL, c.protocolFilter, c.addrFilter)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -402,7 +448,8 @@ func (c *Client) FindPeers(ctx context.Context, pid peer.ID) (peers iter.ResultI
 		return nil, errors.New("unknown content type")
 	}
 
-	return &measuringIter[iter.Result[types.Record]]{Iter: it, ctx: ctx, m: m}, nil
+	it = &measuringIter[iter.Result[types.Record]]{Iter: it, ctx: ctx, m: m}
+	return filters.ApplyFiltersToIter(it, c.protocolFilter, c.addrFilter), nil
 }
 
 func (c *Client) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
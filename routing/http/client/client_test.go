@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"runtime"
 	"testing"
 	"time"
@@ -18,6 +19,8 @@ import (
 	"github.com/ipfs/boxo/routing/http/types"
 	"github.com/ipfs/boxo/routing/http/types/iter"
 	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -709,3 +712,83 @@ func TestClient_IPNS(t *testing.T) {
 		runWithRecordOptions(t, ipns.WithV1Compatibility(false))
 	})
 }
+
+func TestClient_Filters(t *testing.T) {
+	pid1, _, _ := makeProviderAndIdentity()
+	pid2, _, _ := makeProviderAndIdentity()
+	tcp := multiaddr.StringCast("/ip4/127.0.0.1/tcp/4001")
+	quic := multiaddr.StringCast("/ip4/127.0.0.1/udp/4001/quic-v1")
+
+	queries := make(chan url.Values, 1)
+	// The server ignores the filters.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query()
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"Providers": []*types.PeerRecord{
+				{Schema: types.SchemaPeer, ID: &pid1, Protocols: []string{"transport-bitswap"}, Addrs: []types.Multiaddr{{Multiaddr: tcp}, {Multiaddr: quic}}},
+				{Schema: types.SchemaPeer, ID: &pid2, Protocols: []string{"transport-foo"}, Addrs: []types.Multiaddr{{Multiaddr: tcp}}},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, WithProtocolFilter([]string{"transport-bitswap"}), WithAddrFilter([]string{"!tcp"}))
+	require.NoError(t, err)
+
+	it, err := c.FindProviders(context.Background(), makeCID())
+	require.NoError(t, err)
+	records, err := iter.ReadAllResults(it)
+	require.NoError(t, err)
+
+	query := <-queries
+	assert.Equal(t, []string{"transport-bitswap"}, query["filter-protocols"])
+	assert.Equal(t, []string{"!tcp"}, query["filter-addrs"])
+
+	require.Len(t, records, 1)
+	record := records[0].(*types.PeerRecord)
+	assert.Equal(t, pid1, *record.ID)
+	assert.Equal(t, []types.Multiaddr{{Multiaddr: quic}}, record.Addrs)
+}
+
+func TestClient_GetClosestPeers(t *testing.T) {
+	router := server.NewDatastoreRouter(dssync.MutexWrap(ds.NewMapDatastore()))
+	t.Cleanup(func() { router.Close() })
+	srv := httptest.NewServer(server.Handler(router))
+	t.Cleanup(srv.Close)
+
+	pid, addrs, _ := makeProviderAndIdentity()
+	_, err := router.ProvideBitswap(context.Background(), &server.BitswapWriteProvideRequest{
+		Keys:  []cid.Cid{makeCID()},
+		ID:    pid,
+		Addrs: addrs,
+	})
+	require.NoError(t, err)
+
+	for _, streaming := range []bool{false, true} {
+		var opts []Option
+		if streaming {
+			opts = append(opts, WithStreamResultsRequired())
+		}
+		c, err := New(srv.URL, opts...)
+		require.NoError(t, err)
+
+		it, err := c.GetClosestPeers(context.Background(), peer.ToCid(pid))
+		require.NoError(t, err)
+		records, err := iter.ReadAllResults(it)
+		require.NoError(t, err)
+
+		require.Len(t, records, 1)
+		record := records[0].(*types.PeerRecord)
+		assert.Equal(t, pid, *record.ID)
+		assert.Equal(t, addrs, drAddrsToAddrs(record.Addrs))
+	}
+
+	t.Run("Unsupported router returns an error", func(t *testing.T) {
+		deps := makeTestDeps(t, nil, nil)
+		_, err := deps.client.GetClosestPeers(context.Background(), makeCID())
+		var httpErr *HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusNotImplemented, httpErr.StatusCode)
+	})
+}
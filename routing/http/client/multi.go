@@ -0,0 +1,330 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	ipns "github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/contentrouter"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultEndpointTimeout is the default time limit of the requests of a
+// [MultiClient] to each of its endpoints.
+const DefaultEndpointTimeout = 30 * time.Second
+
+var _ contentrouter.Client = &MultiClient{}
+
+// MultiClient is a delegated routing client that sends each request to
+// several endpoints in parallel, and combines their responses.
+//
+// Records of the same peer are only returned once, from the first endpoint
+// that returns them. The best IPNS record of all endpoints is returned, as
+// selected by [ipns.Validator.Select]. Requests fail only if they fail for
+// every endpoint.
+type MultiClient struct {
+	endpoints []*endpoint
+	timeout   time.Duration
+	clock     clock.Clock
+}
+
+type endpoint struct {
+	client *Client
+	host   string
+}
+
+type MultiOption func(*MultiClient)
+
+// WithEndpointTimeout sets the time limit of the requests to each endpoint,
+// including the time to stream results. Default is [DefaultEndpointTimeout].
+func WithEndpointTimeout(timeout time.Duration) MultiOption {
+	return func(c *MultiClient) {
+		c.timeout = timeout
+	}
+}
+
+// NewMulti creates a client that fans out requests to the given clients.
+func NewMulti(clients []*Client, opts ...MultiOption) (*MultiClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("at least one client is required")
+	}
+
+	c := &MultiClient{
+		timeout: DefaultEndpointTimeout,
+		clock:   clock.New(),
+	}
+	for _, client := range clients {
+		host := client.baseURL
+		if u, err := url.Parse(client.baseURL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		c.endpoints = append(c.endpoints, &endpoint{client: client, host: host})
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// forEach calls f for every endpoint in parallel, and returns the errors of
+// each endpoint.
+func (c *MultiClient) forEach(ctx context.Context, operation string, f func(ctx context.Context, i int, ep *endpoint) error) []error {
+	errs := make([]error, len(c.endpoints))
+
+	var wg sync.WaitGroup
+	for i, ep := range c.endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+
+			m := newMeasurement(operation)
+			m.host = ep.host
+
+			ectx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := c.clock.Now()
+			errs[i] = f(ectx, i, ep)
+			m.latency = c.clock.Since(start)
+			m.err = errs[i]
+			m.record(ctx)
+
+			if errs[i] != nil {
+				logger.Debugw("endpoint request failed", "Operation", operation, "Host", ep.host, "Error", errs[i])
+			}
+		}(i, ep)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// joinErrors returns nil if any of errs is nil, and all of them otherwise.
+func joinErrors(errs []error) error {
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *MultiClient) FindProviders(ctx context.Context, key cid.Cid) (iter.ResultIter[types.Record], error) {
+	return c.findRecords(ctx, "MultiFindProviders", func(ctx context.Context, client *Client) (iter.ResultIter[types.Record], error) {
+		return client.FindProviders(ctx, key)
+	}), nil
+}
+
+func (c *MultiClient) FindPeers(ctx context.Context, pid peer.ID) (iter.ResultIter[types.Record], error) {
+	return c.findRecords(ctx, "MultiFindPeers", func(ctx context.Context, client *Client) (iter.ResultIter[types.Record], error) {
+		return client.FindPeers(ctx, pid)
+	}), nil
+}
+
+// GetClosestPeers returns the closest peers of every endpoint, in the order in
+// which they arrive.
+func (c *MultiClient) GetClosestPeers(ctx context.Context, key cid.Cid) (iter.ResultIter[types.Record], error) {
+	return c.findRecords(ctx, "MultiGetClosestPeers", func(ctx context.Context, client *Client) (iter.ResultIter[types.Record], error) {
+		return client.GetClosestPeers(ctx, key)
+	}), nil
+}
+
+// findRecords streams the records of every endpoint as they arrive. If every
+// endpoint fails before returning a record, the iterator returns their errors.
+func (c *MultiClient) findRecords(ctx context.Context, operation string, find func(context.Context, *Client) (iter.ResultIter[types.Record], error)) iter.ResultIter[types.Record] {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan iter.Result[types.Record])
+
+	var (
+		wg     sync.WaitGroup
+		errsLk sync.Mutex
+		errs   []error
+	)
+
+	for _, ep := range c.endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+
+			m := newMeasurement(operation)
+			m.host = ep.host
+			start := c.clock.Now()
+			defer func() {
+				m.latency = c.clock.Since(start)
+				m.record(ctx)
+			}()
+
+			ectx, ecancel := context.WithTimeout(ctx, c.timeout)
+			defer ecancel()
+
+			it, err := find(ectx, ep.client)
+			if err == nil {
+				defer it.Close()
+				for it.Next() {
+					res := it.Val()
+					if res.Err != nil {
+						err = res.Err
+						break
+					}
+					select {
+					case out <- res:
+						m.length++
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			if err != nil {
+				m.err = err
+				logger.Debugw("endpoint request failed", "Operation", operation, "Host", ep.host, "Error", err)
+				if m.length == 0 {
+					errsLk.Lock()
+					errs = append(errs, err)
+					errsLk.Unlock()
+				}
+			}
+		}(ep)
+	}
+
+	go func() {
+		defer close(out)
+		wg.Wait()
+
+		if len(errs) == len(c.endpoints) {
+			select {
+			case out <- iter.Result[types.Record]{Err: errors.Join(errs...)}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return &mergedIter{ch: out, cancel: cancel, seen: map[peer.ID]struct{}{}}
+}
+
+// mergedIter deduplicates the records of several endpoints by peer ID.
+type mergedIter struct {
+	ch     chan iter.Result[types.Record]
+	cancel context.CancelFunc
+	seen   map[peer.ID]struct{}
+	val    iter.Result[types.Record]
+}
+
+func (m *mergedIter) Next() bool {
+	for res := range m.ch {
+		if res.Err == nil {
+			if pid := recordPeerID(res.Val); pid != nil {
+				if _, ok := m.seen[*pid]; ok {
+					continue
+				}
+				m.seen[*pid] = struct{}{}
+			}
+		}
+		m.val = res
+		return true
+	}
+	return false
+}
+
+func (m *mergedIter) Val() iter.Result[types.Record] {
+	return m.val
+}
+
+func (m *mergedIter) Close() error {
+	m.cancel()
+	// Wait for the endpoint goroutines to exit.
+	for range m.ch {
+	}
+	return nil
+}
+
+// recordPeerID returns the peer ID of the record, or nil if it is unknown.
+func recordPeerID(rec types.Record) *peer.ID {
+	switch r := rec.(type) {
+	case *types.PeerRecord:
+		return r.ID
+	//lint:ignore SA1019 // ignore staticcheck
+	case *types.BitswapRecord:
+		return r.ID
+	}
+	return nil
+}
+
+// ProvideBitswap provides the keys to every endpoint, and returns the shortest
+// advisory TTL of the endpoints that accepted them.
+//
+// Deprecated: protocol-agnostic provide is being worked on in [IPIP-378]:
+//
+// [IPIP-378]: https://github.com/ipfs/specs/pull/378
+func (c *MultiClient) ProvideBitswap(ctx context.Context, keys []cid.Cid, ttl time.Duration) (time.Duration, error) {
+	ttls := make([]time.Duration, len(c.endpoints))
+	errs := c.forEach(ctx, "MultiProvideBitswap", func(ctx context.Context, i int, ep *endpoint) error {
+		var err error
+		ttls[i], err = ep.client.ProvideBitswap(ctx, keys, ttl)
+		return err
+	})
+	if err := joinErrors(errs); err != nil {
+		return 0, err
+	}
+
+	var minTTL time.Duration
+	for i, err := range errs {
+		if err == nil && (minTTL == 0 || ttls[i] < minTTL) {
+			minTTL = ttls[i]
+		}
+	}
+	return minTTL, nil
+}
+
+// GetIPNS returns the best valid record of all endpoints.
+func (c *MultiClient) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
+	var (
+		lk      sync.Mutex
+		records []*ipns.Record
+		raws    [][]byte
+	)
+	errs := c.forEach(ctx, "MultiGetIPNS", func(ctx context.Context, _ int, ep *endpoint) error {
+		record, err := ep.client.GetIPNS(ctx, name)
+		if err != nil {
+			return err
+		}
+		raw, err := ipns.MarshalRecord(record)
+		if err != nil {
+			return err
+		}
+
+		lk.Lock()
+		records = append(records, record)
+		raws = append(raws, raw)
+		lk.Unlock()
+		return nil
+	})
+	if err := joinErrors(errs); err != nil {
+		return nil, err
+	}
+
+	// The records have been validated by each client.
+	i, err := ipns.Validator{}.Select(string(name.RoutingKey()), raws)
+	if err != nil {
+		return nil, err
+	}
+	return records[i], nil
+}
+
+// PutIPNS puts the record to every endpoint. It only fails if every endpoint
+// fails.
+func (c *MultiClient) PutIPNS(ctx context.Context, name ipns.Name, record *ipns.Record) error {
+	errs := c.forEach(ctx, "MultiPutIPNS", func(ctx context.Context, _ int, ep *endpoint) error {
+		return ep.client.PutIPNS(ctx, name, record)
+	})
+	return joinErrors(errs)
+}
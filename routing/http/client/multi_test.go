@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ipns "github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type multiTestEndpoint struct {
+	router *server.DatastoreRouter
+	server *httptest.Server
+	client *Client
+}
+
+func makeMultiTestEndpoint(t *testing.T, handler func(http.Handler) http.Handler, opts ...server.DatastoreRouterOption) *multiTestEndpoint {
+	router := server.NewDatastoreRouter(dssync.MutexWrap(ds.NewMapDatastore()), opts...)
+	t.Cleanup(func() { router.Close() })
+
+	var h http.Handler = server.Handler(router)
+	if handler != nil {
+		h = handler(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	peerID, addrs, identity := makeProviderAndIdentity()
+	c, err := New(srv.URL, WithProviderInfo(peerID, addrs), WithIdentity(identity))
+	require.NoError(t, err)
+
+	return &multiTestEndpoint{router: router, server: srv, client: c}
+}
+
+func provideFrom(t *testing.T, ep *multiTestEndpoint, pid peer.ID, keys ...cid.Cid) {
+	_, err := ep.router.ProvideBitswap(context.Background(), &server.BitswapWriteProvideRequest{
+		Keys:        keys,
+		AdvisoryTTL: time.Hour,
+		ID:          pid,
+	})
+	require.NoError(t, err)
+}
+
+func readProviders(t *testing.T, c *MultiClient, key cid.Cid) ([]peer.ID, error) {
+	it, err := c.FindProviders(context.Background(), key)
+	require.NoError(t, err)
+	defer it.Close()
+
+	records, err := iter.ReadAllResults(it)
+	var pids []peer.ID
+	for _, rec := range records {
+		pids = append(pids, *rec.(*types.PeerRecord).ID)
+	}
+	return pids, err
+}
+
+func TestMultiClient_FindProviders(t *testing.T) {
+	key := makeCID()
+	pid1, _, _ := makeProviderAndIdentity()
+	pid2, _, _ := makeProviderAndIdentity()
+
+	t.Run("Records are merged and deduplicated", func(t *testing.T) {
+		ep1 := makeMultiTestEndpoint(t, nil)
+		ep2 := makeMultiTestEndpoint(t, nil)
+		provideFrom(t, ep1, pid1, key)
+		provideFrom(t, ep2, pid1, key)
+		provideFrom(t, ep2, pid2, key)
+
+		c, err := NewMulti([]*Client{ep1.client, ep2.client})
+		require.NoError(t, err)
+
+		pids, err := readProviders(t, c, key)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []peer.ID{pid1, pid2}, pids)
+	})
+
+	t.Run("Failing endpoints are ignored", func(t *testing.T) {
+		ep1 := makeMultiTestEndpoint(t, nil)
+		ep2 := makeMultiTestEndpoint(t, nil)
+		provideFrom(t, ep1, pid1, key)
+		ep2.server.Close()
+
+		c, err := NewMulti([]*Client{ep1.client, ep2.client})
+		require.NoError(t, err)
+
+		pids, err := readProviders(t, c, key)
+		require.NoError(t, err)
+		require.Equal(t, []peer.ID{pid1}, pids)
+	})
+
+	t.Run("Slow endpoints time out", func(t *testing.T) {
+		ep1 := makeMultiTestEndpoint(t, nil)
+		ep2 := makeMultiTestEndpoint(t, func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(10 * time.Second):
+				}
+			})
+		})
+		provideFrom(t, ep1, pid1, key)
+
+		c, err := NewMulti([]*Client{ep1.client, ep2.client}, WithEndpointTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+
+		start := time.Now()
+		pids, err := readProviders(t, c, key)
+		require.NoError(t, err)
+		require.Equal(t, []peer.ID{pid1}, pids)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("Error is returned if every endpoint fails", func(t *testing.T) {
+		ep1 := makeMultiTestEndpoint(t, nil)
+		ep2 := makeMultiTestEndpoint(t, nil)
+		ep1.server.Close()
+		ep2.server.Close()
+
+		c, err := NewMulti([]*Client{ep1.client, ep2.client})
+		require.NoError(t, err)
+
+		_, err = readProviders(t, c, key)
+		require.Error(t, err)
+	})
+
+	t.Run("Closing the iterator early does not block", func(t *testing.T) {
+		ep1 := makeMultiTestEndpoint(t, nil)
+		ep2 := makeMultiTestEndpoint(t, nil)
+		provideFrom(t, ep1, pid1, key)
+		provideFrom(t, ep2, pid2, key)
+
+		c, err := NewMulti([]*Client{ep1.client, ep2.client})
+		require.NoError(t, err)
+
+		it, err := c.FindProviders(context.Background(), key)
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		require.NoError(t, it.Close())
+	})
+}
+
+func TestMultiClient_FindPeers(t *testing.T) {
+	pid, _, _ := makeProviderAndIdentity()
+
+	ep1 := makeMultiTestEndpoint(t, nil)
+	ep2 := makeMultiTestEndpoint(t, nil)
+	provideFrom(t, ep1, pid, makeCID())
+	provideFrom(t, ep2, pid, makeCID())
+
+	c, err := NewMulti([]*Client{ep1.client, ep2.client})
+	require.NoError(t, err)
+
+	it, err := c.FindPeers(context.Background(), pid)
+	require.NoError(t, err)
+	records, err := iter.ReadAllResults(it)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, pid, *records[0].(*types.PeerRecord).ID)
+}
+
+func TestMultiClient_Provide(t *testing.T) {
+	ep1 := makeMultiTestEndpoint(t, nil, server.WithMaxProvideTTL(time.Hour))
+	ep2 := makeMultiTestEndpoint(t, nil, server.WithMaxProvideTTL(2*time.Hour))
+	ep3 := makeMultiTestEndpoint(t, nil)
+	ep3.server.Close()
+
+	// Provide with the same identity on every endpoint.
+	peerID, addrs, identity := makeProviderAndIdentity()
+	var clients []*Client
+	for _, ep := range []*multiTestEndpoint{ep1, ep2, ep3} {
+		client, err := New(ep.server.URL, WithProviderInfo(peerID, addrs), WithIdentity(identity))
+		require.NoError(t, err)
+		clients = append(clients, client)
+	}
+
+	c, err := NewMulti(clients)
+	require.NoError(t, err)
+
+	key := makeCID()
+	ttl, err := c.ProvideBitswap(context.Background(), []cid.Cid{key}, 24*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, ttl)
+
+	for _, ep := range []*multiTestEndpoint{ep1, ep2} {
+		it, err := ep.router.FindProviders(context.Background(), key, 0)
+		require.NoError(t, err)
+		records, err := iter.ReadAllResults(it)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, peerID, *records[0].(*types.PeerRecord).ID)
+	}
+}
+
+func TestMultiClient_IPNS(t *testing.T) {
+	ctx := context.Background()
+	sk, name := makeName(t)
+	key, err := cid.Decode("bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4")
+	require.NoError(t, err)
+
+	makeRecord := func(t *testing.T, seq uint64) *ipns.Record {
+		rec, err := ipns.NewRecord(sk, path.FromCid(key), seq, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		return rec
+	}
+
+	ep1 := makeMultiTestEndpoint(t, nil)
+	ep2 := makeMultiTestEndpoint(t, nil)
+	c, err := NewMulti([]*Client{ep1.client, ep2.client})
+	require.NoError(t, err)
+
+	t.Run("Error is returned if no endpoint has a record", func(t *testing.T) {
+		_, err := c.GetIPNS(ctx, name)
+		require.Error(t, err)
+	})
+
+	t.Run("Record is put to every endpoint", func(t *testing.T) {
+		require.NoError(t, c.PutIPNS(ctx, name, makeRecord(t, 1)))
+
+		for _, ep := range []*multiTestEndpoint{ep1, ep2} {
+			_, err := ep.router.GetIPNS(ctx, name)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("Best record is returned", func(t *testing.T) {
+		require.NoError(t, ep2.router.PutIPNS(ctx, name, makeRecord(t, 2)))
+
+		rec, err := c.GetIPNS(ctx, name)
+		require.NoError(t, err)
+		seq, err := rec.Sequence()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), seq)
+	})
+}
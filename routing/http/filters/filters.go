@@ -0,0 +1,203 @@
+// Package filters implements the filtering of delegated routing records by
+// transfer protocol and multiaddr, as specified by [IPIP-484].
+//
+// A filter is a list of names. A record matches a protocol filter if one of
+// its transfer protocols is in the list, and a multiaddr matches an address
+// filter if one of its protocols is in the list. Names prefixed with `!` are
+// negated, and exclude the records or multiaddrs that match them. The
+// `unknown` name matches the records without protocols or without addresses.
+// Records whose addresses are all filtered out are excluded.
+//
+// [IPIP-484]: https://github.com/ipfs/specs/pull/484
+package filters
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+)
+
+const (
+	// QueryProtocols is the query parameter of the transfer protocol filter.
+	QueryProtocols = "filter-protocols"
+
+	// QueryAddrs is the query parameter of the multiaddr filter.
+	QueryAddrs = "filter-addrs"
+
+	// Unknown matches the records without protocols or without addresses.
+	Unknown = "unknown"
+)
+
+// ParseFilter parses the comma-separated value of a filter query parameter.
+func ParseFilter(param string) []string {
+	var filter []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && name != "!" {
+			filter = append(filter, name)
+		}
+	}
+	return filter
+}
+
+// AddFiltersToURL returns rawURL with the query parameters of the filters.
+// The names are sorted, so that equivalent filters result in the same URL.
+func AddFiltersToURL(rawURL string, filterProtocols, filterAddrs []string) (string, error) {
+	if len(filterProtocols) == 0 && len(filterAddrs) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for param, filter := range map[string][]string{QueryProtocols: filterProtocols, QueryAddrs: filterAddrs} {
+		if len(filter) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), filter...)
+		sort.Strings(sorted)
+		query.Set(param, strings.Join(sorted, ","))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// ApplyFiltersToIter returns an iterator over the records of recordsIter that
+// match the filters, with their multiaddrs filtered. Errors are kept.
+func ApplyFiltersToIter(recordsIter iter.ResultIter[types.Record], filterProtocols, filterAddrs []string) iter.ResultIter[types.Record] {
+	if len(filterProtocols) == 0 && len(filterAddrs) == 0 {
+		return recordsIter
+	}
+
+	mapped := iter.Map[iter.Result[types.Record]](recordsIter, func(res iter.Result[types.Record]) iter.Result[types.Record] {
+		if res.Err != nil {
+			return res
+		}
+		return iter.Result[types.Record]{Val: ApplyFilters(res.Val, filterProtocols, filterAddrs)}
+	})
+	return iter.Filter[iter.Result[types.Record]](mapped, func(res iter.Result[types.Record]) bool {
+		return res.Err != nil || res.Val != nil
+	})
+}
+
+// ApplyFilters returns the record with its multiaddrs filtered, or nil if it
+// does not match the filters. The record is not modified. Records of unknown
+// schemas are returned as is.
+func ApplyFilters(record types.Record, filterProtocols, filterAddrs []string) types.Record {
+	switch r := record.(type) {
+	case *types.PeerRecord:
+		if !protocolsAllowed(r.Protocols, filterProtocols) {
+			return nil
+		}
+		addrs, ok := applyAddrFilter(r.Addrs, filterAddrs)
+		if !ok {
+			return nil
+		}
+		filtered := *r
+		filtered.Addrs = addrs
+		return &filtered
+	//lint:ignore SA1019 // ignore staticcheck
+	case *types.BitswapRecord:
+		var protocols []string
+		if r.Protocol != "" {
+			protocols = []string{r.Protocol}
+		}
+		if !protocolsAllowed(protocols, filterProtocols) {
+			return nil
+		}
+		addrs, ok := applyAddrFilter(r.Addrs, filterAddrs)
+		if !ok {
+			return nil
+		}
+		filtered := *r
+		filtered.Addrs = addrs
+		return &filtered
+	default:
+		return record
+	}
+}
+
+// splitFilter splits the positive and negated names of a filter.
+func splitFilter(filter []string) (positive, negative map[string]bool) {
+	positive, negative = map[string]bool{}, map[string]bool{}
+	for _, name := range filter {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "!") {
+			negative[strings.TrimPrefix(name, "!")] = true
+		} else {
+			positive[name] = true
+		}
+	}
+	return positive, negative
+}
+
+// matchesUnknown reports whether a record without protocols or addresses
+// matches the filter.
+func matchesUnknown(positive, negative map[string]bool) bool {
+	if positive[Unknown] {
+		return true
+	}
+	return len(positive) == 0 && !negative[Unknown]
+}
+
+func protocolsAllowed(protocols []string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	positive, negative := splitFilter(filter)
+	if len(protocols) == 0 {
+		return matchesUnknown(positive, negative)
+	}
+
+	for _, p := range protocols {
+		if negative[strings.ToLower(p)] {
+			return false
+		}
+	}
+	if len(positive) == 0 {
+		return true
+	}
+	for _, p := range protocols {
+		if positive[strings.ToLower(p)] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAddrFilter returns the multiaddrs that match the filter, and false if
+// the record must be excluded.
+func applyAddrFilter(addrs []types.Multiaddr, filter []string) ([]types.Multiaddr, bool) {
+	if len(filter) == 0 {
+		return addrs, true
+	}
+
+	positive, negative := splitFilter(filter)
+	if len(addrs) == 0 {
+		return addrs, matchesUnknown(positive, negative)
+	}
+
+	filtered := []types.Multiaddr{}
+	for _, addr := range addrs {
+		if addr.Multiaddr == nil {
+			continue
+		}
+		var included, excluded bool
+		for _, p := range addr.Protocols() {
+			included = included || positive[p.Name]
+			excluded = excluded || negative[p.Name]
+		}
+		if !excluded && (included || len(positive) == 0) {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered, len(filtered) > 0
+}
@@ -0,0 +1,118 @@
+package filters
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeAddrs(t *testing.T, addrs ...string) []types.Multiaddr {
+	var mas []types.Multiaddr
+	for _, a := range addrs {
+		ma, err := multiaddr.NewMultiaddr(a)
+		require.NoError(t, err)
+		mas = append(mas, types.Multiaddr{Multiaddr: ma})
+	}
+	return mas
+}
+
+func TestParseFilter(t *testing.T) {
+	assert.Nil(t, ParseFilter(""))
+	assert.Equal(t, []string{"transport-bitswap", "!unknown"}, ParseFilter(" transport-bitswap, ,!UNKNOWN,!"))
+}
+
+func TestAddFiltersToURL(t *testing.T) {
+	u, err := AddFiltersToURL("https://example.com/routing/v1/providers/bafkqaaa", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/routing/v1/providers/bafkqaaa", u)
+
+	u, err = AddFiltersToURL("https://example.com/routing/v1/providers/bafkqaaa", []string{"transport-ipfs-gateway-http", "transport-bitswap"}, []string{"!p2p-circuit"})
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(u)
+	require.NoError(t, err)
+	assert.Equal(t, "transport-bitswap,transport-ipfs-gateway-http", parsed.Query().Get(QueryProtocols))
+	assert.Equal(t, "!p2p-circuit", parsed.Query().Get(QueryAddrs))
+}
+
+func TestApplyFilters(t *testing.T) {
+	quic := "/ip4/127.0.0.1/udp/4001/quic-v1"
+	tcp := "/ip4/127.0.0.1/tcp/4001"
+	relay := "/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vn/p2p-circuit"
+
+	for _, test := range []struct {
+		name            string
+		protocols       []string
+		addrs           []string
+		filterProtocols []string
+		filterAddrs     []string
+		expected        []string
+		excluded        bool
+	}{
+		{"No filters", []string{"transport-bitswap"}, []string{quic, tcp}, nil, nil, []string{quic, tcp}, false},
+		{"Matching protocol", []string{"transport-bitswap", "transport-foo"}, []string{tcp}, []string{"transport-bitswap"}, nil, []string{tcp}, false},
+		{"Protocol filter is case insensitive", []string{"transport-bitswap"}, []string{tcp}, []string{"Transport-Bitswap"}, nil, []string{tcp}, false},
+		{"Other protocol", []string{"transport-foo"}, []string{tcp}, []string{"transport-bitswap"}, nil, nil, true},
+		{"Negated protocol", []string{"transport-bitswap", "transport-foo"}, []string{tcp}, []string{"!transport-foo"}, nil, nil, true},
+		{"Unknown protocols are excluded by positive filters", nil, []string{tcp}, []string{"transport-bitswap"}, nil, nil, true},
+		{"Unknown protocols are included with unknown", nil, []string{tcp}, []string{"transport-bitswap", "unknown"}, nil, []string{tcp}, false},
+		{"Unknown protocols are included by negative filters", nil, []string{tcp}, []string{"!transport-foo"}, nil, []string{tcp}, false},
+		{"Unknown protocols are excluded with !unknown", nil, []string{tcp}, []string{"!unknown"}, nil, nil, true},
+		{"Matching addrs", nil, []string{quic, tcp, relay}, nil, []string{"quic-v1"}, []string{quic}, false},
+		{"Negated addrs", nil, []string{quic, tcp, relay}, nil, []string{"!p2p-circuit"}, []string{quic, tcp}, false},
+		{"Positive and negated addrs", nil, []string{quic, tcp, relay}, nil, []string{"tcp", "!p2p-circuit"}, []string{tcp}, false},
+		{"No matching addrs", nil, []string{tcp}, nil, []string{"webtransport"}, nil, true},
+		{"Unknown addrs are excluded by positive filters", nil, nil, nil, []string{"tcp"}, nil, true},
+		{"Unknown addrs are included with unknown", nil, nil, nil, []string{"tcp", "unknown"}, nil, false},
+		{"Known addrs are excluded with unknown only", nil, []string{tcp}, nil, []string{"unknown"}, nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pid, err := peer.Decode("12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vn")
+			require.NoError(t, err)
+
+			record := &types.PeerRecord{
+				Schema:    types.SchemaPeer,
+				ID:        &pid,
+				Protocols: test.protocols,
+				Addrs:     makeAddrs(t, test.addrs...),
+			}
+			original := *record
+
+			filtered := ApplyFilters(record, test.filterProtocols, test.filterAddrs)
+			assert.Equal(t, original, *record, "record must not be modified")
+			if test.excluded {
+				assert.Nil(t, filtered)
+				return
+			}
+			require.NotNil(t, filtered)
+			assert.Equal(t, makeAddrs(t, test.expected...), filtered.(*types.PeerRecord).Addrs)
+		})
+	}
+}
+
+func TestApplyFiltersToIter(t *testing.T) {
+	pid, err := peer.Decode("12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vn")
+	require.NoError(t, err)
+
+	records := iter.FromSlice([]iter.Result[types.Record]{
+		{Val: &types.PeerRecord{Schema: types.SchemaPeer, ID: &pid, Protocols: []string{"transport-bitswap"}}},
+		{Val: &types.PeerRecord{Schema: types.SchemaPeer, ID: &pid, Protocols: []string{"transport-foo"}}},
+		//lint:ignore SA1019 // ignore staticcheck
+		{Val: &types.BitswapRecord{Schema: types.SchemaBitswap, ID: &pid, Protocol: "transport-bitswap"}},
+		{Val: &types.UnknownRecord{Schema: "unknown-schema"}},
+	})
+
+	results, err := iter.ReadAllResults(ApplyFiltersToIter(records, []string{"transport-bitswap"}, nil))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, types.SchemaPeer, results[0].GetSchema())
+	//lint:ignore SA1019 // ignore staticcheck
+	assert.Equal(t, types.SchemaBitswap, results[1].GetSchema())
+	assert.Equal(t, "unknown-schema", results[2].GetSchema())
+}
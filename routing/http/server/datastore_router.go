@@ -0,0 +1,544 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/datastore/dshelp"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+const (
+	// DefaultProvideTTL is the TTL of provider records whose request has no
+	// advisory TTL.
+	DefaultProvideTTL = 24 * time.Hour
+
+	// DefaultMaxProvideTTL is the maximum TTL of provider records. Longer
+	// advisory TTLs are capped to it.
+	DefaultMaxProvideTTL = 48 * time.Hour
+
+	// DefaultGCInterval is the interval between the removals of expired
+	// records from the datastore.
+	DefaultGCInterval = time.Hour
+)
+
+var (
+	providersPrefix = ds.NewKey("/providers")
+	peersPrefix     = ds.NewKey("/peers")
+	ipnsPrefix      = ds.NewKey("/ipns")
+)
+
+// ErrIPNSRecordNotNewer is returned by [DatastoreRouter.PutIPNS] when the
+// stored record is better than the provided one.
+var ErrIPNSRecordNotNewer = errors.New("can't replace a newer IPNS record with an older one")
+
+var (
+	_ ContentRouter      = (*DatastoreRouter)(nil)
+	_ ClosestPeersRouter = (*DatastoreRouter)(nil)
+)
+
+// DatastoreRouter is a [ContentRouter] that stores provider, peer and IPNS
+// records in a local datastore. It can be used with [Handler] to run a
+// self-hosted delegated router, or as a stand-in for a remote one in tests.
+//
+// Provider and peer records expire after the TTL of the request that created
+// them, and IPNS records at the end of their validity. Expired records are
+// never returned, and are removed from the datastore every GC interval.
+type DatastoreRouter struct {
+	datastore ds.Batching
+	validator ipns.Validator
+	clock     clock.Clock
+
+	defaultProvideTTL time.Duration
+	maxProvideTTL     time.Duration
+	gcInterval        time.Duration
+
+	// ipnsLk serializes the puts of IPNS records, so that a record cannot be
+	// replaced by an older one between the comparison and the write.
+	ipnsLk sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type DatastoreRouterOption func(*DatastoreRouter)
+
+// WithDefaultProvideTTL sets the TTL of provider records whose request has no
+// advisory TTL. Default is [DefaultProvideTTL].
+func WithDefaultProvideTTL(ttl time.Duration) DatastoreRouterOption {
+	return func(r *DatastoreRouter) {
+		r.defaultProvideTTL = ttl
+	}
+}
+
+// WithMaxProvideTTL sets the maximum TTL of provider records. Default is
+// [DefaultMaxProvideTTL].
+func WithMaxProvideTTL(ttl time.Duration) DatastoreRouterOption {
+	return func(r *DatastoreRouter) {
+		r.maxProvideTTL = ttl
+	}
+}
+
+// WithGCInterval sets the interval between the removals of expired records.
+// A non-positive interval disables the background removal, in which case
+// [DatastoreRouter.CollectGarbage] can be called instead. Default is
+// [DefaultGCInterval].
+func WithGCInterval(interval time.Duration) DatastoreRouterOption {
+	return func(r *DatastoreRouter) {
+		r.gcInterval = interval
+	}
+}
+
+// WithClock sets the clock used to expire records.
+func WithClock(c clock.Clock) DatastoreRouterOption {
+	return func(r *DatastoreRouter) {
+		r.clock = c
+	}
+}
+
+// NewDatastoreRouter creates a [DatastoreRouter] that stores its records in
+// dstore. [DatastoreRouter.Close] must be called to stop the background
+// removal of expired records.
+func NewDatastoreRouter(dstore ds.Batching, opts ...DatastoreRouterOption) *DatastoreRouter {
+	r := &DatastoreRouter{
+		datastore:         dstore,
+		clock:             clock.New(),
+		defaultProvideTTL: DefaultProvideTTL,
+		maxProvideTTL:     DefaultMaxProvideTTL,
+		gcInterval:        DefaultGCInterval,
+		done:              make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	if r.gcInterval > 0 {
+		go r.gcLoop(ctx, r.clock.Ticker(r.gcInterval))
+	} else {
+		close(r.done)
+	}
+
+	return r
+}
+
+// Close stops the background removal of expired records.
+func (r *DatastoreRouter) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+func (r *DatastoreRouter) gcLoop(ctx context.Context, ticker *clock.Ticker) {
+	defer close(r.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.CollectGarbage(ctx); err != nil && ctx.Err() == nil {
+				logger.Warnw("failed to remove expired records", "Error", err)
+			}
+		}
+	}
+}
+
+// storedProvider is the value of a provider record in the datastore.
+type storedProvider struct {
+	Addrs   []types.Multiaddr
+	Expires time.Time
+}
+
+// storedPeer is the value of a peer record in the datastore.
+type storedPeer struct {
+	Addrs     []types.Multiaddr
+	Protocols []string
+	Expires   time.Time
+}
+
+func providersKey(c cid.Cid) ds.Key {
+	return providersPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+func providerKey(c cid.Cid, pid peer.ID) ds.Key {
+	return providersKey(c).ChildString(pid.String())
+}
+
+func peerKey(pid peer.ID) ds.Key {
+	return peersPrefix.ChildString(pid.String())
+}
+
+func ipnsKey(name ipns.Name) ds.Key {
+	return ipnsPrefix.ChildString(name.String())
+}
+
+func (r *DatastoreRouter) FindProviders(ctx context.Context, c cid.Cid, limit int) (iter.ResultIter[types.Record], error) {
+	res, err := r.datastore.Query(ctx, query.Query{Prefix: providersKey(c).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	return newDatastoreIter(res, limit, func(e query.Entry) (types.Record, bool, error) {
+		var prov storedProvider
+		if err := json.Unmarshal(e.Value, &prov); err != nil {
+			return nil, false, fmt.Errorf("invalid provider record %s: %w", e.Key, err)
+		}
+		if !r.clock.Now().Before(prov.Expires) {
+			return nil, false, nil
+		}
+
+		pid, err := peer.Decode(ds.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid provider record %s: %w", e.Key, err)
+		}
+
+		return &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid,
+			Addrs:     prov.Addrs,
+			Protocols: []string{"transport-bitswap"},
+		}, true, nil
+	}), nil
+}
+
+// ProvideBitswap stores a provider record for each key, and a peer record
+// for the provider. The returned TTL is the advisory TTL of the request,
+// capped to the maximum provide TTL.
+func (r *DatastoreRouter) ProvideBitswap(ctx context.Context, req *BitswapWriteProvideRequest) (time.Duration, error) {
+	ttl := req.AdvisoryTTL
+	if ttl <= 0 {
+		ttl = r.defaultProvideTTL
+	}
+	if r.maxProvideTTL > 0 && ttl > r.maxProvideTTL {
+		ttl = r.maxProvideTTL
+	}
+	expires := r.clock.Now().Add(ttl)
+
+	addrs := make([]types.Multiaddr, len(req.Addrs))
+	for i, a := range req.Addrs {
+		addrs[i] = types.Multiaddr{Multiaddr: a}
+	}
+
+	b, err := r.datastore.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	provValue, err := json.Marshal(storedProvider{Addrs: addrs, Expires: expires})
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range req.Keys {
+		if err := b.Put(ctx, providerKey(c, req.ID), provValue); err != nil {
+			return 0, err
+		}
+	}
+
+	// The peer record lives as long as the longest provider record.
+	peerRec := storedPeer{Addrs: addrs, Protocols: []string{"transport-bitswap"}, Expires: expires}
+	if old, err := r.getPeer(ctx, req.ID); err == nil && old.Expires.After(expires) {
+		peerRec.Expires = old.Expires
+	}
+	peerValue, err := json.Marshal(peerRec)
+	if err != nil {
+		return 0, err
+	}
+	if err := b.Put(ctx, peerKey(req.ID), peerValue); err != nil {
+		return 0, err
+	}
+
+	if err := b.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+func (r *DatastoreRouter) getPeer(ctx context.Context, pid peer.ID) (storedPeer, error) {
+	var rec storedPeer
+	value, err := r.datastore.Get(ctx, peerKey(pid))
+	if err != nil {
+		return rec, err
+	}
+	err = json.Unmarshal(value, &rec)
+	return rec, err
+}
+
+func (r *DatastoreRouter) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[types.Record], error) {
+	rec, err := r.getPeer(ctx, pid)
+	switch {
+	case errors.Is(err, ds.ErrNotFound):
+		return iter.FromSlice[iter.Result[types.Record]](nil), nil
+	case err != nil:
+		return nil, err
+	case !r.clock.Now().Before(rec.Expires):
+		return iter.FromSlice[iter.Result[types.Record]](nil), nil
+	}
+
+	return iter.FromSlice([]iter.Result[types.Record]{
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid,
+			Addrs:     rec.Addrs,
+			Protocols: rec.Protocols,
+		}},
+	}), nil
+}
+
+// GetClosestPeers returns the known peers, from the closest to the farthest
+// from the key in the keyspace of the DHT. All peer records are read, so this
+// is only suitable for small stores.
+func (r *DatastoreRouter) GetClosestPeers(ctx context.Context, key cid.Cid, limit int) (iter.ResultIter[types.Record], error) {
+	res, err := r.datastore.Query(ctx, query.Query{Prefix: peersPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	type closePeer struct {
+		record   *types.PeerRecord
+		distance []byte
+	}
+
+	target := sha256.Sum256(key.Hash())
+	now := r.clock.Now()
+
+	var peers []closePeer
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		var rec storedPeer
+		if err := json.Unmarshal(e.Value, &rec); err != nil {
+			return nil, fmt.Errorf("invalid peer record %s: %w", e.Key, err)
+		}
+		if !now.Before(rec.Expires) {
+			continue
+		}
+		pid, err := peer.Decode(ds.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer record %s: %w", e.Key, err)
+		}
+
+		// Like in the DHT, distances are the XOR of the SHA-256 of the keys.
+		hash := sha256.Sum256([]byte(pid))
+		distance := make([]byte, len(hash))
+		for i := range hash {
+			distance[i] = hash[i] ^ target[i]
+		}
+
+		peers = append(peers, closePeer{
+			record: &types.PeerRecord{
+				Schema:    types.SchemaPeer,
+				ID:        &pid,
+				Addrs:     rec.Addrs,
+				Protocols: rec.Protocols,
+			},
+			distance: distance,
+		})
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return bytes.Compare(peers[i].distance, peers[j].distance) < 0
+	})
+	if limit > 0 && len(peers) > limit {
+		peers = peers[:limit]
+	}
+
+	results := make([]iter.Result[types.Record], len(peers))
+	for i, p := range peers {
+		results[i] = iter.Result[types.Record]{Val: p.record}
+	}
+	return iter.FromSlice(results), nil
+}
+
+// GetIPNS returns the stored record of the name, or [routing.ErrNotFound] if
+// there is none or if it has expired.
+func (r *DatastoreRouter) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
+	raw, err := r.datastore.Get(ctx, ipnsKey(name))
+	if errors.Is(err, ds.ErrNotFound) {
+		return nil, routing.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Validation also checks that the record has not expired.
+	if err := r.validator.Validate(string(name.RoutingKey()), raw); err != nil {
+		if errors.Is(err, ipns.ErrExpiredRecord) {
+			return nil, routing.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return ipns.UnmarshalRecord(raw)
+}
+
+// PutIPNS stores the record of the name if it is valid, and better than the
+// stored one, as selected by [ipns.Validator.Select]. Otherwise, it returns
+// [ErrIPNSRecordNotNewer].
+func (r *DatastoreRouter) PutIPNS(ctx context.Context, name ipns.Name, record *ipns.Record) error {
+	raw, err := ipns.MarshalRecord(record)
+	if err != nil {
+		return err
+	}
+
+	key := string(name.RoutingKey())
+	if err := r.validator.Validate(key, raw); err != nil {
+		return err
+	}
+
+	r.ipnsLk.Lock()
+	defer r.ipnsLk.Unlock()
+
+	old, err := r.datastore.Get(ctx, ipnsKey(name))
+	switch {
+	case errors.Is(err, ds.ErrNotFound):
+	case err != nil:
+		return err
+	case bytes.Equal(old, raw):
+		return nil
+	case r.validator.Validate(key, old) == nil:
+		// Only valid stored records can prevail over the new one.
+		i, err := r.validator.Select(key, [][]byte{raw, old})
+		if err != nil {
+			return err
+		}
+		if i != 0 {
+			return ErrIPNSRecordNotNewer
+		}
+	}
+
+	return r.datastore.Put(ctx, ipnsKey(name), raw)
+}
+
+// CollectGarbage removes the expired records from the datastore.
+func (r *DatastoreRouter) CollectGarbage(ctx context.Context) error {
+	now := r.clock.Now()
+
+	b, err := r.datastore.Batch(ctx)
+	if err != nil {
+		return err
+	}
+
+	isExpired := map[ds.Key]func(value []byte) bool{
+		providersPrefix: func(value []byte) bool {
+			var prov storedProvider
+			return json.Unmarshal(value, &prov) != nil || !now.Before(prov.Expires)
+		},
+		peersPrefix: func(value []byte) bool {
+			var rec storedPeer
+			return json.Unmarshal(value, &rec) != nil || !now.Before(rec.Expires)
+		},
+		ipnsPrefix: func(value []byte) bool {
+			rec, err := ipns.UnmarshalRecord(value)
+			if err != nil {
+				return true
+			}
+			eol, err := rec.Validity()
+			return err != nil || !now.Before(eol)
+		},
+	}
+
+	var removed int
+	for prefix, expired := range isExpired {
+		res, err := r.datastore.Query(ctx, query.Query{Prefix: prefix.String()})
+		if err != nil {
+			return err
+		}
+		for e := range res.Next() {
+			if e.Error != nil {
+				res.Close()
+				return e.Error
+			}
+			if !expired(e.Value) {
+				continue
+			}
+			if err := b.Delete(ctx, ds.RawKey(e.Key)); err != nil {
+				res.Close()
+				return err
+			}
+			removed++
+		}
+		res.Close()
+	}
+
+	if err := b.Commit(ctx); err != nil {
+		return err
+	}
+	logger.Debugw("removed expired records", "Count", removed)
+	return nil
+}
+
+// datastoreIter is a [iter.ResultIter] over the results of a datastore query.
+type datastoreIter struct {
+	res   query.Results
+	limit int
+	f     func(query.Entry) (types.Record, bool, error)
+
+	count int
+	val   iter.Result[types.Record]
+}
+
+// newDatastoreIter returns an iterator that converts the entries of res with
+// f, which returns false for the entries to skip. A limit of 0 is unbounded.
+func newDatastoreIter(res query.Results, limit int, f func(query.Entry) (types.Record, bool, error)) *datastoreIter {
+	return &datastoreIter{res: res, limit: limit, f: f}
+}
+
+func (d *datastoreIter) Next() bool {
+	if d.limit > 0 && d.count >= d.limit {
+		return false
+	}
+
+	for {
+		e, ok := d.res.NextSync()
+		if !ok {
+			return false
+		}
+		if e.Error != nil {
+			d.val = iter.Result[types.Record]{Err: e.Error}
+			return true
+		}
+
+		rec, ok, err := d.f(e.Entry)
+		if err != nil {
+			d.val = iter.Result[types.Record]{Err: err}
+			return true
+		}
+		if !ok {
+			continue
+		}
+
+		d.count++
+		d.val = iter.Result[types.Record]{Val: rec}
+		return true
+	}
+}
+
+func (d *datastoreIter) Val() iter.Result[types.Record] {
+	return d.val
+}
+
+func (d *datastoreIter) Close() error {
+	return d.res.Close()
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatastoreRouter(t *testing.T) (*DatastoreRouter, ds.Batching, *clock.Mock) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	clk := clock.NewMock()
+	// IPNS records expire in real time.
+	clk.Set(time.Now())
+	router := NewDatastoreRouter(dstore, WithClock(clk), WithGCInterval(0))
+	t.Cleanup(func() { router.Close() })
+	return router, dstore, clk
+}
+
+func countKeys(t *testing.T, dstore ds.Datastore) int {
+	res, err := dstore.Query(context.Background(), query.Query{KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	return len(entries)
+}
+
+func TestDatastoreRouterProviders(t *testing.T) {
+	ctx := context.Background()
+	router, dstore, clk := newTestDatastoreRouter(t)
+
+	cid1, err := cid.Decode("bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4")
+	require.NoError(t, err)
+	cid2, err := cid.Decode("bafkqaaa")
+	require.NoError(t, err)
+
+	_, pid1 := makePeerID(t)
+	_, pid2 := makePeerID(t)
+	addr := multiaddr.StringCast("/ip4/127.0.0.1/tcp/4001")
+
+	ttl, err := router.ProvideBitswap(ctx, &BitswapWriteProvideRequest{
+		Keys:        []cid.Cid{cid1, cid2},
+		AdvisoryTTL: time.Hour,
+		ID:          pid1,
+		Addrs:       []multiaddr.Multiaddr{addr},
+	})
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, ttl)
+
+	ttl, err = router.ProvideBitswap(ctx, &BitswapWriteProvideRequest{
+		Keys:        []cid.Cid{cid1},
+		AdvisoryTTL: time.Hour * 1000,
+		ID:          pid2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, DefaultMaxProvideTTL, ttl)
+
+	findProviders := func(t *testing.T, c cid.Cid, limit int) []peer.ID {
+		it, err := router.FindProviders(ctx, c, limit)
+		require.NoError(t, err)
+		defer it.Close()
+		records, err := iter.ReadAllResults(it)
+		require.NoError(t, err)
+
+		var pids []peer.ID
+		for _, rec := range records {
+			pr, ok := rec.(*types.PeerRecord)
+			require.True(t, ok)
+			require.Equal(t, []string{"transport-bitswap"}, pr.Protocols)
+			if *pr.ID == pid1 {
+				require.Equal(t, []types.Multiaddr{{Multiaddr: addr}}, pr.Addrs)
+			}
+			pids = append(pids, *pr.ID)
+		}
+		return pids
+	}
+
+	require.ElementsMatch(t, []peer.ID{pid1, pid2}, findProviders(t, cid1, 0))
+	require.ElementsMatch(t, []peer.ID{pid1}, findProviders(t, cid2, 0))
+	require.Len(t, findProviders(t, cid1, 1), 1)
+
+	peers := func(t *testing.T, pid peer.ID) []types.Record {
+		it, err := router.FindPeers(ctx, pid, 0)
+		require.NoError(t, err)
+		records, err := iter.ReadAllResults(it)
+		require.NoError(t, err)
+		return records
+	}
+	records := peers(t, pid1)
+	require.Len(t, records, 1)
+	require.Equal(t, []types.Multiaddr{{Multiaddr: addr}}, records[0].(*types.PeerRecord).Addrs)
+
+	// The records of pid1 expire first.
+	clk.Add(2 * time.Hour)
+	require.Equal(t, []peer.ID{pid2}, findProviders(t, cid1, 0))
+	require.Empty(t, findProviders(t, cid2, 0))
+	require.Empty(t, peers(t, pid1))
+	require.Len(t, peers(t, pid2), 1)
+
+	require.Equal(t, 5, countKeys(t, dstore))
+	require.NoError(t, router.CollectGarbage(ctx))
+	require.Equal(t, 2, countKeys(t, dstore))
+
+	clk.Add(DefaultMaxProvideTTL)
+	require.NoError(t, router.CollectGarbage(ctx))
+	require.Equal(t, 0, countKeys(t, dstore))
+}
+
+func TestDatastoreRouterIPNS(t *testing.T) {
+	ctx := context.Background()
+	router, dstore, _ := newTestDatastoreRouter(t)
+
+	cid1, err := cid.Decode("bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4")
+	require.NoError(t, err)
+
+	sk, name := makeName(t)
+	otherSk, _ := makeName(t)
+
+	makeRecord := func(t *testing.T, seq uint64, eol time.Time) *ipns.Record {
+		rec, err := ipns.NewRecord(sk, path.FromCid(cid1), seq, eol, time.Minute)
+		require.NoError(t, err)
+		return rec
+	}
+
+	_, err = router.GetIPNS(ctx, name)
+	require.ErrorIs(t, err, routing.ErrNotFound)
+
+	rec2 := makeRecord(t, 2, time.Now().Add(time.Hour))
+	require.NoError(t, router.PutIPNS(ctx, name, rec2))
+	require.NoError(t, router.PutIPNS(ctx, name, rec2))
+
+	rec, err := router.GetIPNS(ctx, name)
+	require.NoError(t, err)
+	seq, err := rec.Sequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), seq)
+
+	t.Run("Older record is rejected", func(t *testing.T) {
+		require.ErrorIs(t, router.PutIPNS(ctx, name, makeRecord(t, 1, time.Now().Add(time.Hour))), ErrIPNSRecordNotNewer)
+	})
+
+	t.Run("Record signed by another key is rejected", func(t *testing.T) {
+		rec, err := ipns.NewRecord(otherSk, path.FromCid(cid1), 3, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		require.Error(t, router.PutIPNS(ctx, name, rec))
+	})
+
+	t.Run("Newer record replaces the stored one", func(t *testing.T) {
+		require.NoError(t, router.PutIPNS(ctx, name, makeRecord(t, 3, time.Now().Add(time.Hour))))
+
+		rec, err := router.GetIPNS(ctx, name)
+		require.NoError(t, err)
+		seq, err := rec.Sequence()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), seq)
+	})
+
+	t.Run("Expired record is not found and is collected", func(t *testing.T) {
+		// Write the expired record directly, as it cannot be put.
+		expired := makeRecord(t, 4, time.Now().Add(-time.Minute))
+		raw, err := ipns.MarshalRecord(expired)
+		require.NoError(t, err)
+		require.NoError(t, dstore.Put(ctx, ipnsKey(name), raw))
+
+		_, err = router.GetIPNS(ctx, name)
+		require.ErrorIs(t, err, routing.ErrNotFound)
+
+		require.NoError(t, router.CollectGarbage(ctx))
+		require.Equal(t, 0, countKeys(t, dstore))
+	})
+}
+
+func TestDatastoreRouterHandler(t *testing.T) {
+	router, _, _ := newTestDatastoreRouter(t)
+	server := httptest.NewServer(Handler(router))
+	t.Cleanup(server.Close)
+
+	_, name := makeName(t)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/routing/v1/ipns/"+name.String(), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", mediaTypeIPNSRecord)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDatastoreRouterGCLoop(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	clk := clock.NewMock()
+	router := NewDatastoreRouter(dstore, WithClock(clk), WithGCInterval(time.Minute))
+
+	_, pid := makePeerID(t)
+	_, err := router.ProvideBitswap(ctx, &BitswapWriteProvideRequest{
+		Keys:        []cid.Cid{cid.MustParse("bafkqaaa")},
+		AdvisoryTTL: time.Second,
+		ID:          pid,
+	})
+	require.NoError(t, err)
+
+	clk.Add(time.Minute)
+	require.Eventually(t, func() bool { return countKeys(t, dstore) == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, router.Close())
+}
+
+func TestDatastoreRouterClosestPeers(t *testing.T) {
+	ctx := context.Background()
+	router, _, clk := newTestDatastoreRouter(t)
+
+	var pids []peer.ID
+	for i := 0; i < 5; i++ {
+		_, pid := makePeerID(t)
+		pids = append(pids, pid)
+		_, err := router.ProvideBitswap(ctx, &BitswapWriteProvideRequest{
+			Keys:        []cid.Cid{cid.MustParse("bafkqaaa")},
+			AdvisoryTTL: time.Hour * time.Duration(i+1),
+			ID:          pid,
+		})
+		require.NoError(t, err)
+	}
+
+	closest := func(t *testing.T, key cid.Cid, limit int) []peer.ID {
+		it, err := router.GetClosestPeers(ctx, key, limit)
+		require.NoError(t, err)
+		records, err := iter.ReadAllResults(it)
+		require.NoError(t, err)
+
+		var closest []peer.ID
+		for _, rec := range records {
+			closest = append(closest, *rec.(*types.PeerRecord).ID)
+		}
+		return closest
+	}
+
+	// A peer is the closest to its own key.
+	for _, pid := range pids {
+		found := closest(t, peer.ToCid(pid), 2)
+		require.Len(t, found, 2)
+		require.Equal(t, pid, found[0])
+	}
+
+	require.ElementsMatch(t, pids, closest(t, cid.MustParse("bafkqaaa"), 0))
+
+	// Expired peers are not returned.
+	clk.Add(90 * time.Minute)
+	require.Len(t, closest(t, cid.MustParse("bafkqaaa"), 0), 4)
+}
@@ -16,12 +16,14 @@ import (
 	"github.com/cespare/xxhash/v2"
 	"github.com/gorilla/mux"
 	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/filters"
 	"github.com/ipfs/boxo/routing/http/internal/drjson"
 	"github.com/ipfs/boxo/routing/http/types"
 	"github.com/ipfs/boxo/routing/http/types/iter"
 	jsontypes "github.com/ipfs/boxo/routing/http/types/json"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/multiformats/go-multiaddr"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -44,6 +46,7 @@ const (
 	findProvidersPath = "/routing/v1/providers/{cid}"
 	findPeersPath     = "/routing/v1/peers/{peer-id}"
 	GetIPNSPath       = "/routing/v1/ipns/{cid}"
+	closestPeersPath  = "/routing/v1/dht/closest/peers/{key}"
 )
 
 type FindProvidersAsyncResponse struct {
@@ -65,7 +68,8 @@ type ContentRouter interface {
 	// Limit indicates the maximum amount of results to return; 0 means unbounded.
 	FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[types.Record], error)
 
-	// GetIPNS searches for an [ipns.Record] for the given [ipns.Name].
+	// GetIPNS searches for an [ipns.Record] for the given [ipns.Name]. It
+	// returns [routing.ErrNotFound] if there is no record for the name.
 	GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error)
 
 	// PutIPNS stores the provided [ipns.Record] for the given [ipns.Name].
@@ -73,6 +77,15 @@ type ContentRouter interface {
 	PutIPNS(ctx context.Context, name ipns.Name, record *ipns.Record) error
 }
 
+// ClosestPeersRouter is an optional interface of [ContentRouter]
+// implementations, which enables the closest peers endpoint of the [Handler].
+type ClosestPeersRouter interface {
+	// GetClosestPeers returns the DHT peers closest to the given key, which
+	// can be any [cid.Cid], including the CID of a [peer.ID].
+	// Limit indicates the maximum amount of results to return; 0 means unbounded.
+	GetClosestPeers(ctx context.Context, key cid.Cid, limit int) (iter.ResultIter[types.Record], error)
+}
+
 // Deprecated: protocol-agnostic provide is being worked on in [IPIP-378]:
 //
 // [IPIP-378]: https://github.com/ipfs/specs/pull/378
@@ -135,6 +148,7 @@ func Handler(svc ContentRouter, opts ...Option) http.Handler {
 	r.HandleFunc(findProvidersPath, server.findProviders).Methods(http.MethodGet)
 	r.HandleFunc(providePath, server.provide).Methods(http.MethodPut)
 	r.HandleFunc(findPeersPath, server.findPeers).Methods(http.MethodGet)
+	r.HandleFunc(closestPeersPath, server.getClosestPeers).Methods(http.MethodGet)
 	r.HandleFunc(GetIPNSPath, server.GetIPNS).Methods(http.MethodGet)
 	r.HandleFunc(GetIPNSPath, server.PutIPNS).Methods(http.MethodPut)
 	return r
@@ -218,7 +232,7 @@ func (s *server) findProviders(w http.ResponseWriter, httpReq *http.Request) {
 		return
 	}
 
-	handlerFunc(w, provIter)
+	handlerFunc(w, applyQueryFilters(httpReq, provIter))
 }
 
 func (s *server) findProvidersJSON(w http.ResponseWriter, provIter iter.ResultIter[types.Record]) {
@@ -285,7 +299,64 @@ func (s *server) findPeers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handlerFunc(w, provIter)
+	handlerFunc(w, applyQueryFilters(r, provIter))
+}
+
+func (s *server) getClosestPeers(w http.ResponseWriter, r *http.Request) {
+	router, ok := s.svc.(ClosestPeersRouter)
+	if !ok {
+		writeErr(w, "GetClosestPeers", http.StatusNotImplemented, errors.New("closest peers are not supported by this router"))
+		return
+	}
+
+	// The key is a CID, but peer IDs in other formats are accepted too.
+	keyStr := mux.Vars(r)["key"]
+	key, err := cid.Decode(keyStr)
+	if err != nil {
+		pid, pidErr := peer.Decode(keyStr)
+		if pidErr != nil {
+			writeErr(w, "GetClosestPeers", http.StatusBadRequest, fmt.Errorf("unable to parse key: %w", err))
+			return
+		}
+		key = peer.ToCid(pid)
+	}
+
+	mediaType, err := s.detectResponseType(r)
+	if err != nil {
+		writeErr(w, "GetClosestPeers", http.StatusBadRequest, err)
+		return
+	}
+
+	var (
+		handlerFunc  func(w http.ResponseWriter, peersIter iter.ResultIter[types.Record])
+		recordsLimit int
+	)
+
+	if mediaType == mediaTypeNDJSON {
+		handlerFunc = s.findPeersNDJSON
+		recordsLimit = s.streamingRecordsLimit
+	} else {
+		handlerFunc = s.findPeersJSON
+		recordsLimit = s.recordsLimit
+	}
+
+	peersIter, err := router.GetClosestPeers(r.Context(), key, recordsLimit)
+	if err != nil {
+		writeErr(w, "GetClosestPeers", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
+		return
+	}
+
+	handlerFunc(w, applyQueryFilters(r, peersIter))
+}
+
+// applyQueryFilters filters the records by the transfer protocols and the
+// multiaddrs of the filter-protocols and filter-addrs query parameters, as
+// specified by IPIP-484.
+func applyQueryFilters(r *http.Request, recordsIter iter.ResultIter[types.Record]) iter.ResultIter[types.Record] {
+	query := r.URL.Query()
+	filterProtocols := filters.ParseFilter(query.Get(filters.QueryProtocols))
+	filterAddrs := filters.ParseFilter(query.Get(filters.QueryAddrs))
+	return filters.ApplyFiltersToIter(recordsIter, filterProtocols, filterAddrs)
 }
 
 func (s *server) provide(w http.ResponseWriter, httpReq *http.Request) {
@@ -386,6 +457,10 @@ func (s *server) GetIPNS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	record, err := s.svc.GetIPNS(r.Context(), name)
+	if errors.Is(err, routing.ErrNotFound) {
+		writeErr(w, "GetIPNS", http.StatusNotFound, err)
+		return
+	}
 	if err != nil {
 		writeErr(w, "GetIPNS", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
 		return
@@ -15,9 +15,12 @@ import (
 	"github.com/ipfs/boxo/routing/http/types"
 	"github.com/ipfs/boxo/routing/http/types/iter"
 	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	b58 "github.com/mr-tron/base58/base58"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -388,3 +391,124 @@ func (m *mockContentRouter) PutIPNS(ctx context.Context, name ipns.Name, record
 	args := m.Called(ctx, name, record)
 	return args.Error(0)
 }
+
+func TestProvidersFilters(t *testing.T) {
+	cidStr := "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4"
+	c, err := cid.Decode(cidStr)
+	require.NoError(t, err)
+
+	_, pid1 := makePeerID(t)
+	_, pid2 := makePeerID(t)
+	_, pid3 := makePeerID(t)
+	tcp, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	require.NoError(t, err)
+	relay, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/4001/p2p/" + pid1.String() + "/p2p-circuit")
+	require.NoError(t, err)
+
+	results := iter.FromSlice([]iter.Result[types.Record]{
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid1,
+			Protocols: []string{"transport-bitswap"},
+			Addrs:     []types.Multiaddr{{Multiaddr: tcp}, {Multiaddr: relay}},
+		}},
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid2,
+			Protocols: []string{"transport-foo"},
+			Addrs:     []types.Multiaddr{{Multiaddr: tcp}},
+		}},
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid3,
+			Protocols: []string{"transport-bitswap"},
+			Addrs:     []types.Multiaddr{{Multiaddr: relay}},
+		}},
+	})
+
+	router := &mockContentRouter{}
+	router.On("FindProviders", mock.Anything, c, DefaultRecordsLimit).Return(results, nil)
+	server := httptest.NewServer(Handler(router))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/routing/v1/providers/"+cidStr+"?filter-protocols=transport-bitswap&filter-addrs=!p2p-circuit", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", mediaTypeJSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"Providers":[{"Addrs":["/ip4/127.0.0.1/tcp/4001"],"ID":"`+pid1.String()+`","Protocols":["transport-bitswap"],"Schema":"peer"}]}`, string(body))
+}
+
+func TestClosestPeers(t *testing.T) {
+	makeRequest := func(t *testing.T, router ContentRouter, contentType, key string) *http.Response {
+		server := httptest.NewServer(Handler(router))
+		t.Cleanup(server.Close)
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/routing/v1/dht/closest/peers/"+key, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("GET /routing/v1/dht/closest/peers/{key} returns 501 if unsupported", func(t *testing.T) {
+		t.Parallel()
+
+		resp := makeRequest(t, &mockContentRouter{}, mediaTypeJSON, "bafkqaaa")
+		require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+
+	t.Run("GET /routing/v1/dht/closest/peers/{invalid-key} returns 400", func(t *testing.T) {
+		t.Parallel()
+
+		router := NewDatastoreRouter(dssync.MutexWrap(ds.NewMapDatastore()))
+		t.Cleanup(func() { router.Close() })
+		resp := makeRequest(t, router, mediaTypeJSON, "not-a-key")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	router := NewDatastoreRouter(dssync.MutexWrap(ds.NewMapDatastore()))
+	t.Cleanup(func() { router.Close() })
+
+	_, pid := makePeerID(t)
+	_, err := router.ProvideBitswap(context.Background(), &BitswapWriteProvideRequest{
+		Keys: []cid.Cid{cid.MustParse("bafkqaaa")},
+		ID:   pid,
+	})
+	require.NoError(t, err)
+
+	for _, key := range []string{"bafkqaaa", peer.ToCid(pid).String(), pid.String()} {
+		key := key
+
+		t.Run("GET /routing/v1/dht/closest/peers/"+key+" returns 200 (JSON)", func(t *testing.T) {
+			t.Parallel()
+
+			resp := makeRequest(t, router, mediaTypeJSON, key)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, mediaTypeJSON, resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, `{"Peers":[{"Addrs":[],"ID":"`+pid.String()+`","Protocols":["transport-bitswap"],"Schema":"peer"}]}`, string(body))
+		})
+
+		t.Run("GET /routing/v1/dht/closest/peers/"+key+" returns 200 (NDJSON)", func(t *testing.T) {
+			t.Parallel()
+
+			resp := makeRequest(t, router, mediaTypeNDJSON, key)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, mediaTypeNDJSON, resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, `{"Addrs":[],"ID":"`+pid.String()+`","Protocols":["transport-bitswap"],"Schema":"peer"}`+"\n", string(body))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package iter
+
+// Filter returns an iterator over the elements of iter for which f returns true.
+func Filter[T any](iter Iter[T], f func(t T) bool) *FilterIter[T] {
+	return &FilterIter[T]{iter: iter, f: f}
+}
+
+type FilterIter[T any] struct {
+	iter Iter[T]
+	f    func(T) bool
+
+	done bool
+	val  T
+}
+
+func (f *FilterIter[T]) Next() bool {
+	if f.done {
+		return false
+	}
+
+	for f.iter.Next() {
+		f.val = f.iter.Val()
+		if f.f(f.val) {
+			return true
+		}
+	}
+
+	f.done = true
+	return false
+}
+
+func (f *FilterIter[T]) Val() T {
+	return f.val
+}
+
+func (f *FilterIter[T]) Close() error {
+	return f.iter.Close()
+}